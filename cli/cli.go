@@ -0,0 +1,475 @@
+//-----------------------------------------------------------------------------
+/*
+
+sdfx Command Line Tool
+
+Render scene files and registered models without writing a Go program per part.
+
+sdfx render stl|3mf|svg|dxf|png [flags] <scene file|model>
+sdfx slice [flags] <scene file|model>
+sdfx bench [flags] <scene file|model>
+sdfx info [<scene file|model>]
+
+The input is a JSON/YAML scene file, or the name of a model registered with
+scene.RegisterModel. The sdfx command has a few demo models. To render your own
+parts build a tool that registers them and calls Main, e.g.
+
+func main() {
+	scene.RegisterModel("bracket", scene.SDF3Func(bracket))
+	os.Exit(cli.Main(os.Args[1:]))
+}
+
+*/
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jakoblorz/sdfx/render"
+	"github.com/jakoblorz/sdfx/scene"
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+const usage = `usage:
+  sdfx render stl|3mf|svg|dxf|png [flags] <scene file|model>
+  sdfx slice [flags] <scene file|model>
+  sdfx bench [flags] <scene file|model>
+  sdfx info [<scene file|model>]
+
+Use "sdfx <command> -h" for the command flags.
+`
+
+// svgLineStyle is the line style for SVG outlines.
+const svgLineStyle = "fill:none;stroke:black;stroke-width:0.1"
+
+// svgFillStyle is the fill style for SVG slices.
+const svgFillStyle = "fill:black;fill-rule:evenodd"
+
+// errUsage is returned for bad command lines, the usage has been printed.
+var errUsage = errors.New("usage")
+
+// tool runs sdfx commands.
+type tool struct {
+	stdout io.Writer // command output
+	stderr io.Writer // errors, usage and progress messages
+}
+
+// Main runs the sdfx tool with the command line arguments (without the program
+// name) and returns the exit status.
+func Main(args []string) int {
+	return run(args, os.Stdout, os.Stderr)
+}
+
+// run runs the sdfx tool and returns the exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	t := &tool{stdout, stderr}
+	err := t.command(args)
+	switch {
+	case err == nil, err == flag.ErrHelp:
+		return 0
+	case err == errUsage:
+		return 2
+	}
+	fmt.Fprintf(stderr, "sdfx: %s\n", err)
+	return 1
+}
+
+// command runs a command.
+func (t *tool) command(args []string) error {
+	if len(args) == 0 {
+		return t.usage()
+	}
+	switch args[0] {
+	case "render":
+		return t.render(args[1:])
+	case "slice":
+		return t.slice(args[1:])
+	case "bench":
+		return t.bench(args[1:])
+	case "info":
+		return t.info(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(t.stdout, usage)
+		return nil
+	}
+	fmt.Fprintf(t.stderr, "unknown command \"%s\"\n", args[0])
+	return t.usage()
+}
+
+// usage prints the tool usage.
+func (t *tool) usage() error {
+	fmt.Fprint(t.stderr, usage)
+	return errUsage
+}
+
+//-----------------------------------------------------------------------------
+// Flags
+
+// flags are the command line flags for a command.
+type flags struct {
+	*flag.FlagSet
+	output  string
+	cells   int
+	mesher  string
+	workers int
+	quiet   bool
+}
+
+// newFlags returns the flag set for a command, with the flags shared by the
+// rendering commands. The output flag has the given usage.
+func (t *tool) newFlags(name, args, output string) *flags {
+	f := &flags{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	f.SetOutput(t.stderr)
+	f.Usage = func() {
+		fmt.Fprintf(t.stderr, "usage: sdfx %s [flags] %s\n", name, args)
+		f.PrintDefaults()
+	}
+	f.StringVar(&f.output, "o", "", output)
+	f.IntVar(&f.cells, "cells", 200, "resolution, the number of cells on the longest axis")
+	f.StringVar(&f.mesher, "mesher", "mc", "mesher, mc (marching cubes), dc (dual contouring), adc (adaptive dual contouring) or umc (uniform marching cubes)")
+	f.IntVar(&f.workers, "workers", 0, "number of workers (default one per cpu)")
+	f.BoolVar(&f.quiet, "q", false, "don't report progress")
+	return f
+}
+
+// parse parses the command line and returns the single input argument.
+// The flags may be given before or after the input.
+func (f *flags) parse(args []string) (string, error) {
+	var inputs []string
+	for {
+		if err := f.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return "", err
+			}
+			return "", errUsage
+		}
+		if f.NArg() == 0 {
+			break
+		}
+		inputs = append(inputs, f.Arg(0))
+		args = f.Args()[1:]
+	}
+	if len(inputs) != 1 {
+		f.Usage()
+		return "", errUsage
+	}
+	if f.cells <= 0 {
+		return "", errors.New("cells <= 0")
+	}
+	return inputs[0], nil
+}
+
+// isSet returns true if a flag was given on the command line.
+func (f *flags) isSet(name string) bool {
+	set := false
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// meshers maps the mesher flag values to meshers.
+var meshers = map[string]render.Mesher{
+	"mc":  render.MarchingCubes,
+	"dc":  render.DualContouring,
+	"adc": render.AdaptiveDualContouring,
+	"umc": render.UniformMarchingCubes,
+}
+
+// options returns the render options for the flags.
+func (t *tool) options(f *flags) ([]render.RenderOption, error) {
+	m, ok := meshers[f.mesher]
+	if !ok {
+		return nil, fmt.Errorf("unknown mesher \"%s\"", f.mesher)
+	}
+	options := []render.RenderOption{render.UseMesher(m)}
+	if f.workers > 0 {
+		options = append(options, render.Workers(f.workers))
+	}
+	if !f.quiet {
+		options = append(options, render.OnProgress(func(p render.Progress) {
+			if p.Message != "" {
+				fmt.Fprintf(t.stderr, "%s\n", p.Message)
+			}
+		}))
+	}
+	return options, nil
+}
+
+//-----------------------------------------------------------------------------
+// Inputs
+
+// input is a model loaded from a scene file or the model registry.
+type input struct {
+	name string     // file name without the extension, or the model name
+	node scene.Node // model
+}
+
+// load loads a scene file, or a registered model if there is no such file.
+func load(arg string) (*input, error) {
+	if _, err := os.Stat(arg); err == nil {
+		n, err := scene.Load(arg)
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Base(arg)
+		return &input{strings.TrimSuffix(name, filepath.Ext(name)), n}, nil
+	}
+	n, err := scene.Model(arg)
+	if err != nil {
+		return nil, fmt.Errorf("no scene file or model \"%s\"", arg)
+	}
+	return &input{arg, n}, nil
+}
+
+// sdf3 builds the SDF3 for an input.
+func (in *input) sdf3() (sdf.SDF3, error) {
+	if !in.node.Is3D() {
+		return nil, fmt.Errorf("%s is 2d, not 3d", in.name)
+	}
+	s, err := in.node.SDF3()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", in.name, err)
+	}
+	return s, nil
+}
+
+// sdf2 builds the SDF2 for an input. A 3d input is sliced at height z.
+func (in *input) sdf2(z float64, middle bool) (sdf.SDF2, error) {
+	if in.node.Is3D() {
+		s, err := in.sdf3()
+		if err != nil {
+			return nil, err
+		}
+		if middle {
+			z = s.BoundingBox().Center().Z
+		}
+		return sdf.Slice2D(s, sdf.V3{Z: z}, sdf.V3{Z: 1}), nil
+	}
+	s, err := in.node.SDF2()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", in.name, err)
+	}
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
+// Commands
+
+// render renders an input to a file.
+func (t *tool) render(args []string) error {
+	if len(args) == 0 {
+		return t.usage()
+	}
+	format := args[0]
+	switch format {
+	case "stl", "3mf", "svg", "dxf", "png":
+	default:
+		if strings.HasPrefix(format, "-") {
+			return t.usage()
+		}
+		return fmt.Errorf("unknown render format \"%s\"", format)
+	}
+	f := t.newFlags("render "+format, "<scene file|model>", "output file (default <input name>."+format+")")
+	z := f.Float64("z", 0, "slice height for 2d output of a 3d model (default the middle)")
+	arg, err := f.parse(args[1:])
+	if err != nil {
+		return err
+	}
+	options, err := t.options(f)
+	if err != nil {
+		return err
+	}
+	in, err := load(arg)
+	if err != nil {
+		return err
+	}
+	path := f.output
+	if path == "" {
+		path = in.name + "." + format
+	}
+
+	if format == "stl" || format == "3mf" {
+		s, err := in.sdf3()
+		if err != nil {
+			return err
+		}
+		if format == "stl" {
+			return render.ToSTL(s, f.cells, path, options...)
+		}
+		return render.To3MF(s, f.cells, path, options...)
+	}
+	s, err := in.sdf2(*z, !f.isSet("z"))
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "svg":
+		return render.ToSVG(s, f.cells, path, svgLineStyle, options...)
+	case "dxf":
+		return render.ToDXF(s, f.cells, path, options...)
+	}
+	return t.toPNG(s, f.cells, path, f.quiet)
+}
+
+// toPNG renders an SDF2 as a gray scale distance image.
+func (t *tool) toPNG(s sdf.SDF2, cells int, path string, quiet bool) error {
+	bb := s.BoundingBox()
+	size := bb.Size()
+	pixels := size.MulScalar(float64(cells) / size.MaxComponent()).Ceil().ToV2i()
+	if !quiet {
+		fmt.Fprintf(t.stderr, "rendering %s (%dx%d)\n", path, pixels[0], pixels[1])
+	}
+	d, err := render.NewPNG(path, bb, pixels)
+	if err != nil {
+		return err
+	}
+	d.RenderSDF2(s)
+	return d.Save()
+}
+
+// slice slices an input into layers.
+func (t *tool) slice(args []string) error {
+	f := t.newFlags("slice", "<scene file|model>", "output file pattern for the layer number, or the file for layered svg (default <input name>_%04d.png)")
+	format := f.String("format", "png", "format, png (masks), svg (a file per layer) or layered (layered svg)")
+	layer := f.Float64("layer", 0.2, "layer height")
+	pixel := f.Float64("pixel", 0.05, "pixel size for png masks")
+	arg, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	options, err := t.options(f)
+	if err != nil {
+		return err
+	}
+	in, err := load(arg)
+	if err != nil {
+		return err
+	}
+	s, err := in.sdf3()
+	if err != nil {
+		return err
+	}
+	path := f.output
+
+	switch *format {
+	case "png":
+		if path == "" {
+			path = in.name + "_%04d.png"
+		}
+		return render.ToPNGLayers(s, *layer, *pixel, path, options...)
+	case "svg":
+		if path == "" {
+			path = in.name + "_%04d.svg"
+		}
+		return render.ToSVGLayers(s, *layer, f.cells, path, svgFillStyle, options...)
+	case "layered":
+		if path == "" {
+			path = in.name + ".svg"
+		}
+		return render.ToLayeredSVG(s, *layer, f.cells, path, svgFillStyle, options...)
+	}
+	return fmt.Errorf("unknown slice format \"%s\"", *format)
+}
+
+// bench reports the evaluation speed of an input (to stdout).
+func (t *tool) bench(args []string) error {
+	f := flag.NewFlagSet("bench", flag.ContinueOnError)
+	f.SetOutput(t.stderr)
+	f.Usage = func() {
+		fmt.Fprintf(t.stderr, "usage: sdfx bench [flags] <scene file|model>\n")
+		f.PrintDefaults()
+	}
+	n := f.Int("n", 10000000, "number of evaluations")
+	if err := f.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errUsage
+	}
+	if f.NArg() != 1 {
+		f.Usage()
+		return errUsage
+	}
+	if *n <= 0 {
+		return errors.New("n <= 0")
+	}
+	in, err := load(f.Arg(0))
+	if err != nil {
+		return err
+	}
+	var eps float64
+	if in.node.Is3D() {
+		s, err := in.sdf3()
+		if err != nil {
+			return err
+		}
+		eps = sdf.EvalRateSDF3(s, *n)
+	} else {
+		s, err := in.sdf2(0, false)
+		if err != nil {
+			return err
+		}
+		eps = sdf.EvalRateSDF2(s, *n)
+	}
+	fmt.Fprintf(t.stdout, "%s %s\n", in.name, sdf.FormatEvalRate(eps))
+	return nil
+}
+
+// info prints the type and bounding box of an input,
+// or the registered models if there is no input.
+func (t *tool) info(args []string) error {
+	switch len(args) {
+	case 0:
+		for _, name := range scene.Models() {
+			fmt.Fprintf(t.stdout, "%s\n", name)
+		}
+		return nil
+	case 1:
+	default:
+		return t.usage()
+	}
+	in, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(t.stdout, "name: %s\n", in.name)
+	if in.node.Is3D() {
+		s, err := in.sdf3()
+		if err != nil {
+			return err
+		}
+		bb := s.BoundingBox()
+		fmt.Fprintf(t.stdout, "type: SDF3 (%T)\n", s)
+		fmt.Fprintf(t.stdout, "min: %g %g %g\n", bb.Min.X, bb.Min.Y, bb.Min.Z)
+		fmt.Fprintf(t.stdout, "max: %g %g %g\n", bb.Max.X, bb.Max.Y, bb.Max.Z)
+		size := bb.Size()
+		fmt.Fprintf(t.stdout, "size: %g %g %g\n", size.X, size.Y, size.Z)
+		return nil
+	}
+	s, err := in.sdf2(0, false)
+	if err != nil {
+		return err
+	}
+	bb := s.BoundingBox()
+	fmt.Fprintf(t.stdout, "type: SDF2 (%T)\n", s)
+	fmt.Fprintf(t.stdout, "min: %g %g\n", bb.Min.X, bb.Min.Y)
+	fmt.Fprintf(t.stdout, "max: %g %g\n", bb.Max.X, bb.Max.Y)
+	size := bb.Size()
+	fmt.Fprintf(t.stdout, "size: %g %g\n", size.X, size.Y)
+	return nil
+}
+
+//-----------------------------------------------------------------------------
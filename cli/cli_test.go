@@ -0,0 +1,199 @@
+//-----------------------------------------------------------------------------
+
+//-----------------------------------------------------------------------------
+
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jakoblorz/sdfx/render"
+	"github.com/jakoblorz/sdfx/scene"
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+const testScene = `
+Type: Difference3D
+SDF0: {Type: Box3D, Size: {X: 10, Y: 10, Z: 4}, Round: 1}
+SDF1: {Type: Cylinder3D, Height: 6, Radius: 2}
+`
+
+func init() {
+	scene.RegisterModel("test-circle", scene.SDF2Func(func() (sdf.SDF2, error) {
+		return sdf.Circle2D(5)
+	}))
+}
+
+// testDir returns a temporary directory with the test scene file.
+func testDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sdfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "part.yaml"), []byte(testScene), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// testRun runs the tool and checks the exit status.
+func testRun(t *testing.T, status int, args ...string) (string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	if s := run(args, &stdout, &stderr); s != status {
+		t.Fatalf("%v: exit status %d (expected %d)\n%s", args, s, status, stderr.String())
+	}
+	return stdout.String(), stderr.String()
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Render(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+	part := filepath.Join(dir, "part.yaml")
+
+	for _, args := range [][]string{
+		{"render", "stl", "-cells", "20", "-q", "-o", filepath.Join(dir, "part.stl"), part},
+		{"render", "3mf", "-cells", "20", "-mesher", "dc", "-o", filepath.Join(dir, "part.3mf"), part},
+		{"render", "svg", "-cells", "20", "-o", filepath.Join(dir, "part.svg"), part, "-z", "1"},
+		{"render", "dxf", "-cells", "20", "-workers", "2", "-o", filepath.Join(dir, "circle.dxf"), "test-circle"},
+		{"render", "png", "-cells", "20", "-o", filepath.Join(dir, "circle.png"), "test-circle"},
+	} {
+		_, stderr := testRun(t, 0, args...)
+		if quiet := args[4] == "-q"; quiet != (stderr == "") {
+			t.Errorf("%v: unexpected progress \"%s\"", args, stderr)
+		}
+	}
+	for _, name := range []string{"part.3mf", "part.svg", "circle.dxf", "circle.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	mesh, err := render.LoadSTL(filepath.Join(dir, "part.stl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := render.NewMeshFromTriangles(mesh, 1e-9).Check(); !r.OK() {
+		t.Errorf("part.stl: %s", r)
+	}
+
+	// the default output is named after the input
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	testRun(t, 0, "render", "png", "-cells", "20", "test-circle")
+	testRun(t, 0, "slice", "-format", "layered", "-q", "part.yaml")
+	for _, name := range []string{"test-circle.png", "part.svg"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Slice(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+	part := filepath.Join(dir, "part.yaml")
+
+	testRun(t, 0, "slice", "-layer", "1", "-pixel", "0.5", "-q", "-o", filepath.Join(dir, "layer_%02d.png"), part)
+	testRun(t, 0, "slice", "-layer", "1", "-format", "svg", "-cells", "20", "-q", "-o", filepath.Join(dir, "layer_%02d.svg"), part)
+	for _, ext := range []string{"png", "svg"} {
+		files, _ := filepath.Glob(filepath.Join(dir, "layer_*."+ext))
+		if len(files) != 4 {
+			t.Errorf("%d %s layers (expected 4)", len(files), ext)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Info(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	stdout, _ := testRun(t, 0, "info")
+	if !strings.Contains(stdout, "test-circle\n") {
+		t.Errorf("missing model\n%s", stdout)
+	}
+	stdout, _ = testRun(t, 0, "info", filepath.Join(dir, "part.yaml"))
+	expect := "name: part\ntype: SDF3 (*sdf.DifferenceSDF3)\nmin: -5 -5 -2\nmax: 5 5 2\nsize: 10 10 4\n"
+	if stdout != expect {
+		t.Errorf("unexpected info\n%s", stdout)
+	}
+	stdout, _ = testRun(t, 0, "info", "test-circle")
+	if !strings.HasPrefix(stdout, "name: test-circle\ntype: SDF2") || !strings.Contains(stdout, "size: 10 10\n") {
+		t.Errorf("unexpected info\n%s", stdout)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Bench(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		input string
+		name  string
+	}{
+		{filepath.Join(dir, "part.yaml"), "part"},
+		{"test-circle", "test-circle"},
+	} {
+		stdout, _ := testRun(t, 0, "bench", "-n", "1000", test.input)
+		if !strings.HasPrefix(stdout, test.name+" ") || !strings.HasSuffix(stdout, " evals/sec\n") {
+			t.Errorf("unexpected bench output \"%s\"", stdout)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Errors(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+	part := filepath.Join(dir, "part.yaml")
+	bad := filepath.Join(dir, "bad.yaml")
+	ioutil.WriteFile(bad, []byte("{Type: Sphere3D, Radius: -1}"), 0644)
+
+	for _, test := range []struct {
+		args   []string
+		status int
+		err    string // expected error message
+	}{
+		{nil, 2, "usage:"},
+		{[]string{"draw"}, 2, "unknown command \"draw\""},
+		{[]string{"render"}, 2, "usage:"},
+		{[]string{"render", "obj", part}, 1, "unknown render format \"obj\""},
+		{[]string{"render", "stl", "-mesher", "foo", part}, 1, "unknown mesher \"foo\""},
+		{[]string{"render", "stl", "-cells", "0", part}, 1, "cells <= 0"},
+		{[]string{"render", "stl", "-size", "1", part}, 2, "flag provided but not defined: -size"},
+		{[]string{"render", "stl", part, part}, 2, "usage: sdfx render stl"},
+		{[]string{"render", "stl", "test-circle"}, 1, "test-circle is 2d, not 3d"},
+		{[]string{"render", "stl", "missing"}, 1, "no scene file or model \"missing\""},
+		{[]string{"render", "stl", bad}, 1, "bad: Sphere3D: "},
+		{[]string{"slice", "-format", "gif", part}, 1, "unknown slice format \"gif\""},
+		{[]string{"bench"}, 2, "usage:"},
+		{[]string{"bench", "-n", "0", part}, 1, "n <= 0"},
+		{[]string{"bench", part, part}, 2, "usage: sdfx bench"},
+		{[]string{"info", part, part}, 2, "usage:"},
+	} {
+		_, stderr := testRun(t, test.status, test.args...)
+		if !strings.Contains(stderr, test.err) {
+			t.Errorf("%v: expected error \"%s\", got \"%s\"", test.args, test.err, stderr)
+		}
+	}
+	testRun(t, 0, "render", "stl", "-h")
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,64 @@
+//-----------------------------------------------------------------------------
+/*
+
+sdfx renders scene files and registered models.
+
+sdfx render stl -cells 300 -mesher dc part.yaml
+sdfx render svg -o gear.svg gear
+sdfx slice -layer 0.1 -pixel 0.05 part.json
+sdfx info
+
+*/
+//-----------------------------------------------------------------------------
+
+package main
+
+import (
+	"os"
+
+	"github.com/jakoblorz/sdfx/cli"
+	"github.com/jakoblorz/sdfx/obj"
+	"github.com/jakoblorz/sdfx/scene"
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+// Demo Models
+
+// cube is a rounded cube with a hole.
+func cube() (sdf.SDF3, error) {
+	box, err := sdf.Box3D(sdf.V3{X: 1.0, Y: 1.0, Z: 1.0}, 0.1)
+	if err != nil {
+		return nil, err
+	}
+	hole, err := sdf.Cylinder3D(1.2, 0.3, 0)
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Difference3D(box, hole), nil
+}
+
+func init() {
+	scene.RegisterModel("cube", scene.SDF3Func(cube))
+	scene.RegisterModel("bolt", scene.NewNode(&scene.Bolt{BoltParms: obj.BoltParms{
+		Thread:      "M10x1.5",
+		Style:       "hex",
+		TotalLength: 30,
+		ShankLength: 5,
+	}}))
+	scene.RegisterModel("gear", scene.NewNode(&scene.InvoluteGear{InvoluteGearParms: obj.InvoluteGearParms{
+		NumberTeeth:   20,
+		Module:        1,
+		PressureAngle: sdf.DtoR(20),
+		RingWidth:     5,
+		Facets:        7,
+	}}))
+}
+
+//-----------------------------------------------------------------------------
+
+func main() {
+	os.Exit(cli.Main(os.Args[1:]))
+}
+
+//-----------------------------------------------------------------------------
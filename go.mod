@@ -9,4 +9,5 @@ require (
 	github.com/veandco/go-sdl2 v0.4.7
 	github.com/yofu/dxf v0.0.0-20190710012328-5a6d1e83f16c
 	golang.org/x/image v0.0.0-20210504121937-7319ad40d33e
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -144,6 +144,32 @@ func buildWorldMandelbulb(width, height int) (render.Camera, render.HitableList)
 	return camera.NewBasicFOVCamera(lookFrom, lookAt, sdf.V3{Y: 1.0}, 20, float64(width)/float64(height), aperture, distToFocus), world
 }
 
+// buildWorldSDF ray traces an sdf.SDF3 part directly
+func buildWorldSDF(width, height int) (render.Camera, render.HitableList) {
+
+	box, err := sdf.Box3D(sdf.V3{X: 1.0, Y: 1.0, Z: 1.0}, 0.1)
+	if err != nil {
+		panic(err)
+	}
+	hole, err := sdf.Cylinder3D(1.2, 0.3, 0)
+	if err != nil {
+		panic(err)
+	}
+	part := sdf.Difference3D(box, hole)
+
+	world := render.HitableList{
+		render.NewSDFHitable(part, material.Metal{render.Color{R: 0.8, G: 0.6, B: 0.2}, 0.3}),
+		Sphere{center: sdf.V3{Y: -100.5}, radius: 100, material: material.Lambertian{render.Color{R: 0.8, G: 0.8}}},
+	}
+
+	lookFrom := sdf.V3{-3.0, 3.0, 4.0}
+	lookAt := sdf.V3{}
+	aperture := 0.0
+	distToFocus := 1.0
+
+	return camera.NewBasicFOVCamera(lookFrom, lookAt, sdf.V3{Y: 1.0}, 20, float64(width)/float64(height), aperture, distToFocus), world
+}
+
 // saveImage saves the image (if requested) to a file in png format
 func saveImage(pixels render.Pixels, options Options) (error, bool) {
 	if options.Output != "" {
@@ -235,6 +261,7 @@ func main() {
 	//camera, world := buildWorldMetalSpheres(options.Width, options.Height)
 	//camera, world := buildWorldDielectrics(options.Width, options.Height)
 	camera, world := buildWorldMandelbulb(options.Width, options.Height)
+	//camera, world := buildWorldSDF(options.Width, options.Height)
 	//camera, world := buildWorldOneWeekend(options.Width, options.Height)
 
 	scene := render.NewScene(options.Width, options.Height, options.RaysPerPixel, camera, world)
@@ -57,11 +57,7 @@ func (m Mandelbulb) Evaluate(pos sdf.V3) float64 {
 }
 
 func (m Mandelbulb) EstimateNormal(pos sdf.V3) sdf.V3 {
-	return sdf.V3{
-		X: m.Evaluate(pos.Add(sdf.V3{X: Epsilon})) - m.Evaluate(pos.Sub(sdf.V3{X: Epsilon})),
-		Y: m.Evaluate(pos.Add(sdf.V3{Y: Epsilon})) - m.Evaluate(pos.Sub(sdf.V3{Y: Epsilon})),
-		Z: m.Evaluate(pos.Add(sdf.V3{Z: Epsilon})) - m.Evaluate(pos.Sub(sdf.V3{Z: Epsilon})),
-	}.Normalize()
+	return sdf.FiniteGradient3(m.Evaluate, pos, Epsilon).Normalize()
 }
 
 func (m Mandelbulb) Hit(ray *render.Ray3, tMin float64, tMax float64) (bool, *render.HitRecord) {
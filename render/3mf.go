@@ -0,0 +1,253 @@
+//-----------------------------------------------------------------------------
+/*
+
+3MF Save
+
+A 3MF file is a zip package containing an XML model with indexed vertices.
+See: https://github.com/3MFConsortium/spec_core
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// Unit3MF is the unit of measure for 3MF model coordinates.
+type Unit3MF string
+
+// 3MF units of measure.
+const (
+	Micron     Unit3MF = "micron"
+	Millimeter Unit3MF = "millimeter"
+	Centimeter Unit3MF = "centimeter"
+	Inch       Unit3MF = "inch"
+	Foot       Unit3MF = "foot"
+	Meter      Unit3MF = "meter"
+)
+
+// weldTolerance is the distance within which 3MF vertices are merged.
+const weldTolerance = 1e-6
+
+const contentTypes3MF = `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+ <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+ <Default Extension="model" ContentType="application/vnd.ms-package.3dmanufacturing-3dmodel+xml"/>
+</Types>
+`
+
+const rels3MF = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+ <Relationship Target="/3D/3dmodel.model" Id="rel0" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"/>
+</Relationships>
+`
+
+//-----------------------------------------------------------------------------
+
+// model3MF is an indexed triangle mesh for a 3MF file.
+type model3MF struct {
+	name string
+	unit Unit3MF
+	mesh *Mesh
+}
+
+func newModel3MF(name string, unit Unit3MF, mesh *Mesh) *model3MF {
+	return &model3MF{
+		name: name,
+		unit: unit,
+		mesh: mesh,
+	}
+}
+
+// fmtFloat formats a model coordinate.
+func fmtFloat(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 32)
+}
+
+// writeModel writes the 3MF model XML.
+func (m *model3MF) writeModel(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(buf, "<model unit=\"%s\" xml:lang=\"en-US\" xmlns=\"http://schemas.microsoft.com/3dmanufacturing/core/2015/02\">\n", m.unit)
+	fmt.Fprintf(buf, " <resources>\n")
+	var name strings.Builder
+	if err := xml.EscapeText(&name, []byte(m.name)); err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "  <object id=\"1\" name=\"%s\" type=\"model\">\n", name.String())
+	fmt.Fprintf(buf, "   <mesh>\n")
+	fmt.Fprintf(buf, "    <vertices>\n")
+	for _, v := range m.mesh.Vertex {
+		fmt.Fprintf(buf, "     <vertex x=\"%s\" y=\"%s\" z=\"%s\"/>\n", fmtFloat(v.X), fmtFloat(v.Y), fmtFloat(v.Z))
+	}
+	fmt.Fprintf(buf, "    </vertices>\n")
+	fmt.Fprintf(buf, "    <triangles>\n")
+	for _, f := range m.mesh.Face {
+		fmt.Fprintf(buf, "     <triangle v1=\"%d\" v2=\"%d\" v3=\"%d\"/>\n", f[0], f[1], f[2])
+	}
+	fmt.Fprintf(buf, "    </triangles>\n")
+	fmt.Fprintf(buf, "   </mesh>\n")
+	fmt.Fprintf(buf, "  </object>\n")
+	fmt.Fprintf(buf, " </resources>\n")
+	fmt.Fprintf(buf, " <build>\n")
+	fmt.Fprintf(buf, "  <item objectid=\"1\"/>\n")
+	fmt.Fprintf(buf, " </build>\n")
+	fmt.Fprintf(buf, "</model>\n")
+	return buf.Flush()
+}
+
+// write writes the 3MF zip package.
+func (m *model3MF) write(w io.Writer) error {
+	z := zip.NewWriter(w)
+	parts := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"[Content_Types].xml", func(w io.Writer) error {
+			_, err := io.WriteString(w, contentTypes3MF)
+			return err
+		}},
+		{"_rels/.rels", func(w io.Writer) error {
+			_, err := io.WriteString(w, rels3MF)
+			return err
+		}},
+		{"3D/3dmodel.model", m.writeModel},
+	}
+	for _, p := range parts {
+		f, err := z.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if err := p.write(f); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// save writes the 3MF package to a file.
+func (m *model3MF) save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := m.write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// name3MF returns a default object name for a 3MF file.
+func name3MF(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+//-----------------------------------------------------------------------------
+
+// Save3MF writes a triangle mesh to a 3MF file.
+func Save3MF(path, name string, unit Unit3MF, mesh []*Triangle3) error {
+	m := newModel3MF(name, unit, NewMeshFromTriangles(mesh, weldTolerance))
+	return m.save(path)
+}
+
+//-----------------------------------------------------------------------------
+
+// Stream3MF writes a stream of triangles to a 3MF file.
+// The vertices are indexed as the triangles arrive. Close the triangle
+// channel when done, the file is then written and the result of the
+// write is sent on the error channel.
+func Stream3MF(path, name string, unit Unit3MF) (chan<- *Triangle3, <-chan error, error) {
+
+	// check we can create the file before meshing starts
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f.Close()
+
+	// External code writes triangles to this channel.
+	// This goroutine reads the channel and builds the indexed mesh.
+	c := make(chan *Triangle3)
+	errc := make(chan error, 1)
+
+	go func() {
+		mesh := NewMesh(weldTolerance)
+		for t := range c {
+			mesh.Add(t)
+		}
+		errc <- newModel3MF(name, unit, mesh).save(path)
+	}()
+
+	return c, errc, nil
+}
+
+// Write3MF writes a stream of triangles to a 3MF file.
+// The file is written when the channel is closed.
+// Write errors are printed, use Stream3MF to get them.
+func Write3MF(wg *sync.WaitGroup, path, name string, unit Unit3MF) (chan<- *Triangle3, error) {
+	c, errc, err := Stream3MF(path, name, unit)
+	if err != nil {
+		return nil, err
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := <-errc; err != nil {
+			fmt.Printf("%s\n", err)
+		}
+	}()
+	return c, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// To3MF renders an SDF3 as a 3MF file.
+// The object is named after the file and the units are millimeters.
+func To3MF(
+	s sdf.SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	path string, //path to filename
+	options ...RenderOption, //optional settings
+) error {
+	cfg := newRenderConfig(options)
+	resolution, cells := meshResolution(s, meshCells)
+	cfg.report("rendering %s (%dx%dx%d, resolution %.2f)", path, cells[0], cells[1], cells[2], resolution)
+	mesh := cfg.mesh(s, resolution)
+	if err := cfg.t.err(); err != nil {
+		return err
+	}
+	return newModel3MF(name3MF(path), Millimeter, mesh).save(path)
+}
+
+// Render3MF renders an SDF3 as a 3MF file (uses octree sampling).
+// The object is named after the file and the units are millimeters.
+func Render3MF(
+	s sdf.SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	path string, //path to filename
+	options ...RenderOption, //optional settings
+) {
+	options = append([]RenderOption{OnProgress(printProgress)}, options...)
+	if err := To3MF(s, meshCells, path, options...); err != nil {
+		fmt.Printf("%s\n", err)
+	}
+}
+
+//-----------------------------------------------------------------------------
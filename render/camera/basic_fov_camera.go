@@ -46,5 +46,5 @@ func (c basicFOVCamera) Ray(rnd render.Rnd, u, v float64) *render.Ray3 {
 		origin = origin.Add(offset)
 		d = d.Sub(offset)
 	}
-	return &render.Ray3{origin, d, rnd}
+	return &render.Ray3{Origin: origin, Direction: d, Rnd: rnd}
 }
@@ -0,0 +1,277 @@
+//-----------------------------------------------------------------------------
+/*
+
+Contours
+
+Marching squares generates unordered line segments. The stitcher joins them
+into polylines so the DXF and SVG outputs have one entity per contour.
+
+Marching squares puts the solid on the right hand side of each line segment.
+The stitched contours are reversed so that outer boundaries are counter-clockwise
+and holes are clockwise.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"math"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// stitchTolerance is the stitching tolerance as a fraction of the sampling resolution.
+const stitchTolerance = 1e-6
+
+//-----------------------------------------------------------------------------
+
+// Contour is a 2d polyline.
+// For closed contours the first point is not repeated at the end.
+type Contour struct {
+	Points []sdf.V2
+	Closed bool
+}
+
+// Area returns the signed area of a closed contour.
+// It is positive for counter-clockwise contours.
+func (c *Contour) Area() float64 {
+	if !c.Closed {
+		return 0
+	}
+	var a float64
+	n := len(c.Points)
+	for i, p0 := range c.Points {
+		p1 := c.Points[(i+1)%n]
+		a += p0.X*p1.Y - p1.X*p0.Y
+	}
+	return 0.5 * a
+}
+
+// Hole returns true if the contour is the inner boundary of a solid region.
+func (c *Contour) Hole() bool {
+	return c.Area() < 0
+}
+
+// Reverse reverses the direction of the contour.
+func (c *Contour) Reverse() {
+	p := c.Points
+	for i, j := 0, len(p)-1; i < j; i, j = i+1, j-1 {
+		p[i], p[j] = p[j], p[i]
+	}
+}
+
+// Simplify removes points from the contour (Douglas-Peucker) while keeping
+// the contour within tolerance of the original.
+func (c *Contour) Simplify(tolerance float64) {
+	p := c.Points
+	if !c.Closed {
+		if len(p) > 2 {
+			c.Points = douglasPeucker(p, tolerance)
+		}
+		return
+	}
+	if len(p) < 4 {
+		return
+	}
+	// split the contour at the point furthest from the first point
+	k := 0
+	var dmax float64
+	for i, q := range p {
+		if d := q.Sub(p[0]).Length2(); d > dmax {
+			k, dmax = i, d
+		}
+	}
+	if k == 0 {
+		return
+	}
+	a := douglasPeucker(p[:k+1], tolerance)
+	b := douglasPeucker(append(append([]sdf.V2{}, p[k:]...), p[0]), tolerance)
+	p = append(a, b[1:len(b)-1]...)
+	// the first point was kept as a split point, remove it if possible
+	if len(p) > 3 && segmentDistance(p[0], p[len(p)-1], p[1]) <= tolerance {
+		p = p[1:]
+	}
+	c.Points = p
+}
+
+//-----------------------------------------------------------------------------
+
+// segmentDistance returns the distance from p to the line segment ab.
+func segmentDistance(p, a, b sdf.V2) float64 {
+	ab := b.Sub(a)
+	l2 := ab.Length2()
+	if l2 == 0 {
+		return p.Sub(a).Length()
+	}
+	t := sdf.Clamp(p.Sub(a).Dot(ab)/l2, 0, 1)
+	return p.Sub(a.Add(ab.MulScalar(t))).Length()
+}
+
+// douglasPeucker simplifies a polyline. The end points are kept.
+func douglasPeucker(p []sdf.V2, tolerance float64) []sdf.V2 {
+	keep := make([]bool, len(p))
+	keep[0] = true
+	keep[len(p)-1] = true
+	stack := [][2]int{{0, len(p) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		k := -1
+		dmax := tolerance
+		for i := s[0] + 1; i < s[1]; i++ {
+			if d := segmentDistance(p[i], p[s[0]], p[s[1]]); d > dmax {
+				k, dmax = i, d
+			}
+		}
+		if k >= 0 {
+			keep[k] = true
+			stack = append(stack, [2]int{s[0], k}, [2]int{k, s[1]})
+		}
+	}
+	var q []sdf.V2
+	for i, v := range p {
+		if keep[i] {
+			q = append(q, v)
+		}
+	}
+	return q
+}
+
+//-----------------------------------------------------------------------------
+
+// pointIndex is a spatial hash of line end points.
+type pointIndex struct {
+	tolerance float64
+	cells     map[[2]int64][]int
+}
+
+func newPointIndex(tolerance float64) *pointIndex {
+	return &pointIndex{
+		tolerance: tolerance,
+		cells:     make(map[[2]int64][]int),
+	}
+}
+
+// cell returns the hash cell for a point.
+func (pi *pointIndex) cell(p sdf.V2) [2]int64 {
+	return [2]int64{int64(math.Floor(p.X / pi.tolerance)), int64(math.Floor(p.Y / pi.tolerance))}
+}
+
+// add adds a point with index i.
+func (pi *pointIndex) add(p sdf.V2, i int) {
+	k := pi.cell(p)
+	pi.cells[k] = append(pi.cells[k], i)
+}
+
+// find returns the index of an unused point within tolerance of p, or -1 if there is none.
+func (pi *pointIndex) find(p sdf.V2, point func(i int) sdf.V2, used []bool) int {
+	k := pi.cell(p)
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for _, i := range pi.cells[[2]int64{k[0] + dx, k[1] + dy}] {
+				if !used[i] && point(i).Equals(p, pi.tolerance) {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+//-----------------------------------------------------------------------------
+
+// StitchLines joins line segments into contours.
+// Segment end points within tolerance of each other are joined.
+// The line segments should have the solid on their right hand side (as generated
+// by marching squares), the contours are then counter-clockwise for outer
+// boundaries and clockwise for holes.
+func StitchLines(lines []*Line, tolerance float64) []*Contour {
+	starts := newPointIndex(tolerance)
+	ends := newPointIndex(tolerance)
+	for i, l := range lines {
+		starts.add(l[0], i)
+		ends.add(l[1], i)
+	}
+	start := func(i int) sdf.V2 { return lines[i][0] }
+	end := func(i int) sdf.V2 { return lines[i][1] }
+
+	used := make([]bool, len(lines))
+	var contours []*Contour
+	for i, l := range lines {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		p := []sdf.V2{l[0], l[1]}
+		// follow the lines forwards
+		for {
+			j := starts.find(p[len(p)-1], start, used)
+			if j < 0 {
+				break
+			}
+			used[j] = true
+			p = append(p, lines[j][1])
+		}
+		closed := len(p) > 3 && p[0].Equals(p[len(p)-1], tolerance)
+		if closed {
+			p = p[:len(p)-1]
+		} else {
+			// follow the lines backwards
+			var q []sdf.V2
+			head := p[0]
+			for {
+				j := ends.find(head, end, used)
+				if j < 0 {
+					break
+				}
+				used[j] = true
+				head = lines[j][0]
+				q = append([]sdf.V2{head}, q...)
+			}
+			p = append(q, p...)
+		}
+		// remove duplicate points
+		q := p[:1]
+		for _, v := range p[1:] {
+			if !v.Equals(q[len(q)-1], tolerance) {
+				q = append(q, v)
+			}
+		}
+		if closed && len(q) > 1 && q[0].Equals(q[len(q)-1], tolerance) {
+			q = q[:len(q)-1]
+		}
+		c := &Contour{Points: q, Closed: closed}
+		c.Reverse()
+		contours = append(contours, c)
+	}
+	return contours
+}
+
+//-----------------------------------------------------------------------------
+
+// simplifyContours simplifies contours, closed contours that collapse are removed.
+func simplifyContours(contours []*Contour, tolerance float64) []*Contour {
+	if tolerance <= 0 {
+		return contours
+	}
+	var result []*Contour
+	for _, c := range contours {
+		c.Simplify(tolerance)
+		if !c.Closed || len(c.Points) >= 3 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// renderContours returns the contours for an SDF2 (uses quadtree sampling).
+func renderContours(s sdf.SDF2, resolution float64, cfg *renderConfig) []*Contour {
+	lines := renderLines(s, resolution, cfg.engine, cfg.t)
+	contours := StitchLines(lines, resolution*stitchTolerance)
+	return simplifyContours(contours, cfg.contourTolerance)
+}
+
+//-----------------------------------------------------------------------------
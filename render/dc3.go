@@ -0,0 +1,342 @@
+//-----------------------------------------------------------------------------
+/*
+
+Dual Contouring
+
+Convert an SDF3 to a triangle mesh.
+See: Dual Contouring of Hermite Data, Ju, Losasso, Schaefer & Warren, 2002
+
+Each cell that the surface passes through gets a single vertex. The vertex
+is placed by minimising a quadratic error function (QEF) built from the
+surface intersections and normals on the cell edges, so vertices land on
+sharp edges and corners rather than rounding them off. Each cell edge that
+crosses the surface generates a quad joining the vertices of its 4 cells.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"math"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+const dcRootSteps = 8     // maximum number of steps to locate an edge intersection
+const dcTruncate = 0.1    // relative size of the QEF eigenvalues that are ignored
+const dcNormalStep = 1e-3 // normal step size relative to the cell size
+
+// dcCorners are the corner offsets of a cell (in cell units).
+var dcCorners = [8]sdf.V3i{
+	{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0},
+	{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1},
+}
+
+// dcEdges are the edges of a cell as pairs of corner indices.
+var dcEdges = [12][2]int{
+	{0, 1}, {1, 2}, {2, 3}, {3, 0},
+	{4, 5}, {5, 6}, {6, 7}, {7, 4},
+	{0, 4}, {1, 5}, {2, 6}, {3, 7},
+}
+
+// dcAxes are the x, y and z unit vectors (in cell units).
+var dcAxes = [3]sdf.V3i{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+// dcQuads are the offsets of the 4 cells around the x, y and z edges
+// leaving the minimum corner of a cell. They are ordered counter-clockwise
+// when viewed from the positive end of the edge.
+var dcQuads = [3][4]sdf.V3i{
+	{{0, -1, -1}, {0, 0, -1}, {0, 0, 0}, {0, -1, 0}},
+	{{-1, 0, -1}, {-1, 0, 0}, {0, 0, 0}, {0, 0, -1}},
+	{{-1, -1, 0}, {0, -1, 0}, {0, 0, 0}, {-1, 0, 0}},
+}
+
+//-----------------------------------------------------------------------------
+
+// eigen returns the eigenvalues and eigenvectors (as columns)
+// of a symmetric 3x3 matrix using Jacobi rotations.
+func eigen(a [3][3]float64) ([3]float64, [3][3]float64) {
+	v := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	for sweep := 0; sweep < 16; sweep++ {
+		off := a[0][1]*a[0][1] + a[0][2]*a[0][2] + a[1][2]*a[1][2]
+		if off < epsilon {
+			break
+		}
+		for _, pq := range [3][2]int{{0, 1}, {0, 2}, {1, 2}} {
+			p, q := pq[0], pq[1]
+			if math.Abs(a[p][q]) < epsilon {
+				continue
+			}
+			// rotate to zero a[p][q]
+			theta := 0.5 * math.Atan2(2*a[p][q], a[q][q]-a[p][p])
+			c, s := math.Cos(theta), math.Sin(theta)
+			for k := 0; k < 3; k++ {
+				akp, akq := a[k][p], a[k][q]
+				a[k][p] = c*akp - s*akq
+				a[k][q] = s*akp + c*akq
+			}
+			for k := 0; k < 3; k++ {
+				apk, aqk := a[p][k], a[q][k]
+				a[p][k] = c*apk - s*aqk
+				a[q][k] = s*apk + c*aqk
+			}
+			for k := 0; k < 3; k++ {
+				vkp, vkq := v[k][p], v[k][q]
+				v[k][p] = c*vkp - s*vkq
+				v[k][q] = s*vkp + c*vkq
+			}
+		}
+	}
+	return [3]float64{a[0][0], a[1][1], a[2][2]}, v
+}
+
+// minimumNear returns the point minimising the quadric error that is closest to m.
+// Small eigenvalues are truncated, so flat regions and edges are solved in
+// the directions they constrain and left at m in the others.
+func (q quadric) minimumNear(m sdf.V3) sdf.V3 {
+	a := [3][3]float64{
+		{q[0], q[1], q[2]},
+		{q[1], q[4], q[5]},
+		{q[2], q[5], q[7]},
+	}
+	// solve a.y = r for the offset y = x - m
+	r := [3]float64{
+		-q[3] - (a[0][0]*m.X + a[0][1]*m.Y + a[0][2]*m.Z),
+		-q[6] - (a[1][0]*m.X + a[1][1]*m.Y + a[1][2]*m.Z),
+		-q[8] - (a[2][0]*m.X + a[2][1]*m.Y + a[2][2]*m.Z),
+	}
+	lambda, v := eigen(a)
+	lmax := math.Max(math.Abs(lambda[0]), math.Max(math.Abs(lambda[1]), math.Abs(lambda[2])))
+	var y [3]float64
+	for i := 0; i < 3; i++ {
+		if math.Abs(lambda[i]) <= dcTruncate*lmax || lmax < epsilon {
+			continue
+		}
+		// project r onto the eigenvector
+		k := (v[0][i]*r[0] + v[1][i]*r[1] + v[2][i]*r[2]) / lambda[i]
+		for j := 0; j < 3; j++ {
+			y[j] += k * v[j][i]
+		}
+	}
+	return sdf.V3{X: m.X + y[0], Y: m.Y + y[1], Z: m.Z + y[2]}
+}
+
+// surfaceCells appends the cells (at the octree resolution) that contain the surface.
+func (dc *dcache3) surfaceCells(c *cube, cells *[]sdf.V3i) {
+	if c.n > 1 && dc.t.cancelled() {
+		return
+	}
+	if dc.isEmpty(c) {
+		dc.t.addCells(c.cells())
+		return
+	}
+	if c.n == 1 {
+		dc.t.addCells(1)
+		// this cube is at the required resolution
+		inside := 0
+		_, values := dc.corners(c)
+		for _, d := range values {
+			if d < 0 {
+				inside++
+			}
+		}
+		if inside != 0 && inside != 8 {
+			*cells = append(*cells, sdf.V3i{c.v[0] / 2, c.v[1] / 2, c.v[2] / 2})
+		}
+		return
+	}
+	// process the sub cubes
+	n := c.n - 1
+	s := 1 << n
+	for _, k := range dcCorners {
+		dc.surfaceCells(&cube{c.v.Add(sdf.V3i{s * k[0], s * k[1], s * k[2]}), n}, cells)
+	}
+}
+
+// corner returns the position and distance of a cell corner.
+func (dc *dcache3) corner(cell sdf.V3i) (sdf.V3, float64) {
+	return dc.evaluate(sdf.V3i{2 * cell[0], 2 * cell[1], 2 * cell[2]})
+}
+
+// intersect returns the surface position on the edge p0 to p1.
+func (dc *dcache3) intersect(p0, p1 sdf.V3, d0, d1 float64) sdf.V3 {
+	var p sdf.V3
+	for i := 0; i < dcRootSteps; i++ {
+		// false position
+		p = p0.Add(p1.Sub(p0).MulScalar(d0 / (d0 - d1)))
+		d := dc.s.Evaluate(p)
+		if math.Abs(d) < tolerance {
+			break
+		}
+		if (d < 0) == (d0 < 0) {
+			p0, d0 = p, d
+		} else {
+			p1, d1 = p, d
+		}
+	}
+	return p
+}
+
+// qef accumulates the surface intersections and normals within a cell.
+type qef struct {
+	q      quadric // sum of the tangent plane quadrics
+	mass   sdf.V3  // sum of the intersection points
+	normal sdf.V3  // sum of the surface normals
+	n      int     // number of intersections
+}
+
+// add adds the intersections of another cell.
+func (a *qef) add(b *qef) {
+	a.q = a.q.add(b.q)
+	a.mass = a.mass.Add(b.mass)
+	a.normal = a.normal.Add(b.normal)
+	a.n += b.n
+}
+
+// solve returns the vertex minimising the QEF and its mean squared error.
+func (a *qef) solve() (sdf.V3, float64) {
+	v := a.q.minimumNear(a.mass.DivScalar(float64(a.n)))
+	return v, math.Max(a.q.eval(v), 0) / float64(a.n)
+}
+
+// cellQEF returns the QEF for the edge intersections of a cell.
+func (dc *dcache3) cellQEF(cell sdf.V3i) *qef {
+	size := 2 * dc.resolution
+	var p [8]sdf.V3
+	var d [8]float64
+	for i, k := range dcCorners {
+		p[i], d[i] = dc.corner(cell.Add(k))
+	}
+	a := &qef{}
+	for _, e := range dcEdges {
+		d0, d1 := d[e[0]], d[e[1]]
+		if (d0 < 0) == (d1 < 0) {
+			continue
+		}
+		x := dc.intersect(p[e[0]], p[e[1]], d0, d1)
+		normal := sdfNormal(dc.s, x, dcNormalStep*size)
+		if !math.IsNaN(normal.X) {
+			a.q = a.q.add(planeQuadric(normal, -normal.Dot(x)))
+			a.normal = a.normal.Add(normal)
+		}
+		a.mass = a.mass.Add(x)
+		a.n++
+	}
+	return a
+}
+
+// cellVertex returns the dual contouring vertex for a cell.
+func (dc *dcache3) cellVertex(cell sdf.V3i) sdf.V3 {
+	v, _ := dc.cellQEF(cell).solve()
+	// keep the vertex within the cell
+	return v.Clamp(dc.cellBox(cell, 0))
+}
+
+// dcQuad returns the cells around the edge leaving the minimum corner
+// of a cell along an axis. The cells are ordered so the quad joining
+// their vertices faces outwards. It returns false if the edge does not
+// cross the surface.
+func (dc *dcache3) dcQuad(cell sdf.V3i, axis int) ([4]sdf.V3i, bool) {
+	var quad [4]sdf.V3i
+	_, d0 := dc.corner(cell)
+	_, d1 := dc.corner(cell.Add(dcAxes[axis]))
+	if (d0 < 0) == (d1 < 0) {
+		return quad, false
+	}
+	for j, k := range dcQuads[axis] {
+		quad[j] = cell.Add(k)
+	}
+	if d0 >= 0 {
+		// the surface normal points along the negative axis
+		quad[1], quad[3] = quad[3], quad[1]
+	}
+	return quad, true
+}
+
+// dcTriangles outputs the triangles for a quad, split along the shorter diagonal.
+func dcTriangles(v [4]sdf.V3, output chan<- *Triangle3, t *tracker) {
+	t.addTriangles(2)
+	if v[0].Sub(v[2]).Length2() <= v[1].Sub(v[3]).Length2() {
+		output <- NewTriangle3(v[0], v[1], v[2])
+		output <- NewTriangle3(v[0], v[2], v[3])
+	} else {
+		output <- NewTriangle3(v[0], v[1], v[3])
+		output <- NewTriangle3(v[1], v[2], v[3])
+	}
+}
+
+// dcSurface returns the distance cache and the surface cells for an SDF3.
+// The upper levels of the octree are searched in parallel.
+func dcSurface(s sdf.SDF3, resolution float64, e *Engine, t *tracker) (*dcache3, []sdf.V3i) {
+	// Scale the bounding box about the center to make sure the boundaries
+	// aren't on the object surface.
+	bb := s.BoundingBox()
+	bb = bb.ScaleAboutCenter(1.01)
+	longAxis := bb.Size().MaxComponent()
+	// Use the octree to find the surface cells.
+	// The level = 0 cube is at half resolution.
+	resolution = 0.5 * resolution
+	levels := uint(math.Ceil(math.Log2(longAxis/resolution))) + 1
+	dc := newDcache3(s, bb.Min, resolution, levels)
+	dc.t = t
+	top := &cube{sdf.V3i{0, 0, 0}, levels - 1}
+	t.setTotal(top.cells())
+	cubes := dc.split(top, 4*e.Workers())
+	found := make([][]sdf.V3i, len(cubes))
+	e.Run(len(cubes), func(_, i int) {
+		dc.surfaceCells(cubes[i], &found[i])
+	})
+	var cells []sdf.V3i
+	for _, f := range found {
+		cells = append(cells, f...)
+	}
+	return dc, cells
+}
+
+//-----------------------------------------------------------------------------
+
+// dualContouring generates a triangle mesh for an SDF3 using dual contouring.
+func dualContouring(s sdf.SDF3, resolution float64, output chan<- *Triangle3, e *Engine, t *tracker) {
+	dc, cells := dcSurface(s, resolution, e, t)
+	if t.cancelled() {
+		return
+	}
+
+	// work out the cell vertices in parallel
+	vertex := make([]sdf.V3, len(cells))
+	e.Run(len(cells), func(_, i int) {
+		vertex[i] = dc.cellVertex(cells[i])
+	})
+	index := make(map[sdf.V3i]int, len(cells))
+	for i, c := range cells {
+		index[c] = i
+	}
+
+	// generate a quad for each edge that crosses the surface
+	for _, c := range cells {
+		for axis := range dcAxes {
+			quad, ok := dc.dcQuad(c, axis)
+			if !ok {
+				continue
+			}
+			var v [4]sdf.V3
+			for j, k := range quad {
+				i, found := index[k]
+				if !found {
+					ok = false
+					break
+				}
+				v[j] = vertex[i]
+			}
+			if ok {
+				dcTriangles(v, output, t)
+			}
+		}
+	}
+	t.flush()
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,217 @@
+//-----------------------------------------------------------------------------
+/*
+
+Adaptive Dual Contouring
+
+Convert an SDF3 to a triangle mesh.
+Uses octree simplification so the mesh is coarse where the surface is flat
+and fine where it is curved.
+See: Dual Contouring of Hermite Data, Ju, Losasso, Schaefer & Warren, 2002
+
+The surface cells are found at the finest resolution. Octree cells are then
+merged bottom up while a single vertex can represent the surface within them
+(the QEF error is small and the normals agree). The polygons are generated
+from the edges of the finest cells, joining the vertices of the merged cells
+that contain them. Polygons that collapse to a line or point are dropped, so
+the mesh is crack free across changes in cell size.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import "github.com/jakoblorz/sdfx/sdf"
+
+//-----------------------------------------------------------------------------
+
+const dcAdaptiveError = 0.02 // maximum rms QEF error relative to the cell size
+const dcNormalLimit = 0.9    // minimum length of the mean normal for a merged cell
+
+// dcCluster is a group of surface cells represented by a single vertex.
+type dcCluster struct {
+	qef               // intersections within the cluster
+	key    sdf.V3i    // octree cell of the cluster
+	level  uint       // octree level of the cluster (0 = finest)
+	parent *dcCluster // the cluster this has been merged into
+}
+
+// root returns the cluster that a cell has been merged into.
+func (c *dcCluster) root() *dcCluster {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c
+}
+
+// dcKey returns the octree cell containing a cell at a given level.
+func dcKey(cell sdf.V3i, level uint) sdf.V3i {
+	return sdf.V3i{cell[0] >> level, cell[1] >> level, cell[2] >> level}
+}
+
+// cellBox returns the minimum and maximum corners of an octree cell.
+func (dc *dcache3) cellBox(key sdf.V3i, level uint) (sdf.V3, sdf.V3) {
+	size := 1 << level
+	p0, _ := dc.corner(sdf.V3i{key[0] * size, key[1] * size, key[2] * size})
+	p1, _ := dc.corner(sdf.V3i{(key[0] + 1) * size, (key[1] + 1) * size, (key[2] + 1) * size})
+	return p0, p1
+}
+
+// vertex returns the vertex for a cluster.
+func (dc *dcache3) vertex(c *dcCluster) sdf.V3 {
+	v, _ := c.solve()
+	return v.Clamp(dc.cellBox(c.key, c.level))
+}
+
+// merge merges the clusters of an octree cell if a single vertex can
+// represent the surface within it.
+func (dc *dcache3) merge(key sdf.V3i, level uint, children []*dcCluster, maxError float64) *dcCluster {
+	c := &dcCluster{key: key, level: level}
+	for _, child := range children {
+		c.add(&child.qef)
+	}
+	if c.normal.Length() < dcNormalLimit*float64(c.n) {
+		// the surface is curved or has more than one sheet
+		return nil
+	}
+	v, err := c.solve()
+	if err > maxError*maxError {
+		return nil
+	}
+	// the vertex must be within the cell
+	if v != v.Clamp(dc.cellBox(key, level)) {
+		return nil
+	}
+	for _, child := range children {
+		child.parent = c
+	}
+	return c
+}
+
+// dcPolyKey returns a key for a polygon generated by a finest edge along an axis.
+// The finest edges along the edge of a merged cell generate the same polygon, with the
+// same vertex order. Polygons with the same vertices but a different order or winding
+// (e.g. the two faces of a thin sheet) have different keys.
+func dcPolyKey(axis int, poly []int) [5]int {
+	key := [5]int{axis, -1, -1, -1, -1}
+	// start the cycle at the lowest vertex index
+	first := 0
+	for i, j := range poly {
+		if j < poly[first] {
+			first = i
+		}
+	}
+	for i := range poly {
+		key[i+1] = poly[(first+i)%len(poly)]
+	}
+	return key
+}
+
+//-----------------------------------------------------------------------------
+
+// adaptiveDualContouring generates a triangle mesh for an SDF3 using
+// dual contouring with octree simplification.
+func adaptiveDualContouring(s sdf.SDF3, resolution float64, output chan<- *Triangle3, e *Engine, t *tracker) {
+	dc, cells := dcSurface(s, resolution, e, t)
+	if t.cancelled() {
+		return
+	}
+	maxError := dcAdaptiveError * resolution
+
+	// the QEFs of the finest cells
+	leaf := make([]*dcCluster, len(cells))
+	e.Run(len(cells), func(_, i int) {
+		leaf[i] = &dcCluster{qef: *dc.cellQEF(cells[i]), key: cells[i]}
+	})
+
+	// merge the clusters bottom up
+	current := make(map[sdf.V3i]*dcCluster, len(cells))
+	for i, c := range cells {
+		current[c] = leaf[i]
+	}
+	blocked := make(map[sdf.V3i]bool)
+	levels := uint(len(dc.hdiag)) - 1
+	for level := uint(1); level < levels && len(current) > 0; level++ {
+		children := make(map[sdf.V3i][]*dcCluster)
+		for key, c := range current {
+			k := dcKey(key, 1)
+			children[k] = append(children[k], c)
+		}
+		next := make(map[sdf.V3i]*dcCluster)
+		nextBlocked := make(map[sdf.V3i]bool)
+		for key := range blocked {
+			nextBlocked[dcKey(key, 1)] = true
+		}
+		for key, c := range children {
+			if nextBlocked[key] {
+				// part of this cell could not be merged
+				continue
+			}
+			if m := dc.merge(key, level, c, maxError); m != nil {
+				next[key] = m
+			} else {
+				nextBlocked[key] = true
+			}
+		}
+		current, blocked = next, nextBlocked
+	}
+
+	// work out the vertex for each cluster
+	var vertex []sdf.V3
+	clusters := make(map[*dcCluster]int)
+	index := make(map[sdf.V3i]int, len(cells))
+	for i, c := range cells {
+		r := leaf[i].root()
+		j, found := clusters[r]
+		if !found {
+			j = len(vertex)
+			vertex = append(vertex, dc.vertex(r))
+			clusters[r] = j
+		}
+		index[c] = j
+	}
+
+	// generate a polygon for each finest edge that crosses the surface
+	done := make(map[[5]int]bool)
+	for _, c := range cells {
+		for axis := range dcAxes {
+			quad, ok := dc.dcQuad(c, axis)
+			if !ok {
+				continue
+			}
+			// vertex indices with repeats removed
+			var poly []int
+			for _, k := range quad {
+				j, found := index[k]
+				if !found {
+					ok = false
+					break
+				}
+				if len(poly) == 0 || poly[len(poly)-1] != j {
+					poly = append(poly, j)
+				}
+			}
+			if len(poly) > 1 && poly[0] == poly[len(poly)-1] {
+				poly = poly[:len(poly)-1]
+			}
+			if !ok || len(poly) < 3 {
+				continue
+			}
+			// the same polygon is generated by each finest edge
+			// along the edge of a merged cell
+			key := dcPolyKey(axis, poly)
+			if done[key] {
+				continue
+			}
+			done[key] = true
+			if len(poly) == 3 {
+				output <- NewTriangle3(vertex[poly[0]], vertex[poly[1]], vertex[poly[2]])
+				t.addTriangles(1)
+			} else {
+				dcTriangles([4]sdf.V3{vertex[poly[0]], vertex[poly[1]], vertex[poly[2]], vertex[poly[3]]}, output, t)
+			}
+		}
+	}
+	t.flush()
+}
+
+//-----------------------------------------------------------------------------
@@ -133,7 +133,7 @@ func superTriangle(vs sdf.V2Set) (Triangle2, error) {
 			k = 1
 		}
 	} else {
-		b := sdf.Box2{vs.Min(), vs.Max()}
+		b := sdf.Box2{Min: vs.Min(), Max: vs.Max()}
 		p = b.Center()
 		k = b.Size().MaxComponent() * 2.0
 	}
@@ -143,9 +143,9 @@ func superTriangle(vs sdf.V2Set) (Triangle2, error) {
 	// on the hull the circumcenter is going to be arbitrarily far away.
 	k *= 4096.0
 
-	p0 := p.Add(sdf.V2{-k, -k})
-	p1 := p.Add(sdf.V2{0, k})
-	p2 := p.Add(sdf.V2{k, -k})
+	p0 := p.Add(sdf.V2{X: -k, Y: -k})
+	p1 := p.Add(sdf.V2{X: 0, Y: k})
+	p2 := p.Add(sdf.V2{X: k, Y: -k})
 	return Triangle2{p0, p1, p2}, nil
 }
 
@@ -200,7 +200,7 @@ func (t Triangle2) Circumcenter() (sdf.V2, error) {
 		}
 	}
 
-	return sdf.V2{xc, yc}, nil
+	return sdf.V2{X: xc, Y: yc}, nil
 }
 
 // InCircumcircle return inside == true if the point is inside the circumcircle of the triangle.
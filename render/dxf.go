@@ -11,6 +11,7 @@ package render
 import (
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/jakoblorz/sdfx/sdf"
@@ -56,6 +57,16 @@ func (d *DXF) Lines(s sdf.V2Set) {
 	}
 }
 
+// Contour adds a contour to a dxf drawing object as a LWPOLYLINE.
+func (d *DXF) Contour(c *Contour) {
+	d.drawing.ChangeLayer("Lines")
+	vertices := make([][]float64, len(c.Points))
+	for i, p := range c.Points {
+		vertices[i] = []float64{p.X, p.Y}
+	}
+	d.drawing.LwPolyline(c.Closed, vertices...)
+}
+
 // Points adds a set of points to a dxf drawing object.
 func (d *DXF) Points(s sdf.V2Set, r float64) {
 	d.drawing.ChangeLayer("Points")
@@ -69,6 +80,12 @@ func (d *DXF) Triangle(t Triangle2) {
 	d.Lines([]sdf.V2{t[0], t[1], t[2], t[0]})
 }
 
+// Write writes a dxf drawing object to a writer.
+func (d *DXF) Write(w io.Writer) error {
+	_, err := d.drawing.WriteTo(w)
+	return err
+}
+
 // Save writes a dxf drawing object to a file.
 func (d *DXF) Save() error {
 	err := d.drawing.SaveAs(d.name)
@@ -80,8 +97,8 @@ func (d *DXF) Save() error {
 
 //-----------------------------------------------------------------------------
 
-// SaveDXF writes line segments to a DXF file.
-func SaveDXF(path string, mesh []*Line) error {
+// newDXFLines returns a dxf drawing object with line segments.
+func newDXFLines(path string, mesh []*Line) *DXF {
 	d := NewDXF(path)
 	d.drawing.ChangeLayer("Lines")
 	for i := range mesh {
@@ -89,7 +106,21 @@ func SaveDXF(path string, mesh []*Line) error {
 		p1 := mesh[i][1]
 		d.drawing.Line(p0.X, p0.Y, 0, p1.X, p1.Y, 0)
 	}
-	err := d.Save()
+	return d
+}
+
+// newDXFContours returns a dxf drawing object with contours.
+func newDXFContours(path string, contours []*Contour) *DXF {
+	d := NewDXF(path)
+	for _, c := range contours {
+		d.Contour(c)
+	}
+	return d
+}
+
+// SaveDXF writes line segments to a DXF file.
+func SaveDXF(path string, mesh []*Line) error {
+	err := newDXFLines(path, mesh).Save()
 	if err != nil {
 		return err
 	}
@@ -128,35 +159,49 @@ func WriteDXF(wg *sync.WaitGroup, path string) (chan<- *Line, error) {
 
 //-----------------------------------------------------------------------------
 
+// ToDXF renders an SDF2 as a DXF file.
+func ToDXF(
+	s sdf.SDF2, //sdf2 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	path string, //path to filename
+	options ...RenderOption, //optional settings
+) error {
+	cfg := newRenderConfig(options)
+	resolution, cells := lineResolution(s, meshCells)
+	cfg.report("rendering %s (%dx%d, resolution %.2f)", path, cells[0], cells[1], resolution)
+	contours := renderContours(s, resolution, cfg)
+	if err := cfg.t.err(); err != nil {
+		return err
+	}
+	return newDXFContours(path, contours).Save()
+}
+
+// ToDXFWriter renders an SDF2 as DXF to a writer.
+func ToDXFWriter(
+	s sdf.SDF2, //sdf2 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	w io.Writer, //output writer
+	options ...RenderOption, //optional settings
+) error {
+	cfg := newRenderConfig(options)
+	resolution, cells := lineResolution(s, meshCells)
+	cfg.report("rendering dxf (%dx%d, resolution %.2f)", cells[0], cells[1], resolution)
+	contours := renderContours(s, resolution, cfg)
+	if err := cfg.t.err(); err != nil {
+		return err
+	}
+	return newDXFContours("", contours).Write(w)
+}
+
 // RenderDXF renders an SDF2 as a DXF file. (uses quadtree sampling)
 func RenderDXF(
 	s sdf.SDF2, //sdf2 to render
 	meshCells int, //number of cells on the longest axis. e.g 200
 	path string, //path to filename
 ) {
-
-	// work out the sampling resolution to use
-	bbSize := s.BoundingBox().Size()
-	resolution := bbSize.MaxComponent() / float64(meshCells)
-	cells := bbSize.DivScalar(resolution).ToV2i()
-
-	fmt.Printf("rendering %s (%dx%d, resolution %.2f)\n", path, cells[0], cells[1], resolution)
-
-	// write the line segments to a DXF file
-	var wg sync.WaitGroup
-	output, err := WriteDXF(&wg, path)
-	if err != nil {
-		fmt.Printf("%s", err)
-		return
+	if err := ToDXF(s, meshCells, path, OnProgress(printProgress)); err != nil {
+		fmt.Printf("%s\n", err)
 	}
-
-	// run marching squares to generate the line segments
-	marchingSquaresQuadtree(s, resolution, output)
-
-	// stop the DXF writer reading on the channel
-	close(output)
-	// wait for the file write to complete
-	wg.Wait()
 }
 
 // RenderDXFSlow renders an SDF2 as a DXF file. (uses uniform grid sampling)
@@ -178,8 +223,8 @@ func RenderDXFSlow(
 	fmt.Printf("rendering %s (%dx%d)\n", path, cells[0], cells[1])
 
 	// run marching squares to generate the line segments
-	m := marchingSquares(s, bb, meshInc)
-	err := SaveDXF(path, m)
+	m := marchingSquares(s, bb, meshInc, nil)
+	err := newDXFContours(path, StitchLines(m, meshInc*stitchTolerance)).Save()
 	if err != nil {
 		fmt.Printf("%s", err)
 	}
@@ -0,0 +1,324 @@
+//-----------------------------------------------------------------------------
+/*
+
+Evaluation Engine
+
+A persistent pool of workers for evaluating SDFs in parallel.
+
+Jobs are split into indexed tasks. The workers (and the caller) take tasks
+from a shared counter, so the load is balanced without a goroutine per task.
+The caller works on its own job while it waits, so jobs can be nested (a task
+may start another job) without deadlocking the pool.
+
+The distance cache used by the octree and quadtree meshers is sharded by key
+and lock-free, so concurrent readers and writers don't wait on each other.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// evalBatch is the number of points evaluated per task.
+const evalBatch = 256
+
+// cacheShards is the number of distance cache shards (a power of 2).
+const cacheShards = 64
+
+//-----------------------------------------------------------------------------
+
+// Engine is a pool of workers for evaluating SDFs in parallel.
+// It is safe for concurrent use and can be shared by many renderings.
+type Engine struct {
+	workers int
+	jobs    chan func(worker int)
+	done    chan struct{} // closed to stop the workers
+	closed  int32         // the engine has been closed (atomic)
+	close   sync.Once
+}
+
+// NewEngine returns an evaluation engine with the given number of workers.
+// Use workers <= 0 for a worker per cpu.
+func NewEngine(workers int) *Engine {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	e := &Engine{
+		workers: workers,
+		jobs:    make(chan func(worker int), workers),
+		done:    make(chan struct{}),
+	}
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			for {
+				select {
+				case job := <-e.jobs:
+					job(w)
+				case <-e.done:
+					return
+				}
+			}
+		}(w)
+	}
+	return e
+}
+
+var (
+	engineLock sync.Mutex
+	engines    = make(map[int]*Engine)
+)
+
+// sharedEngine returns a persistent engine with the given number of workers.
+func sharedEngine(workers int) *Engine {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	engineLock.Lock()
+	defer engineLock.Unlock()
+	e, ok := engines[workers]
+	if !ok {
+		e = NewEngine(workers)
+		engines[workers] = e
+	}
+	return e
+}
+
+// get returns the engine, or the default engine if e is nil.
+func (e *Engine) get() *Engine {
+	if e == nil {
+		return sharedEngine(0)
+	}
+	return e
+}
+
+// Workers returns the number of workers.
+func (e *Engine) Workers() int {
+	return e.get().workers
+}
+
+// Close stops the workers of an engine created with NewEngine.
+// Run can still be called after Close, the tasks are then run by the caller.
+func (e *Engine) Close() {
+	if e == nil {
+		return
+	}
+	e.close.Do(func() {
+		atomic.StoreInt32(&e.closed, 1)
+		close(e.done)
+	})
+}
+
+// Run calls fn(worker, i) for i = 0 to n-1 in parallel and waits for the calls to finish.
+// The worker index is in [0, Workers()] and is unique amongst the goroutines running
+// the calls, so it can be used to select per-worker buffers.
+func (e *Engine) Run(n int, fn func(worker, i int)) {
+	e = e.get()
+	if n <= 0 {
+		return
+	}
+	var next, workers int64
+	var wg sync.WaitGroup
+	wg.Add(n)
+	work := func(int) {
+		w := int(atomic.AddInt64(&workers, 1) - 1)
+		for {
+			i := int(atomic.AddInt64(&next, 1) - 1)
+			if i >= n {
+				return
+			}
+			fn(w, i)
+			wg.Done()
+		}
+	}
+	// hand the job to idle workers
+	helpers := e.workers
+	if n-1 < helpers {
+		helpers = n - 1
+	}
+	if atomic.LoadInt32(&e.closed) != 0 {
+		// there are no workers
+		helpers = 0
+	}
+submit:
+	for k := 0; k < helpers; k++ {
+		select {
+		case e.jobs <- work:
+		default:
+			// the workers are busy
+			break submit
+		}
+	}
+	// the caller works until the tasks have all been taken
+	work(0)
+	wg.Wait()
+}
+
+// Evaluate3 evaluates an SDF3 at a set of points.
+// The batch evaluator of the SDF3 is used if it has one.
+func (e *Engine) Evaluate3(s sdf.SDF3, p []sdf.V3, out []float64) {
+	n := (len(p) + evalBatch - 1) / evalBatch
+	e.Run(n, func(_, i int) {
+		j0 := i * evalBatch
+		j1 := j0 + evalBatch
+		if j1 > len(p) {
+			j1 = len(p)
+		}
+		sdf.Evaluate3N(s, p[j0:j1], out[j0:j1])
+	})
+}
+
+// Evaluate2 evaluates an SDF2 at a set of points.
+// The batch evaluator of the SDF2 is used if it has one.
+func (e *Engine) Evaluate2(s sdf.SDF2, p []sdf.V2, out []float64) {
+	n := (len(p) + evalBatch - 1) / evalBatch
+	e.Run(n, func(_, i int) {
+		j0 := i * evalBatch
+		j1 := j0 + evalBatch
+		if j1 > len(p) {
+			j1 = len(p)
+		}
+		sdf.Evaluate2N(s, p[j0:j1], out[j0:j1])
+	})
+}
+
+//-----------------------------------------------------------------------------
+
+// distCache is a lock-free concurrent cache of distances at (non-negative)
+// integer grid positions. Each shard is an open addressing hash table. A shard
+// that fills up gets a larger table chained after it, entries are never moved
+// or removed, so readers and writers only use atomic operations.
+type distCache struct {
+	shards [cacheShards]cacheShard
+}
+
+// cacheLine is the cache line size that shards are padded to.
+const cacheLine = 64
+
+// cacheShard is a shard of a distance cache.
+type cacheShard struct {
+	table *cacheTable
+	_     [cacheLine - unsafe.Sizeof((*cacheTable)(nil))]byte // keep shards on separate cache lines
+}
+
+// cacheTable is a hash table of cached distances.
+type cacheTable struct {
+	slots []cacheSlot
+	mask  uint64
+	next  unsafe.Pointer // *cacheTable, the next (larger) table
+}
+
+// cacheSlot is a cached distance.
+type cacheSlot struct {
+	key   uint64 // packed key + 1, 0 for an empty slot (atomic)
+	value uint64 // distance bits (atomic)
+	ok    uint32 // the value has been written (atomic)
+	_     uint32 // 24 bytes, so key and value are 64-bit aligned in every slot on 32-bit platforms
+}
+
+// cacheSize is the initial number of slots in a cache shard.
+const cacheSize = 1024
+
+// cacheProbes is the number of slots probed for a key in each table.
+const cacheProbes = 16
+
+func newCacheTable(size int) *cacheTable {
+	return &cacheTable{
+		slots: make([]cacheSlot, size),
+		mask:  uint64(size - 1),
+	}
+}
+
+func newDistCache() *distCache {
+	c := &distCache{}
+	for i := range c.shards {
+		c.shards[i].table = newCacheTable(cacheSize)
+	}
+	return c
+}
+
+// key3 returns the cache key for a 3d grid position (components < 2^21).
+func key3(k sdf.V3i) uint64 {
+	const m = 1<<21 - 1
+	return uint64(k[0])&m | (uint64(k[1])&m)<<21 | (uint64(k[2])&m)<<42
+}
+
+// key2 returns the cache key for a 2d grid position (components < 2^31).
+func key2(k sdf.V2i) uint64 {
+	const m = 1<<31 - 1
+	return uint64(k[0])&m | (uint64(k[1])&m)<<31
+}
+
+// hash mixes the bits of a key (splitmix64 finalizer).
+func hash(k uint64) uint64 {
+	k = (k ^ (k >> 30)) * 0xbf58476d1ce4e5b9
+	k = (k ^ (k >> 27)) * 0x94d049bb133111eb
+	return k ^ (k >> 31)
+}
+
+// read returns the cached distance for a key.
+func (c *distCache) read(key uint64) (float64, bool) {
+	h := hash(key)
+	key++
+	for t := c.shards[h>>58].table; t != nil; t = (*cacheTable)(atomic.LoadPointer(&t.next)) {
+		for i := uint64(0); i < cacheProbes; i++ {
+			slot := &t.slots[(h+i)&t.mask]
+			switch atomic.LoadUint64(&slot.key) {
+			case key:
+				if atomic.LoadUint32(&slot.ok) == 0 {
+					// being written
+					return 0, false
+				}
+				return math.Float64frombits(atomic.LoadUint64(&slot.value)), true
+			case 0:
+				// slots are filled in probe order, the key isn't cached
+				return 0, false
+			}
+		}
+	}
+	return 0, false
+}
+
+// write caches the distance for a key.
+func (c *distCache) write(key uint64, d float64) {
+	h := hash(key)
+	key++
+	t := c.shards[h>>58].table
+	for {
+		for i := uint64(0); i < cacheProbes; i++ {
+			slot := &t.slots[(h+i)&t.mask]
+			k := atomic.LoadUint64(&slot.key)
+			if k == 0 && atomic.CompareAndSwapUint64(&slot.key, 0, key) {
+				k = key
+			}
+			if k == key {
+				atomic.StoreUint64(&slot.value, math.Float64bits(d))
+				atomic.StoreUint32(&slot.ok, 1)
+				return
+			}
+		}
+		// the probed slots are full, use the next table
+		next := atomic.LoadPointer(&t.next)
+		if next == nil {
+			n := newCacheTable(4 * len(t.slots))
+			if atomic.CompareAndSwapPointer(&t.next, nil, unsafe.Pointer(n)) {
+				next = unsafe.Pointer(n)
+			} else {
+				next = atomic.LoadPointer(&t.next)
+			}
+		}
+		t = (*cacheTable)(next)
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,352 @@
+//-----------------------------------------------------------------------------
+/*
+
+G-Code Toolpath Generation
+
+Generate 2.5D profile cutting toolpaths for an SDF2.
+
+The SDF2 is offset by the tool radius (Offset2D) so the tool edge follows the
+profile, the offset contours are extracted and each contour is cut in one or
+more passes down to the final depth. Tabs leave bridges of material on the final
+passes so parts stay attached to the stock.
+
+Coordinates are absolute, in millimeters, with Z = 0 at the top of the stock.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// CutSide is the side of the profile the tool cuts on.
+type CutSide int
+
+// Cut sides.
+const (
+	Outside CutSide = iota // cut outside the profile, the SDF2 is the part
+	Inside                 // cut inside the profile, the SDF2 is removed material
+	OnLine                 // cut along the profile
+)
+
+// GCodeParms defines the parameters for a G-code toolpath.
+type GCodeParms struct {
+	ToolDiameter float64 // cutting tool diameter
+	Side         CutSide // side of the profile to cut on
+	Depth        float64 // total depth of the cut (> 0)
+	StepDown     float64 // maximum depth per pass, 0 for a single pass
+	SafeZ        float64 // height for rapid moves (> 0)
+	FeedRate     float64 // cutting feed rate (mm/min)
+	PlungeRate   float64 // plunge feed rate (mm/min), 0 to use the feed rate
+	SpindleSpeed float64 // spindle speed (rpm), 0 for no spindle commands
+	Climb        bool    // climb milling (the default is conventional milling)
+	Tabs         int     // number of tabs per outer contour
+	TabWidth     float64 // width of the tabs
+	TabHeight    float64 // height of the tabs above the final depth
+}
+
+// validate checks the G-code parameters.
+func (k *GCodeParms) validate() error {
+	if k.ToolDiameter <= 0 {
+		return errors.New("ToolDiameter <= 0")
+	}
+	if k.Depth <= 0 {
+		return errors.New("Depth <= 0")
+	}
+	if k.StepDown < 0 {
+		return errors.New("StepDown < 0")
+	}
+	if k.SafeZ <= 0 {
+		return errors.New("SafeZ <= 0")
+	}
+	if k.FeedRate <= 0 {
+		return errors.New("FeedRate <= 0")
+	}
+	if k.PlungeRate < 0 {
+		return errors.New("PlungeRate < 0")
+	}
+	if k.Tabs < 0 {
+		return errors.New("Tabs < 0")
+	}
+	if k.Tabs > 0 && (k.TabWidth <= 0 || k.TabHeight <= 0 || k.TabHeight >= k.Depth) {
+		return errors.New("bad tab size")
+	}
+	return nil
+}
+
+// offset returns the profile offset for the tool radius.
+func (k *GCodeParms) offset() float64 {
+	switch k.Side {
+	case Inside:
+		return -0.5 * k.ToolDiameter
+	case OnLine:
+		return 0
+	}
+	return 0.5 * k.ToolDiameter
+}
+
+// passes returns the Z levels for each pass.
+func (k *GCodeParms) passes() []float64 {
+	n := 1
+	if k.StepDown > 0 {
+		n = int(math.Ceil(k.Depth/k.StepDown - epsilon))
+	}
+	z := make([]float64, n)
+	for i := range z {
+		z[i] = -k.Depth * float64(i+1) / float64(n)
+	}
+	return z
+}
+
+//-----------------------------------------------------------------------------
+
+// gcode writes G-code commands.
+type gcode struct {
+	w       *errWriter
+	k       *GCodeParms
+	feed    float64 // current feed rate
+	x, y, z float64 // current position
+}
+
+// move writes a G0 (rapid) or G1 (feed) move.
+func (g *gcode) move(rapid bool, p sdf.V3, feed float64) {
+	cmd := "G1"
+	if rapid {
+		cmd = "G0"
+	}
+	s := cmd
+	if p.X != g.x || p.Y != g.y {
+		s += fmt.Sprintf(" X%.4f Y%.4f", p.X, p.Y)
+	}
+	if p.Z != g.z {
+		s += fmt.Sprintf(" Z%.4f", p.Z)
+	}
+	if s == cmd {
+		return
+	}
+	if !rapid && feed != g.feed {
+		s += fmt.Sprintf(" F%.1f", feed)
+		g.feed = feed
+	}
+	fmt.Fprintln(g.w, s)
+	g.x, g.y, g.z = p.X, p.Y, p.Z
+}
+
+// retract moves the tool up to the safe height.
+func (g *gcode) retract() {
+	if g.z != g.k.SafeZ {
+		fmt.Fprintf(g.w, "G0 Z%.4f\n", g.k.SafeZ)
+		g.z = g.k.SafeZ
+	}
+}
+
+// header writes the program setup.
+func (g *gcode) header() {
+	k := g.k
+	fmt.Fprintf(g.w, "(tool diameter %.4f, depth %.4f)\n", k.ToolDiameter, k.Depth)
+	fmt.Fprintln(g.w, "G21 (millimeters)")
+	fmt.Fprintln(g.w, "G90 (absolute coordinates)")
+	fmt.Fprintln(g.w, "G17 (xy plane)")
+	// the xy position is unknown until the first move
+	g.x, g.y = math.NaN(), math.NaN()
+	g.z = math.NaN()
+	g.retract()
+	if k.SpindleSpeed > 0 {
+		fmt.Fprintf(g.w, "M3 S%.0f\n", k.SpindleSpeed)
+	}
+}
+
+// footer writes the program end.
+func (g *gcode) footer() {
+	g.retract()
+	if g.k.SpindleSpeed > 0 {
+		fmt.Fprintln(g.w, "M5")
+	}
+	fmt.Fprintln(g.w, "M2")
+}
+
+// tabIntervals returns the path length intervals covered by tabs on a closed contour.
+func (g *gcode) tabIntervals(length float64) [][2]float64 {
+	k := g.k
+	w := 0.5 * (k.TabWidth + k.ToolDiameter)
+	var tabs [][2]float64
+	for i := 0; i < k.Tabs; i++ {
+		d := length * (float64(i) + 0.5) / float64(k.Tabs)
+		if d-w > 0 && d+w < length {
+			tabs = append(tabs, [2]float64{d - w, d + w})
+		}
+	}
+	return tabs
+}
+
+// pass cuts a contour at depth z, raising the tool to tabZ over the tabs.
+func (g *gcode) pass(points []sdf.V2, z, tabZ float64, tabs [][2]float64) {
+	feed := g.k.FeedRate
+	plunge := g.k.PlungeRate
+	if plunge == 0 {
+		plunge = feed
+	}
+	g.move(false, sdf.V3{X: points[0].X, Y: points[0].Y, Z: z}, plunge)
+	var d float64
+	t := 0
+	for i := 1; i < len(points); i++ {
+		p0, p1 := points[i-1], points[i]
+		l := p1.Sub(p0).Length()
+		// tab boundaries on this segment
+		for t < 2*len(tabs) {
+			dt := tabs[t/2][t%2]
+			if dt > d+l {
+				break
+			}
+			p := p0.Add(p1.Sub(p0).MulScalar((dt - d) / l))
+			g.move(false, sdf.V3{X: p.X, Y: p.Y, Z: g.z}, feed)
+			if t%2 == 0 {
+				g.move(false, sdf.V3{X: p.X, Y: p.Y, Z: tabZ}, feed)
+			} else {
+				g.move(false, sdf.V3{X: p.X, Y: p.Y, Z: z}, plunge)
+			}
+			t++
+		}
+		g.move(false, sdf.V3{X: p1.X, Y: p1.Y, Z: g.z}, feed)
+		d += l
+	}
+}
+
+// contour cuts a contour in multiple passes.
+func (g *gcode) contour(c *Contour, tabbed bool) {
+	k := g.k
+	points := c.Points
+	if c.Closed {
+		points = append(append([]sdf.V2{}, points...), points[0])
+	}
+	var length float64
+	for i := 1; i < len(points); i++ {
+		length += points[i].Sub(points[i-1]).Length()
+	}
+	var tabs [][2]float64
+	if tabbed && c.Closed && k.Tabs > 0 {
+		tabs = g.tabIntervals(length)
+	}
+	tabZ := k.TabHeight - k.Depth
+	p0 := points[0]
+	g.retract()
+	g.move(true, sdf.V3{X: p0.X, Y: p0.Y, Z: k.SafeZ}, 0)
+	for _, z := range k.passes() {
+		if !c.Closed {
+			// return to the start of an open contour
+			g.retract()
+			g.move(true, sdf.V3{X: p0.X, Y: p0.Y, Z: k.SafeZ}, 0)
+		}
+		if z >= tabZ {
+			g.pass(points, z, tabZ, nil)
+		} else {
+			g.pass(points, z, tabZ, tabs)
+		}
+	}
+	g.retract()
+}
+
+// toolpath writes the G-code for a set of tool center contours.
+func (g *gcode) toolpath(contours []*Contour) {
+	k := g.k
+	// cut the inner (smaller) contours first so the part stays attached to the stock
+	sort.SliceStable(contours, func(i, j int) bool {
+		return math.Abs(contours[i].Area()) < math.Abs(contours[j].Area())
+	})
+	g.header()
+	for _, c := range contours {
+		if len(c.Points) < 2 {
+			continue
+		}
+		// tabs hold the regions that are cut free
+		tabbed := !c.Hole()
+		// The contours have the offset solid on their left hand side.
+		// For Outside cuts this is the part, for Inside cuts it is the removed material.
+		// Climb milling (M3 spindle) keeps the part on the right hand side.
+		if k.Climb != (k.Side == Inside) {
+			c.Reverse()
+		}
+		g.contour(c, tabbed)
+	}
+	g.footer()
+}
+
+//-----------------------------------------------------------------------------
+
+// writeGCode renders the toolpath for an SDF2 as G-code to a writer.
+func writeGCode(s sdf.SDF2, meshCells int, w io.Writer, k *GCodeParms, cfg *renderConfig) error {
+	if err := k.validate(); err != nil {
+		return err
+	}
+	s = sdf.Offset2D(s, k.offset())
+	resolution, cells := lineResolution(s, meshCells)
+	cfg.report("rendering gcode (%dx%d, resolution %.2f)", cells[0], cells[1], resolution)
+	contours := renderContours(s, resolution, cfg)
+	if err := cfg.t.err(); err != nil {
+		return err
+	}
+	if len(contours) == 0 {
+		return errors.New("no contours")
+	}
+	g := &gcode{w: &errWriter{w: w}, k: k}
+	g.toolpath(contours)
+	return g.w.err
+}
+
+// ToGCode renders the toolpath for an SDF2 as a G-code file.
+func ToGCode(
+	s sdf.SDF2, // sdf2 to cut
+	meshCells int, // number of cells on the longest axis. e.g 200
+	path string, // path to filename
+	k *GCodeParms, // toolpath parameters
+	options ...RenderOption, // optional settings
+) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = writeGCode(s, meshCells, f, k, newRenderConfig(options))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// ToGCodeWriter renders the toolpath for an SDF2 as G-code to a writer.
+func ToGCodeWriter(
+	s sdf.SDF2, // sdf2 to cut
+	meshCells int, // number of cells on the longest axis. e.g 200
+	w io.Writer, // output writer
+	k *GCodeParms, // toolpath parameters
+	options ...RenderOption, // optional settings
+) error {
+	return writeGCode(s, meshCells, w, k, newRenderConfig(options))
+}
+
+// RenderGCode renders the toolpath for an SDF2 as a G-code file.
+// The contours are simplified to a tenth of the sampling resolution.
+func RenderGCode(
+	s sdf.SDF2, // sdf2 to cut
+	meshCells int, // number of cells on the longest axis. e.g 200
+	path string, // path to filename
+	k *GCodeParms, // toolpath parameters
+) error {
+	resolution, _ := lineResolution(s, meshCells)
+	return ToGCode(s, meshCells, path, k, OnProgress(printProgress), SimplifyContours(0.1*resolution))
+}
+
+//-----------------------------------------------------------------------------
@@ -25,11 +25,13 @@ type lineCache struct {
 	steps sdf.V2i   // number of x,y steps
 	val0  []float64 // SDF values for x line
 	val1  []float64 // SDF values for x + dx line
+	p     []sdf.V2  // evaluation points for a line
+	e     *Engine   // evaluation engine
 }
 
 // newLineCache returns a line cache.
-func newLineCache(base, inc sdf.V2, steps sdf.V2i) *lineCache {
-	return &lineCache{base, inc, steps, nil, nil}
+func newLineCache(base, inc sdf.V2, steps sdf.V2i, e *Engine) *lineCache {
+	return &lineCache{base: base, inc: inc, steps: steps, e: e}
 }
 
 // evaluate the SDF2 over a given x line.
@@ -45,19 +47,21 @@ func (l *lineCache) evaluate(s sdf.SDF2, x int) {
 	if l.val1 == nil {
 		l.val1 = make([]float64, ny+1)
 	}
+	if l.p == nil {
+		l.p = make([]sdf.V2, ny+1)
+	}
 
-	// setup the loop variables
-	idx := 0
+	// setup the line points
 	var p sdf.V2
 	p.X = l.base.X + float64(x)*dx
-
-	// evaluate the line
 	p.Y = l.base.Y
 	for y := 0; y < ny+1; y++ {
-		l.val1[idx] = s.Evaluate(p)
-		idx++
+		l.p[y] = p
 		p.Y += dy
 	}
+
+	// evaluate the line
+	l.e.Evaluate2(s, l.p, l.val1)
 }
 
 // get a value from a line cache.
@@ -70,7 +74,9 @@ func (l *lineCache) get(x, y int) float64 {
 
 //-----------------------------------------------------------------------------
 
-func marchingSquares(s sdf.SDF2, box sdf.Box2, step float64) []*Line {
+// marchingSquares generates line segments for an SDF2 using uniform grid sampling.
+// The SDF2 is evaluated by the engine (nil for the default engine).
+func marchingSquares(s sdf.SDF2, box sdf.Box2, step float64, e *Engine) []*Line {
 
 	var lines []*Line
 	size := box.Size()
@@ -79,7 +85,7 @@ func marchingSquares(s sdf.SDF2, box sdf.Box2, step float64) []*Line {
 	inc := size.Div(steps.ToV2())
 
 	// create the line cache
-	l := newLineCache(base, inc, steps)
+	l := newLineCache(base, inc, steps, e)
 	// evaluate the SDF for x = 0
 	l.evaluate(s, 0)
 
@@ -97,10 +103,10 @@ func marchingSquares(s sdf.SDF2, box sdf.Box2, step float64) []*Line {
 			x0, y0 := p.X, p.Y
 			x1, y1 := x0+dx, y0+dy
 			corners := [4]sdf.V2{
-				{x0, y0},
-				{x1, y0},
-				{x1, y1},
-				{x0, y1},
+				{X: x0, Y: y0},
+				{X: x1, Y: y0},
+				{X: x1, Y: y1},
+				{X: x0, Y: y1},
 			}
 			values := [4]float64{
 				l.get(0, y),
@@ -151,12 +157,32 @@ func msToLines(p [4]sdf.V2, v [4]float64, x float64) []*Line {
 		l[1] = points[table[i*2+0]]
 		l[0] = points[table[i*2+1]]
 		if !l.Degenerate(0) {
+			msOrient(&l, p, v, x, table[i*2+0], table[i*2+1])
 			result = append(result, &l)
 		}
 	}
 	return result
 }
 
+// msOrient orients a line segment so the inside (v < x) is on its right hand side.
+// Each edge crossed by the line has an inside corner on the inside of the line.
+func msOrient(l *Line, p [4]sdf.V2, v [4]float64, x float64, e0, e1 int) {
+	dir := l[1].Sub(l[0])
+	var side float64
+	for _, e := range []int{e0, e1} {
+		k := msPairTable[e][0]
+		if v[k] >= x {
+			k = msPairTable[e][1]
+		}
+		if s := dir.Cross(p[k].Sub(l[0])); math.Abs(s) > math.Abs(side) {
+			side = s
+		}
+	}
+	if side > 0 {
+		l[0], l[1] = l[1], l[0]
+	}
+}
+
 //-----------------------------------------------------------------------------
 
 func msInterpolate(p1, p2 sdf.V2, v1, v2, x float64) sdf.V2 {
@@ -182,8 +208,8 @@ func msInterpolate(p1, p2 sdf.V2, v1, v2, x float64) sdf.V2 {
 	}
 
 	return sdf.V2{
-		p1.X + t*(p2.X-p1.X),
-		p1.Y + t*(p2.Y-p1.Y),
+		X: p1.X + t*(p2.X-p1.X),
+		Y: p1.Y + t*(p2.Y-p1.Y),
 	}
 }
 
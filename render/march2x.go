@@ -13,7 +13,6 @@ package render
 
 import (
 	"math"
-	"sync"
 
 	"github.com/jakoblorz/sdfx/sdf"
 )
@@ -29,12 +28,12 @@ type square struct {
 // Evaluate the SDF2 via a distance cache to avoid repeated evaluations.
 
 type dcache2 struct {
-	origin     sdf.V2              // origin of the overall bounding square
-	resolution float64             // size of smallest quadtree square
-	hdiag      []float64           // lookup table of square half diagonals
-	s          sdf.SDF2            // the SDF2 to be rendered
-	cache      map[sdf.V2i]float64 // cache of distances
-	lock       sync.RWMutex        // lock the the cache during reads/writes
+	origin     sdf.V2     // origin of the overall bounding square
+	resolution float64    // size of smallest quadtree square
+	hdiag      []float64  // lookup table of square half diagonals
+	s          sdf.SDF2   // the SDF2 to be rendered
+	cache      *distCache // cache of distances
+	t          *tracker   // progress tracker
 }
 
 func newDcache2(s sdf.SDF2, origin sdf.V2, resolution float64, n uint) *dcache2 {
@@ -43,7 +42,7 @@ func newDcache2(s sdf.SDF2, origin sdf.V2, resolution float64, n uint) *dcache2
 		resolution: resolution,
 		hdiag:      make([]float64, n),
 		s:          s,
-		cache:      make(map[sdf.V2i]float64),
+		cache:      newDistCache(),
 	}
 	// build a lut for cube half diagonal lengths
 	for i := range dc.hdiag {
@@ -56,17 +55,12 @@ func newDcache2(s sdf.SDF2, origin sdf.V2, resolution float64, n uint) *dcache2
 
 // read from the cache
 func (dc *dcache2) read(vi sdf.V2i) (float64, bool) {
-	dc.lock.RLock()
-	dist, found := dc.cache[vi]
-	dc.lock.RUnlock()
-	return dist, found
+	return dc.cache.read(key2(vi))
 }
 
 // write to the cache
 func (dc *dcache2) write(vi sdf.V2i, dist float64) {
-	dc.lock.Lock()
-	dc.cache[vi] = dist
-	dc.lock.Unlock()
+	dc.cache.write(key2(vi), dist)
 }
 
 func (dc *dcache2) evaluate(vi sdf.V2i) (sdf.V2, float64) {
@@ -83,8 +77,51 @@ func (dc *dcache2) evaluate(vi sdf.V2i) (sdf.V2, float64) {
 	return v, dist
 }
 
+// cells returns the number of cells (at the meshing resolution) in a square.
+func (c *square) cells() int64 {
+	return 1 << (2 * (c.n - 1))
+}
+
+// children returns the 4 sub squares of a square.
+func (c *square) children() []*square {
+	n := c.n - 1
+	s := 1 << n
+	return []*square{
+		{c.v.Add(sdf.V2i{0, 0}), n},
+		{c.v.Add(sdf.V2i{s, 0}), n},
+		{c.v.Add(sdf.V2i{s, s}), n},
+		{c.v.Add(sdf.V2i{0, s}), n},
+	}
+}
+
+// split subdivides a square until there are at least n non-empty sub squares
+// (or the squares are at the required resolution). The sub squares can then be
+// processed in parallel.
+func (dc *dcache2) split(c *square, n int) []*square {
+	squares := []*square{c}
+	for len(squares) > 0 && len(squares) < n && squares[0].n > 1 {
+		var next []*square
+		for _, c := range squares {
+			if dc.isEmpty(c) {
+				dc.t.addCells(c.cells())
+				continue
+			}
+			next = append(next, c.children()...)
+		}
+		squares = next
+	}
+	return squares
+}
+
 // isEmpty returns true if the square contains no SDF surface
 func (dc *dcache2) isEmpty(c *square) bool {
+	if _, ok := dc.s.(sdf.IntervalEvaluator2); ok {
+		// bound the distance over the square
+		min := dc.origin.Add(c.v.ToV2().MulScalar(dc.resolution))
+		max := dc.origin.Add(c.v.AddScalar(1 << c.n).ToV2().MulScalar(dc.resolution))
+		lo, hi := sdf.EvaluateInterval2(dc.s, sdf.Box2{Min: min, Max: max})
+		return lo >= 0 || hi <= 0
+	}
 	// evaluate the SDF2 at the center of the square
 	s := 1 << (c.n - 1) // half side
 	_, d := dc.evaluate(c.v.AddScalar(s))
@@ -94,6 +131,9 @@ func (dc *dcache2) isEmpty(c *square) bool {
 
 // Process a square. Generate line segments, or more squares.
 func (dc *dcache2) processSquare(c *square, output chan<- *Line) {
+	if c.n > 1 && dc.t.cancelled() {
+		return
+	}
 	if !dc.isEmpty(c) {
 		if c.n == 1 {
 			// this square is at the required resolution
@@ -107,23 +147,24 @@ func (dc *dcache2) processSquare(c *square, output chan<- *Line) {
 			for _, l := range msToLines(corners, values, 0) {
 				output <- l
 			}
+			dc.t.addCells(1)
 		} else {
 			// process the sub squares
-			n := c.n - 1
-			s := 1 << n
-			// TODO - turn these into throttled go-routines
-			dc.processSquare(&square{c.v.Add(sdf.V2i{0, 0}), n}, output)
-			dc.processSquare(&square{c.v.Add(sdf.V2i{s, 0}), n}, output)
-			dc.processSquare(&square{c.v.Add(sdf.V2i{s, s}), n}, output)
-			dc.processSquare(&square{c.v.Add(sdf.V2i{0, s}), n}, output)
+			for _, sc := range c.children() {
+				dc.processSquare(sc, output)
+			}
 		}
+	} else {
+		dc.t.addCells(c.cells())
 	}
 }
 
 //-----------------------------------------------------------------------------
 
 // marchingSquaresQuadtree generates line segments for an SDF2 using quadtree subdivision.
-func marchingSquaresQuadtree(s sdf.SDF2, resolution float64, output chan<- *Line) {
+// The upper levels of the quadtree are split into sub squares that are processed in parallel.
+// Cancellation and progress are handled by the tracker.
+func marchingSquaresQuadtree(s sdf.SDF2, resolution float64, output chan<- *Line, e *Engine, t *tracker) {
 	// Scale the bounding box about the center to make sure the boundaries
 	// aren't on the object surface.
 	bb := s.BoundingBox()
@@ -136,8 +177,42 @@ func marchingSquaresQuadtree(s sdf.SDF2, resolution float64, output chan<- *Line
 	levels := uint(math.Ceil(math.Log2(longAxis/resolution))) + 1
 	// create the distance cache
 	dc := newDcache2(s, bb.Min, resolution, levels)
+	dc.t = t
 	// process the quadtree, start at the top level
-	dc.processSquare(&square{sdf.V2i{0, 0}, levels - 1}, output)
+	top := &square{sdf.V2i{0, 0}, levels - 1}
+	t.setTotal(top.cells())
+	squares := dc.split(top, 4*e.Workers())
+	e.Run(len(squares), func(_, i int) {
+		dc.processSquare(squares[i], output)
+	})
+	t.flush()
+}
+
+//-----------------------------------------------------------------------------
+
+// lineResolution returns the sampling resolution for an SDF2.
+func lineResolution(s sdf.SDF2, meshCells int) (float64, sdf.V2i) {
+	bbSize := s.BoundingBox().Size()
+	resolution := bbSize.MaxComponent() / float64(meshCells)
+	return resolution, bbSize.DivScalar(resolution).ToV2i()
+}
+
+// renderLines returns the line segments for an SDF2 (uses quadtree sampling).
+// The SDF2 is evaluated by the engine (nil for the default engine).
+func renderLines(s sdf.SDF2, resolution float64, e *Engine, t *tracker) []*Line {
+	var lines []*Line
+	c := make(chan *Line)
+	done := make(chan bool)
+	go func() {
+		for l := range c {
+			lines = append(lines, l)
+		}
+		done <- true
+	}()
+	marchingSquaresQuadtree(s, resolution, c, e, t)
+	close(c)
+	<-done
+	return lines
 }
 
 //-----------------------------------------------------------------------------
@@ -12,8 +12,6 @@ package render
 
 import (
 	"math"
-	"runtime"
-	"sync"
 
 	"github.com/jakoblorz/sdfx/sdf"
 )
@@ -26,38 +24,12 @@ type layerYZ struct {
 	steps sdf.V3i   // number of x,y,z steps
 	val0  []float64 // SDF values for x layer
 	val1  []float64 // SDF values for x + dx layer
+	p     []sdf.V3  // evaluation points for a layer
+	e     *Engine   // evaluation engine
 }
 
-func newLayerYZ(base, inc sdf.V3, steps sdf.V3i) *layerYZ {
-	return &layerYZ{base, inc, steps, nil, nil}
-}
-
-// evalReq is used for processing evaluations in parallel.
-//
-// A slice of V3 is run through `fn`; the result of which
-// is stored in the corresponding index of the `out` slice.
-type evalReq struct {
-	out []float64
-	p   []sdf.V3
-	fn  func(sdf.V3) float64
-	wg  *sync.WaitGroup
-}
-
-var evalProcessCh = make(chan evalReq, 100)
-
-func init() {
-	for i := 0; i < runtime.NumCPU(); i++ {
-		go func() {
-			var i int
-			var p sdf.V3
-			for r := range evalProcessCh {
-				for i, p = range r.p {
-					r.out[i] = r.fn(p)
-				}
-				r.wg.Done()
-			}
-		}()
-	}
+func newLayerYZ(base, inc sdf.V3, steps sdf.V3i, e *Engine) *layerYZ {
+	return &layerYZ{base: base, inc: inc, steps: steps, e: e}
 }
 
 // Evaluate the SDF for a given XY layer
@@ -73,50 +45,27 @@ func (l *layerYZ) Evaluate(s sdf.SDF3, x int) {
 	if l.val1 == nil {
 		l.val1 = make([]float64, (ny+1)*(nz+1))
 	}
+	if l.p == nil {
+		l.p = make([]sdf.V3, (ny+1)*(nz+1))
+	}
 
-	// setup the loop variables
+	// setup the layer points
 	idx := 0
 	var p sdf.V3
 	p.X = l.base.X + float64(x)*dx
-
-	// define the base struct for requesting evaluation
-	eReq := evalReq{
-		wg:  new(sync.WaitGroup),
-		fn:  s.Evaluate,
-		out: l.val1,
-	}
-
-	// evaluate the layer
 	p.Y = l.base.Y
-
-	// Performance doesn't seem to improve past 100.
-	const batchSize = 100
-
-	eReq.p = make([]sdf.V3, 0, batchSize)
 	for y := 0; y < ny+1; y++ {
 		p.Z = l.base.Z
 		for z := 0; z < nz+1; z++ {
-			eReq.p = append(eReq.p, p)
-			if len(eReq.p) == batchSize {
-				eReq.wg.Add(1)
-				evalProcessCh <- eReq
-				eReq.out = eReq.out[batchSize:]       // shift the output slice for processing
-				eReq.p = make([]sdf.V3, 0, batchSize) // create a new slice for the next batch
-			}
+			l.p[idx] = p
 			idx++
 			p.Z += dz
 		}
 		p.Y += dy
 	}
 
-	// send any remaining points for processing
-	if len(eReq.p) > 0 {
-		eReq.wg.Add(1)
-		evalProcessCh <- eReq
-	}
-
-	// Wait for all processing to complete before returning
-	eReq.wg.Wait()
+	// evaluate the layer
+	l.e.Evaluate3(s, l.p, l.val1)
 }
 
 func (l *layerYZ) Get(x, y, z int) float64 {
@@ -129,7 +78,7 @@ func (l *layerYZ) Get(x, y, z int) float64 {
 
 //-----------------------------------------------------------------------------
 
-func marchingCubes(s sdf.SDF3, box sdf.Box3, step float64) []*Triangle3 {
+func marchingCubes(s sdf.SDF3, box sdf.Box3, step float64, e *Engine, t *tracker) []*Triangle3 {
 
 	var triangles []*Triangle3
 	size := box.Size()
@@ -138,16 +87,21 @@ func marchingCubes(s sdf.SDF3, box sdf.Box3, step float64) []*Triangle3 {
 	inc := size.Div(steps.ToV3())
 
 	// create the SDF layer cache
-	l := newLayerYZ(base, inc, steps)
+	l := newLayerYZ(base, inc, steps, e)
 	// evaluate the SDF for x = 0
 	l.Evaluate(s, 0)
 
 	nx, ny, nz := steps[0], steps[1], steps[2]
 	dx, dy, dz := inc.X, inc.Y, inc.Z
 
+	t.setTotal(int64(nx * ny * nz))
+
 	var p sdf.V3
 	p.X = base.X
 	for x := 0; x < nx; x++ {
+		if t.cancelled() {
+			break
+		}
 		// read the x + 1 layer
 		l.Evaluate(s, x+1)
 		// process all cubes in the x and x + 1 layers
@@ -158,14 +112,14 @@ func marchingCubes(s sdf.SDF3, box sdf.Box3, step float64) []*Triangle3 {
 				x0, y0, z0 := p.X, p.Y, p.Z
 				x1, y1, z1 := x0+dx, y0+dy, z0+dz
 				corners := [8]sdf.V3{
-					{x0, y0, z0},
-					{x1, y0, z0},
-					{x1, y1, z0},
-					{x0, y1, z0},
-					{x0, y0, z1},
-					{x1, y0, z1},
-					{x1, y1, z1},
-					{x0, y1, z1}}
+					{X: x0, Y: y0, Z: z0},
+					{X: x1, Y: y0, Z: z0},
+					{X: x1, Y: y1, Z: z0},
+					{X: x0, Y: y1, Z: z0},
+					{X: x0, Y: y0, Z: z1},
+					{X: x1, Y: y0, Z: z1},
+					{X: x1, Y: y1, Z: z1},
+					{X: x0, Y: y1, Z: z1}}
 				values := [8]float64{
 					l.Get(0, y, z),
 					l.Get(1, y, z),
@@ -181,11 +135,28 @@ func marchingCubes(s sdf.SDF3, box sdf.Box3, step float64) []*Triangle3 {
 			p.Y += dy
 		}
 		p.X += dx
+		t.addCells(int64(ny * nz))
 	}
 
 	return triangles
 }
 
+// uniformMarchingCubes generates a triangle mesh for an SDF3 using a uniform grid.
+func uniformMarchingCubes(s sdf.SDF3, resolution float64, output chan<- *Triangle3, e *Engine, t *tracker) {
+	// work out the region we will sample
+	bb0 := s.BoundingBox()
+	bb1Size := bb0.Size().DivScalar(resolution)
+	bb1Size = bb1Size.Ceil().AddScalar(1)
+	bb1Size = bb1Size.MulScalar(resolution)
+	bb := sdf.NewBox3(bb0.Center(), bb1Size)
+	triangles := marchingCubes(s, bb, resolution, e, t)
+	for _, tri := range triangles {
+		output <- tri
+	}
+	t.addTriangles(int64(len(triangles)))
+	t.flush()
+}
+
 //-----------------------------------------------------------------------------
 
 func mcToTriangles(p [8]sdf.V3, v [8]float64, x float64) []*Triangle3 {
@@ -251,9 +222,9 @@ func mcInterpolate(p1, p2 sdf.V3, v1, v2, x float64) sdf.V3 {
 	}
 
 	return sdf.V3{
-		p1.X + t*(p2.X-p1.X),
-		p1.Y + t*(p2.Y-p1.Y),
-		p1.Z + t*(p2.Z-p1.Z),
+		X: p1.X + t*(p2.X-p1.X),
+		Y: p1.Y + t*(p2.Y-p1.Y),
+		Z: p1.Z + t*(p2.Z-p1.Z),
 	}
 }
 
@@ -13,7 +13,6 @@ package render
 
 import (
 	"math"
-	"sync"
 
 	"github.com/jakoblorz/sdfx/sdf"
 )
@@ -31,12 +30,12 @@ type cube struct {
 // is about 2x a non-cached evaluation.
 
 type dcache3 struct {
-	origin     sdf.V3              // origin of the overall bounding cube
-	resolution float64             // size of smallest octree cube
-	hdiag      []float64           // lookup table of cube half diagonals
-	s          sdf.SDF3            // the SDF3 to be rendered
-	cache      map[sdf.V3i]float64 // cache of distances
-	lock       sync.RWMutex        // lock the the cache during reads/writes
+	origin     sdf.V3     // origin of the overall bounding cube
+	resolution float64    // size of smallest octree cube
+	hdiag      []float64  // lookup table of cube half diagonals
+	s          sdf.SDF3   // the SDF3 to be rendered
+	cache      *distCache // cache of distances
+	t          *tracker   // progress tracker
 }
 
 func newDcache3(s sdf.SDF3, origin sdf.V3, resolution float64, n uint) *dcache3 {
@@ -47,7 +46,7 @@ func newDcache3(s sdf.SDF3, origin sdf.V3, resolution float64, n uint) *dcache3
 		resolution: resolution,
 		hdiag:      make([]float64, n),
 		s:          s,
-		cache:      make(map[sdf.V3i]float64),
+		cache:      newDistCache(),
 	}
 	// build a lut for cube half diagonal lengths
 	for i := range dc.hdiag {
@@ -60,17 +59,12 @@ func newDcache3(s sdf.SDF3, origin sdf.V3, resolution float64, n uint) *dcache3
 
 // read from the cache
 func (dc *dcache3) read(vi sdf.V3i) (float64, bool) {
-	dc.lock.RLock()
-	dist, found := dc.cache[vi]
-	dc.lock.RUnlock()
-	return dist, found
+	return dc.cache.read(key3(vi))
 }
 
 // write to the cache
 func (dc *dcache3) write(vi sdf.V3i, dist float64) {
-	dc.lock.Lock()
-	dc.cache[vi] = dist
-	dc.lock.Unlock()
+	dc.cache.write(key3(vi), dist)
 }
 
 func (dc *dcache3) evaluate(vi sdf.V3i) (sdf.V3, float64) {
@@ -87,8 +81,86 @@ func (dc *dcache3) evaluate(vi sdf.V3i) (sdf.V3, float64) {
 	return v, dist
 }
 
+// corners returns the positions and distances for the corners of a cube.
+// The distances that aren't in the cache are evaluated as a batch.
+func (dc *dcache3) corners(c *cube) ([8]sdf.V3, [8]float64) {
+	var p [8]sdf.V3
+	var d [8]float64
+	var keys [8]sdf.V3i
+	var miss [8]int
+	var q [8]sdf.V3
+	n := 0
+	s := 1 << c.n // side
+	for i, k := range dcCorners {
+		keys[i] = c.v.Add(sdf.V3i{s * k[0], s * k[1], s * k[2]})
+		p[i] = dc.origin.Add(keys[i].ToV3().MulScalar(dc.resolution))
+		var found bool
+		if d[i], found = dc.read(keys[i]); !found {
+			miss[n] = i
+			q[n] = p[i]
+			n++
+		}
+	}
+	if n > 0 {
+		var dist [8]float64
+		sdf.Evaluate3N(dc.s, q[:n], dist[:n])
+		for j, i := range miss[:n] {
+			d[i] = dist[j]
+			dc.write(keys[i], dist[j])
+		}
+	}
+	return p, d
+}
+
+// cells returns the number of cells (at the meshing resolution) in a cube.
+func (c *cube) cells() int64 {
+	return 1 << (3 * (c.n - 1))
+}
+
+// children returns the 8 sub cubes of a cube.
+func (c *cube) children() []*cube {
+	n := c.n - 1
+	s := 1 << n
+	return []*cube{
+		{c.v.Add(sdf.V3i{0, 0, 0}), n},
+		{c.v.Add(sdf.V3i{s, 0, 0}), n},
+		{c.v.Add(sdf.V3i{s, s, 0}), n},
+		{c.v.Add(sdf.V3i{0, s, 0}), n},
+		{c.v.Add(sdf.V3i{0, 0, s}), n},
+		{c.v.Add(sdf.V3i{s, 0, s}), n},
+		{c.v.Add(sdf.V3i{s, s, s}), n},
+		{c.v.Add(sdf.V3i{0, s, s}), n},
+	}
+}
+
+// split subdivides a cube until there are at least n non-empty sub cubes
+// (or the cubes are at the required resolution). The sub cubes can then be
+// processed in parallel.
+func (dc *dcache3) split(c *cube, n int) []*cube {
+	cubes := []*cube{c}
+	for len(cubes) > 0 && len(cubes) < n && cubes[0].n > 1 {
+		var next []*cube
+		for _, c := range cubes {
+			if dc.isEmpty(c) {
+				dc.t.addCells(c.cells())
+				continue
+			}
+			next = append(next, c.children()...)
+		}
+		cubes = next
+	}
+	return cubes
+}
+
 // isEmpty returns true if the cube contains no SDF surface
 func (dc *dcache3) isEmpty(c *cube) bool {
+	if _, ok := dc.s.(sdf.IntervalEvaluator); ok {
+		// bound the distance over the cube
+		min := dc.origin.Add(c.v.ToV3().MulScalar(dc.resolution))
+		max := dc.origin.Add(c.v.AddScalar(1 << c.n).ToV3().MulScalar(dc.resolution))
+		lo, hi := sdf.EvaluateInterval3(dc.s, sdf.Box3{Min: min, Max: max})
+		return lo >= 0 || hi <= 0
+	}
 	// evaluate the SDF3 at the center of the cube
 	s := 1 << (c.n - 1) // half side
 	_, d := dc.evaluate(c.v.AddScalar(s))
@@ -98,44 +170,37 @@ func (dc *dcache3) isEmpty(c *cube) bool {
 
 // Process a cube. Generate triangles, or more cubes.
 func (dc *dcache3) processCube(c *cube, output chan<- *Triangle3) {
+	if c.n > 1 && dc.t.cancelled() {
+		return
+	}
 	if !dc.isEmpty(c) {
 		if c.n == 1 {
 			// this cube is at the required resolution
-			c0, d0 := dc.evaluate(c.v.Add(sdf.V3i{0, 0, 0}))
-			c1, d1 := dc.evaluate(c.v.Add(sdf.V3i{2, 0, 0}))
-			c2, d2 := dc.evaluate(c.v.Add(sdf.V3i{2, 2, 0}))
-			c3, d3 := dc.evaluate(c.v.Add(sdf.V3i{0, 2, 0}))
-			c4, d4 := dc.evaluate(c.v.Add(sdf.V3i{0, 0, 2}))
-			c5, d5 := dc.evaluate(c.v.Add(sdf.V3i{2, 0, 2}))
-			c6, d6 := dc.evaluate(c.v.Add(sdf.V3i{2, 2, 2}))
-			c7, d7 := dc.evaluate(c.v.Add(sdf.V3i{0, 2, 2}))
-			corners := [8]sdf.V3{c0, c1, c2, c3, c4, c5, c6, c7}
-			values := [8]float64{d0, d1, d2, d3, d4, d5, d6, d7}
+			corners, values := dc.corners(c)
 			// output the triangle(s) for this cube
-			for _, t := range mcToTriangles(corners, values, 0) {
+			triangles := mcToTriangles(corners, values, 0)
+			for _, t := range triangles {
 				output <- t
 			}
+			dc.t.addTriangles(int64(len(triangles)))
+			dc.t.addCells(1)
 		} else {
 			// process the sub cubes
-			n := c.n - 1
-			s := 1 << n
-			// TODO - turn these into throttled go-routines
-			dc.processCube(&cube{c.v.Add(sdf.V3i{0, 0, 0}), n}, output)
-			dc.processCube(&cube{c.v.Add(sdf.V3i{s, 0, 0}), n}, output)
-			dc.processCube(&cube{c.v.Add(sdf.V3i{s, s, 0}), n}, output)
-			dc.processCube(&cube{c.v.Add(sdf.V3i{0, s, 0}), n}, output)
-			dc.processCube(&cube{c.v.Add(sdf.V3i{0, 0, s}), n}, output)
-			dc.processCube(&cube{c.v.Add(sdf.V3i{s, 0, s}), n}, output)
-			dc.processCube(&cube{c.v.Add(sdf.V3i{s, s, s}), n}, output)
-			dc.processCube(&cube{c.v.Add(sdf.V3i{0, s, s}), n}, output)
+			for _, sc := range c.children() {
+				dc.processCube(sc, output)
+			}
 		}
+	} else {
+		dc.t.addCells(c.cells())
 	}
 }
 
 //-----------------------------------------------------------------------------
 
 // marchingCubesOctree generates a triangle mesh for an SDF3 using octree subdivision.
-func marchingCubesOctree(s sdf.SDF3, resolution float64, output chan<- *Triangle3) {
+// The upper levels of the octree are split into sub cubes that are processed in parallel.
+// Cancellation and progress are handled by the tracker.
+func marchingCubesOctree(s sdf.SDF3, resolution float64, output chan<- *Triangle3, e *Engine, t *tracker) {
 	// Scale the bounding box about the center to make sure the boundaries
 	// aren't on the object surface.
 	bb := s.BoundingBox()
@@ -148,8 +213,15 @@ func marchingCubesOctree(s sdf.SDF3, resolution float64, output chan<- *Triangle
 	levels := uint(math.Ceil(math.Log2(longAxis/resolution))) + 1
 	// create the distance cache
 	dc := newDcache3(s, bb.Min, resolution, levels)
+	dc.t = t
 	// process the octree, start at the top level
-	dc.processCube(&cube{sdf.V3i{0, 0, 0}, levels - 1}, output)
+	top := &cube{sdf.V3i{0, 0, 0}, levels - 1}
+	t.setTotal(top.cells())
+	cubes := dc.split(top, 4*e.Workers())
+	e.Run(len(cubes), func(_, i int) {
+		dc.processCube(cubes[i], output)
+	})
+	t.flush()
 }
 
 //-----------------------------------------------------------------------------
@@ -0,0 +1,350 @@
+//-----------------------------------------------------------------------------
+/*
+
+Indexed Triangle Meshes
+
+Triangles share vertices. Vertices within a tolerance are welded together.
+Edge adjacency is used to check the mesh for printability problems.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// vertexSet is a set of unique vertices, vertices within a tolerance are merged.
+// With a tolerance <= 0 only identical vertices are merged.
+type vertexSet struct {
+	tolerance float64
+	cells     map[[3]int64][]int // spatial hash for tolerance > 0
+	exact     map[sdf.V3]int     // vertex lookup for tolerance <= 0
+}
+
+// newVertexSet returns an empty vertex set.
+func newVertexSet(tolerance float64) *vertexSet {
+	if tolerance <= 0 {
+		return &vertexSet{
+			tolerance: tolerance,
+			exact:     make(map[sdf.V3]int),
+		}
+	}
+	return &vertexSet{
+		tolerance: tolerance,
+		cells:     make(map[[3]int64][]int),
+	}
+}
+
+// cell returns the spatial hash cell for a vertex.
+func (vs *vertexSet) cell(v sdf.V3) [3]int64 {
+	return [3]int64{
+		int64(math.Floor(v.X / vs.tolerance)),
+		int64(math.Floor(v.Y / vs.tolerance)),
+		int64(math.Floor(v.Z / vs.tolerance)),
+	}
+}
+
+// find returns the index of a vertex within tolerance of v, or -1 if there is none.
+func (vs *vertexSet) find(vertex []sdf.V3, v sdf.V3) int {
+	if vs.exact != nil {
+		if i, ok := vs.exact[v]; ok {
+			return i
+		}
+		return -1
+	}
+	c := vs.cell(v)
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dz := int64(-1); dz <= 1; dz++ {
+				for _, i := range vs.cells[[3]int64{c[0] + dx, c[1] + dy, c[2] + dz}] {
+					if vertex[i].Equals(v, vs.tolerance) {
+						return i
+					}
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// add records the index of a new vertex.
+func (vs *vertexSet) add(v sdf.V3, i int) {
+	if vs.exact != nil {
+		if _, ok := vs.exact[v]; !ok {
+			vs.exact[v] = i
+		}
+		return
+	}
+	c := vs.cell(v)
+	vs.cells[c] = append(vs.cells[c], i)
+}
+
+//-----------------------------------------------------------------------------
+
+// MeshEdge is a mesh edge defined by two vertex indices (lowest index first).
+type MeshEdge [2]int
+
+// newMeshEdge returns the edge between vertex indices a and b.
+func newMeshEdge(a, b int) MeshEdge {
+	if a > b {
+		a, b = b, a
+	}
+	return MeshEdge{a, b}
+}
+
+// Mesh is an indexed triangle mesh.
+type Mesh struct {
+	Vertex []sdf.V3    // vertices
+	Face   []TriangleI // triangles as vertex indices
+	weld   *vertexSet  // vertex welding lookup
+}
+
+// NewMesh returns an empty mesh. Vertices within tolerance are welded together,
+// with a tolerance <= 0 only identical vertices are welded.
+func NewMesh(tolerance float64) *Mesh {
+	return &Mesh{
+		weld: newVertexSet(tolerance),
+	}
+}
+
+// NewMeshFromTriangles returns a mesh built from a triangle soup.
+func NewMeshFromTriangles(triangles []*Triangle3, tolerance float64) *Mesh {
+	m := NewMesh(tolerance)
+	for _, t := range triangles {
+		m.Add(t)
+	}
+	return m
+}
+
+// vertex returns the index of a vertex, adding it if necessary.
+func (m *Mesh) vertex(v sdf.V3) int {
+	if i := m.weld.find(m.Vertex, v); i >= 0 {
+		return i
+	}
+	i := len(m.Vertex)
+	m.Vertex = append(m.Vertex, v)
+	m.weld.add(v, i)
+	return i
+}
+
+// Add adds a triangle to the mesh.
+// Triangles that are degenerate after vertex welding are dropped.
+func (m *Mesh) Add(t *Triangle3) {
+	f := TriangleI{m.vertex(t.V[0]), m.vertex(t.V[1]), m.vertex(t.V[2])}
+	if f[0] == f[1] || f[1] == f[2] || f[2] == f[0] {
+		return
+	}
+	m.Face = append(m.Face, f)
+}
+
+// Weld re-welds the mesh vertices with a new tolerance.
+// Unused vertices and degenerate triangles are removed.
+func (m *Mesh) Weld(tolerance float64) {
+	triangles := m.Triangles()
+	*m = *NewMeshFromTriangles(triangles, tolerance)
+}
+
+// Triangles returns the mesh as a triangle soup.
+func (m *Mesh) Triangles() []*Triangle3 {
+	triangles := make([]*Triangle3, len(m.Face))
+	for i := range m.Face {
+		triangles[i] = m.Triangle(i)
+	}
+	return triangles
+}
+
+// Triangle returns a mesh face as a triangle.
+func (m *Mesh) Triangle(i int) *Triangle3 {
+	f := m.Face[i]
+	return NewTriangle3(m.Vertex[f[0]], m.Vertex[f[1]], m.Vertex[f[2]])
+}
+
+//-----------------------------------------------------------------------------
+// Edge Adjacency
+
+// Edges returns the faces adjacent to each edge of the mesh.
+func (m *Mesh) Edges() map[MeshEdge][]int {
+	edges := make(map[MeshEdge][]int, len(m.Face)*3/2)
+	for i, f := range m.Face {
+		for j := 0; j < 3; j++ {
+			e := newMeshEdge(f[j], f[(j+1)%3])
+			edges[e] = append(edges[e], i)
+		}
+	}
+	return edges
+}
+
+// EdgeFaces returns the faces that share the edge between vertices a and b.
+func (m *Mesh) EdgeFaces(a, b int) []int {
+	var faces []int
+	for i, f := range m.Face {
+		if f.hasEdge(a, b) {
+			faces = append(faces, i)
+		}
+	}
+	return faces
+}
+
+// Neighbours returns the faces that share an edge with a face.
+func (m *Mesh) Neighbours(face int) []int {
+	var faces []int
+	f := m.Face[face]
+	for i, g := range m.Face {
+		if i == face {
+			continue
+		}
+		for j := 0; j < 3; j++ {
+			if g.hasEdge(f[j], f[(j+1)%3]) {
+				faces = append(faces, i)
+				break
+			}
+		}
+	}
+	return faces
+}
+
+// hasEdge returns true if the triangle has an edge between vertices a and b.
+func (t TriangleI) hasEdge(a, b int) bool {
+	for j := 0; j < 3; j++ {
+		c, d := t[j], t[(j+1)%3]
+		if (c == a && d == b) || (c == b && d == a) {
+			return true
+		}
+	}
+	return false
+}
+
+// direction returns +1 if the triangle has the directed edge a->b, -1 for b->a.
+func (t TriangleI) direction(a, b int) int {
+	for j := 0; j < 3; j++ {
+		c, d := t[j], t[(j+1)%3]
+		if c == a && d == b {
+			return 1
+		}
+		if c == b && d == a {
+			return -1
+		}
+	}
+	return 0
+}
+
+//-----------------------------------------------------------------------------
+// Mesh Validation
+
+// MeshReport describes the problems found in a mesh.
+type MeshReport struct {
+	NonManifoldEdges []MeshEdge // edges shared by more than two faces
+	BoundaryEdges    []MeshEdge // edges with a single face (holes in the mesh)
+	FlippedFaces     []int      // faces with normals pointing into the mesh
+	Components       int        // number of edge connected components
+}
+
+// OK returns true if the mesh is closed, manifold and consistently oriented.
+func (r *MeshReport) OK() bool {
+	return len(r.NonManifoldEdges) == 0 && len(r.BoundaryEdges) == 0 && len(r.FlippedFaces) == 0
+}
+
+func (r *MeshReport) String() string {
+	return fmt.Sprintf("components %d, non-manifold edges %d, boundary edges %d, flipped faces %d",
+		r.Components, len(r.NonManifoldEdges), len(r.BoundaryEdges), len(r.FlippedFaces))
+}
+
+// signedVolume returns the signed volume of the tetrahedron from the origin to a face.
+func (m *Mesh) signedVolume(i int) float64 {
+	f := m.Face[i]
+	return m.Vertex[f[0]].Dot(m.Vertex[f[1]].Cross(m.Vertex[f[2]])) / 6
+}
+
+// Check looks for non-manifold edges, holes and flipped normals.
+// Face orientation is propagated across the manifold edges of each
+// connected component. Faces that disagree with the outward orientation
+// of their component (as given by its signed volume) are flipped.
+func (m *Mesh) Check() *MeshReport {
+	r := &MeshReport{}
+	edges := m.Edges()
+
+	// classify the edges
+	for e, faces := range edges {
+		switch {
+		case len(faces) == 1:
+			r.BoundaryEdges = append(r.BoundaryEdges, e)
+		case len(faces) > 2:
+			r.NonManifoldEdges = append(r.NonManifoldEdges, e)
+		}
+	}
+	sortEdges(r.BoundaryEdges)
+	sortEdges(r.NonManifoldEdges)
+
+	// propagate the face orientation over each component
+	orientation := make([]int, len(m.Face)) // +1/-1 relative to the component seed, 0 = unvisited
+	for seed := range m.Face {
+		if orientation[seed] != 0 {
+			continue
+		}
+		r.Components++
+		component := []int{seed}
+		orientation[seed] = 1
+		for k := 0; k < len(component); k++ {
+			i := component[k]
+			f := m.Face[i]
+			for j := 0; j < 3; j++ {
+				a, b := f[j], f[(j+1)%3]
+				faces := edges[newMeshEdge(a, b)]
+				if len(faces) != 2 {
+					continue
+				}
+				n := faces[0]
+				if n == i {
+					n = faces[1]
+				}
+				if orientation[n] != 0 {
+					continue
+				}
+				// a consistently oriented neighbour has the edge b->a
+				if m.Face[n].direction(a, b) == 1 {
+					orientation[n] = -orientation[i]
+				} else {
+					orientation[n] = orientation[i]
+				}
+				component = append(component, n)
+			}
+		}
+		// the outward orientation gives a positive volume
+		volume := 0.0
+		for _, i := range component {
+			volume += float64(orientation[i]) * m.signedVolume(i)
+		}
+		if volume < 0 {
+			for _, i := range component {
+				orientation[i] = -orientation[i]
+			}
+		}
+	}
+
+	for i, o := range orientation {
+		if o < 0 {
+			r.FlippedFaces = append(r.FlippedFaces, i)
+		}
+	}
+	return r
+}
+
+// sortEdges sorts edges by vertex index.
+func sortEdges(edges []MeshEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,145 @@
+//-----------------------------------------------------------------------------
+/*
+
+Rendering Options
+
+Optional settings for the rendering functions.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"context"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// Mesher is an algorithm for converting an SDF3 to a triangle mesh.
+type Mesher int
+
+// Meshing algorithms.
+const (
+	MarchingCubes          Mesher = iota // marching cubes with octree sampling
+	DualContouring                       // dual contouring, preserves sharp edges and corners
+	AdaptiveDualContouring               // dual contouring with large cells on flat regions
+	UniformMarchingCubes                 // marching cubes with uniform grid sampling
+)
+
+// generate generates the triangle mesh for an SDF3.
+// The SDF3 is evaluated by the engine (nil for the default engine).
+func (m Mesher) generate(s sdf.SDF3, resolution float64, output chan<- *Triangle3, e *Engine, t *tracker) {
+	switch m {
+	case DualContouring:
+		dualContouring(s, resolution, output, e, t)
+	case AdaptiveDualContouring:
+		adaptiveDualContouring(s, resolution, output, e, t)
+	case UniformMarchingCubes:
+		uniformMarchingCubes(s, resolution, output, e, t)
+	default:
+		marchingCubesOctree(s, resolution, output, e, t)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// renderConfig holds the optional settings for rendering.
+type renderConfig struct {
+	mesher   Mesher          // meshing algorithm
+	engine   *Engine         // evaluation engine, nil for the default engine
+	ctx      context.Context // context for cancellation
+	progress ProgressFunc    // progress callback
+	t        *tracker        // progress tracker
+	simplify bool            // simplify the mesh before writing it
+	faces    int             // target face count for simplification
+	maxError float64         // error bound for simplification
+
+	contourTolerance float64 // error bound for 2d contour simplification
+}
+
+// RenderOption is an optional setting for rendering.
+type RenderOption func(*renderConfig)
+
+// newRenderConfig returns the render settings with the options applied.
+func newRenderConfig(options []RenderOption) *renderConfig {
+	cfg := &renderConfig{}
+	for _, o := range options {
+		o(cfg)
+	}
+	cfg.t = newTracker(cfg.ctx, cfg.progress)
+	return cfg
+}
+
+// report sends a progress message to the progress callback.
+func (cfg *renderConfig) report(format string, a ...interface{}) {
+	cfg.t.report(format, a...)
+}
+
+//-----------------------------------------------------------------------------
+
+// UseMesher selects the meshing algorithm. The default is MarchingCubes.
+func UseMesher(m Mesher) RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.mesher = m
+	}
+}
+
+// WithContext sets a context. Rendering stops and returns the context
+// error when the context is cancelled.
+func WithContext(ctx context.Context) RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.ctx = ctx
+	}
+}
+
+// OnProgress sets a callback for progress reports.
+// By default the To* functions are silent and the Render* functions print
+// the status messages to stdout.
+func OnProgress(fn ProgressFunc) RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.progress = fn
+	}
+}
+
+// Decimate simplifies the rendered mesh by collapsing edges until it has no
+// more than faces triangles, or until the quadric error of a collapse would be
+// more than maxError squared (see Mesh.Simplify).
+// Use faces <= 0 to simplify down to the error bound alone, and maxError <= 0
+// to simplify down to the face count alone. With neither limit the mesh isn't simplified.
+func Decimate(faces int, maxError float64) RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.simplify = faces > 0 || maxError > 0
+		cfg.faces = faces
+		cfg.maxError = maxError
+	}
+}
+
+// UseEngine sets the engine used to evaluate the SDF in parallel.
+// By default a shared engine with a worker per cpu is used.
+func UseEngine(e *Engine) RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.engine = e
+	}
+}
+
+// Workers sets the number of workers used to evaluate the SDF in parallel.
+// The workers are kept for later renderings with the same number of workers.
+func Workers(n int) RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.engine = sharedEngine(n)
+	}
+}
+
+// SimplifyContours simplifies the 2d contours (Douglas-Peucker) written to
+// DXF and SVG files. Points are removed while the contour stays within tolerance
+// of the original.
+func SimplifyContours(tolerance float64) RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.contourTolerance = tolerance
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,177 @@
+//-----------------------------------------------------------------------------
+/*
+
+Rendering Progress
+
+Track the progress of long running renders, report it to a callback and
+check for cancellation.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// progressInterval is the minimum time between progress reports.
+const progressInterval = 500 * time.Millisecond
+
+// Progress reports the state of a rendering.
+// Status reports have a message, periodic reports only have the counts.
+type Progress struct {
+	Message   string        // status message
+	Cells     int64         // cells (or pixel samples) processed so far
+	Total     int64         // total cells (or pixel samples), 0 if unknown
+	Triangles int64         // triangles emitted so far
+	Elapsed   time.Duration // time since the rendering started
+	ETA       time.Duration // estimated time remaining, 0 if unknown
+}
+
+// ProgressFunc is called to report rendering progress.
+type ProgressFunc func(p Progress)
+
+// printProgress prints progress messages to stdout.
+func printProgress(p Progress) {
+	if p.Message != "" {
+		fmt.Printf("%s\n", p.Message)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// tracker tracks the progress of a rendering and checks for cancellation.
+// A nil tracker is valid, it reports nothing and is never cancelled.
+type tracker struct {
+	ctx       context.Context
+	fn        ProgressFunc
+	start     time.Time
+	total     int64 // total cells
+	cells     int64 // cells processed (atomic)
+	triangles int64 // triangles emitted (atomic)
+	last      int64 // time of the last report, ns since the start (atomic)
+	lock      sync.Mutex
+}
+
+// newTracker returns a tracker for a rendering.
+func newTracker(ctx context.Context, fn ProgressFunc) *tracker {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &tracker{
+		ctx:   ctx,
+		fn:    fn,
+		start: time.Now(),
+	}
+}
+
+// err returns the context error if the rendering has been cancelled.
+func (t *tracker) err() error {
+	if t == nil {
+		return nil
+	}
+	return t.ctx.Err()
+}
+
+// cancelled returns true if the rendering has been cancelled.
+func (t *tracker) cancelled() bool {
+	return t.err() != nil
+}
+
+// setTotal sets the total number of cells and resets the cell count.
+func (t *tracker) setTotal(total int64) {
+	if t == nil {
+		return
+	}
+	atomic.StoreInt64(&t.total, total)
+	atomic.StoreInt64(&t.cells, 0)
+}
+
+// addCells records processed cells.
+func (t *tracker) addCells(n int64) {
+	if t == nil {
+		return
+	}
+	atomic.AddInt64(&t.cells, n)
+	t.update()
+}
+
+// addTriangles records emitted triangles.
+func (t *tracker) addTriangles(n int64) {
+	if t == nil {
+		return
+	}
+	atomic.AddInt64(&t.triangles, n)
+}
+
+// progress returns the current progress.
+func (t *tracker) progress(msg string) Progress {
+	p := Progress{
+		Message:   msg,
+		Cells:     atomic.LoadInt64(&t.cells),
+		Total:     atomic.LoadInt64(&t.total),
+		Triangles: atomic.LoadInt64(&t.triangles),
+		Elapsed:   time.Since(t.start),
+	}
+	if p.Cells > 0 && p.Total > p.Cells {
+		p.ETA = time.Duration(float64(p.Elapsed) * float64(p.Total-p.Cells) / float64(p.Cells))
+	}
+	return p
+}
+
+// update sends a periodic progress report.
+// The report interval is checked without locking, so the workers only contend
+// for the lock when a report is due.
+func (t *tracker) update() {
+	if t.fn == nil {
+		return
+	}
+	now := int64(time.Since(t.start))
+	last := atomic.LoadInt64(&t.last)
+	if now-last < int64(progressInterval) {
+		return
+	}
+	// only one worker sends the report
+	if !atomic.CompareAndSwapInt64(&t.last, last, now) {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.fn(t.progress(""))
+}
+
+// touch records the time of a report.
+func (t *tracker) touch() {
+	atomic.StoreInt64(&t.last, int64(time.Since(t.start)))
+}
+
+// flush sends a final progress report.
+func (t *tracker) flush() {
+	if t == nil || t.fn == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.touch()
+	t.fn(t.progress(""))
+}
+
+// report sends a status message.
+func (t *tracker) report(format string, a ...interface{}) {
+	if t == nil || t.fn == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.touch()
+	t.fn(t.progress(fmt.Sprintf(format, a...)))
+}
+
+//-----------------------------------------------------------------------------
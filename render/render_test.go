@@ -0,0 +1,1108 @@
+//-----------------------------------------------------------------------------
+
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/jakoblorz/sdfx/obj"
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+func Test_SDFHitable(t *testing.T) {
+	sphere, err := sdf.Sphere3D(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := sdf.Box3D(sdf.V3{X: 10, Y: 10, Z: 10}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const far = 1e9
+	for _, test := range []struct {
+		name       string
+		s          sdf.SDF3
+		o, d       sdf.V3
+		tMin, tMax float64
+		hit        bool
+		t          float64 // expected hit
+		normal     sdf.V3  // expected normal
+	}{
+		// hits
+		{"sphere", sphere, sdf.V3{X: -20}, sdf.V3{X: 1}, 0, far, true, 15, sdf.V3{X: -1}},
+		{"sphere inside", sphere, sdf.V3{}, sdf.V3{Y: 1}, 0, far, true, 5, sdf.V3{Y: 1}},
+		{"box", box, sdf.V3{X: -20, Y: 1, Z: 2}, sdf.V3{X: 2}, 0, far, true, 7.5, sdf.V3{X: -1}},
+		{"box top", box, sdf.V3{X: 3, Y: -4, Z: 20}, sdf.V3{Z: -1}, 0, far, true, 15, sdf.V3{Z: 1}},
+		// misses
+		{"sphere outside box", sphere, sdf.V3{X: -20, Y: 10}, sdf.V3{X: 1}, 0, far, false, 0, sdf.V3{}},
+		{"sphere inside box", sphere, sdf.V3{X: -20, Y: 4.9, Z: 4.9}, sdf.V3{X: 1}, 0, far, false, 0, sdf.V3{}},
+		{"sphere behind", sphere, sdf.V3{X: 20}, sdf.V3{X: 1}, 0, far, false, 0, sdf.V3{}},
+		{"box edge", box, sdf.V3{X: -20, Y: 5.02}, sdf.V3{X: 1}, 0, far, false, 0, sdf.V3{}},
+		// clipped by the t range
+		{"sphere tMax", sphere, sdf.V3{X: -20}, sdf.V3{X: 1}, 0, 10, false, 0, sdf.V3{}},
+		{"sphere tMin", sphere, sdf.V3{X: -20}, sdf.V3{X: 1}, 16, far, true, 25, sdf.V3{X: 1}},
+		{"box tMax", box, sdf.V3{Z: 20}, sdf.V3{Z: -1}, 0, 14.9, false, 0, sdf.V3{}},
+		{"box tMin", box, sdf.V3{Z: 20}, sdf.V3{Z: -1}, 16, 100, true, 25, sdf.V3{Z: -1}},
+	} {
+		h := NewSDFHitable(test.s, nil)
+		r := &Ray3{Origin: test.o, Direction: test.d}
+		hit, rec := h.Hit(r, test.tMin, test.tMax)
+		if hit != test.hit {
+			t.Errorf("%s: hit %v (expected %v)", test.name, hit, test.hit)
+			continue
+		}
+		if !hit {
+			continue
+		}
+		if rec.t < test.t-1e-3 || rec.t > test.t+1e-3 || !rec.p.Equals(r.PointAt(test.t), 1e-3) || !rec.normal.Equals(test.normal, 1e-3) {
+			t.Errorf("%s: t %f p %v normal %v", test.name, rec.t, rec.p, rec.normal)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_STL(t *testing.T) {
+	a := sdf.V3{X: 0, Y: 0, Z: 0}
+	b := sdf.V3{X: 30, Y: 0, Z: 0}
+	c := sdf.V3{X: 0, Y: 30, Z: 0}
+	d := sdf.V3{X: 0, Y: 0, Z: 30}
+	mesh := []*Triangle3{
+		NewTriangle3(a, b, d),
+		NewTriangle3(a, c, b),
+		NewTriangle3(a, d, c),
+		NewTriangle3(b, c, d),
+	}
+
+	dir, err := ioutil.TempDir("", "sdfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// binary round trip
+	path := filepath.Join(dir, "binary.stl")
+	if err := SaveSTL(path, mesh); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadSTL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != len(mesh) {
+		t.Fatalf("expected %d triangles, actual %d", len(mesh), len(m))
+	}
+	for i := range m {
+		for j := 0; j < 3; j++ {
+			if !m[i].V[j].Equals(mesh[i].V[j], tolerance) {
+				t.Error("FAIL")
+			}
+		}
+	}
+
+	// ascii
+	ascii := `solid test
+  facet normal 0 0 -1
+    outer loop
+      vertex 0 0 0
+      vertex 0 30 0
+      vertex 30 0 0
+    endloop
+  endfacet
+endsolid test
+`
+	m, err = readSTL([]byte(ascii))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 1 || !m[0].V[1].Equals(c, tolerance) {
+		t.Error("FAIL")
+	}
+
+	// inside/outside of the loaded mesh
+	s, err := STLMesh3D(mesh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Evaluate(sdf.V3{X: 1, Y: 1, Z: 1}) >= 0 {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(sdf.V3{X: -1, Y: 1, Z: 1}) <= 0 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_3MF(t *testing.T) {
+	s, err := sdf.Sphere3D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "sdfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sphere.3mf")
+	Render3MF(s, 20, path)
+
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+	var model struct {
+		Unit   string `xml:"unit,attr"`
+		Object struct {
+			Name     string `xml:"name,attr"`
+			Vertices []struct {
+				X float64 `xml:"x,attr"`
+			} `xml:"mesh>vertices>vertex"`
+			Triangles []struct {
+				V1 int `xml:"v1,attr"`
+			} `xml:"mesh>triangles>triangle"`
+		} `xml:"resources>object"`
+	}
+	for _, f := range z.File {
+		if f.Name != "3D/3dmodel.model" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := xml.NewDecoder(r).Decode(&model); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+	}
+	if model.Unit != "millimeter" || model.Object.Name != "sphere" {
+		t.Errorf("unit %q name %q", model.Unit, model.Object.Name)
+	}
+	// a closed genus 0 mesh has V - E + F = 2, with E = 3F/2
+	v, f := len(model.Object.Vertices), len(model.Object.Triangles)
+	if f == 0 || 2*v-f != 4 {
+		t.Errorf("vertices %d triangles %d", v, f)
+	}
+
+	// write errors are returned
+	if _, err := os.Stat("/dev/full"); err != nil {
+		return
+	}
+	if err := To3MF(s, 10, "/dev/full"); err == nil {
+		t.Error("To3MF: expected an error")
+	}
+	c, errc, err := Stream3MF("/dev/full", "sphere", Millimeter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c <- NewTriangle3(sdf.V3{}, sdf.V3{X: 1}, sdf.V3{Y: 1})
+	close(c)
+	if err := <-errc; err == nil {
+		t.Error("Stream3MF: expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Mesh(t *testing.T) {
+	s, err := sdf.Sphere3D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := renderMesh(s, 1, MarchingCubes, nil, nil)
+	r := m.Check()
+	if !r.OK() || r.Components != 1 {
+		t.Errorf("sphere: %s", r)
+	}
+
+	// flip a face
+	f := m.Face[0]
+	m.Face[0] = TriangleI{f[0], f[2], f[1]}
+	r = m.Check()
+	if len(r.FlippedFaces) != 1 || r.FlippedFaces[0] != 0 {
+		t.Errorf("flipped: %s", r)
+	}
+
+	// remove a face
+	m.Face = m.Face[1:]
+	r = m.Check()
+	if len(r.BoundaryEdges) != 3 || len(r.FlippedFaces) != 0 {
+		t.Errorf("hole: %s", r)
+	}
+
+	// put the face back and add a third face to one of its edges
+	m.Face = append(m.Face, f, TriangleI{f[0], f[1], len(m.Vertex)})
+	m.Vertex = append(m.Vertex, sdf.V3{X: 0, Y: 0, Z: 0})
+	r = m.Check()
+	if len(r.NonManifoldEdges) != 1 {
+		t.Errorf("non-manifold: %s", r)
+	}
+
+	// zero tolerance only welds identical vertices
+	m = NewMesh(0)
+	m.Add(&Triangle3{V: [3]sdf.V3{{X: 0, Y: 0, Z: 0}, {X: 1, Y: 0, Z: 0}, {X: 0, Y: 1, Z: 0}}})
+	m.Add(&Triangle3{V: [3]sdf.V3{{X: 1, Y: 0, Z: 0}, {X: 1, Y: 1, Z: 0}, {X: 0, Y: 1, Z: 0}}})
+	m.Add(&Triangle3{V: [3]sdf.V3{{X: 0, Y: 0, Z: 1e-12}, {X: 1, Y: 0, Z: 0}, {X: 0, Y: 1, Z: 0}}})
+	if len(m.Vertex) != 5 || len(m.Face) != 3 {
+		t.Errorf("zero tolerance: %d vertices, %d faces", len(m.Vertex), len(m.Face))
+	}
+	m.Weld(0)
+	if len(m.Vertex) != 5 {
+		t.Errorf("zero tolerance weld: %d vertices", len(m.Vertex))
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Simplify(t *testing.T) {
+	// flat faces are simplified without error
+	b, err := sdf.Box3D(sdf.V3{X: 20, Y: 10, Z: 10}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := renderMesh(b, 0.5, MarchingCubes, nil, nil)
+	n := len(m.Face)
+	// no limits, no simplification
+	m.Simplify(0, 0)
+	if len(m.Face) != n {
+		t.Errorf("box: %d to %d triangles with no limits", n, len(m.Face))
+	}
+	m.Simplify(0, 1e-3)
+	if r := m.Check(); !r.OK() || len(m.Face) > n/10 {
+		t.Errorf("box: %d to %d triangles, %s", n, len(m.Face), r)
+	}
+
+	// curved surfaces are simplified down to a target face count
+	s, err := sdf.Sphere3D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = renderMesh(s, 0.5, MarchingCubes, nil, nil)
+	m.Simplify(500, 1)
+	if r := m.Check(); !r.OK() || len(m.Face) > 500 {
+		t.Errorf("sphere: %d triangles, %s", len(m.Face), r)
+	}
+	for _, v := range m.Vertex {
+		if d := math.Abs(s.Evaluate(v)); d > 0.5 {
+			t.Errorf("sphere: vertex %v distance %f", v, d)
+		}
+	}
+
+	// no error limit, simplify down to the face count alone
+	m = renderMesh(s, 0.5, MarchingCubes, nil, nil)
+	m.Simplify(50, 0)
+	if r := m.Check(); !r.OK() || len(m.Face) > 50 {
+		t.Errorf("sphere: %d triangles, %s", len(m.Face), r)
+	}
+
+	// the face lists of the vertices don't hold collapsed faces
+	m = renderMesh(s, 1, MarchingCubes, nil, nil)
+	sm := newSimplifier(m)
+	sm.run(100, 0)
+	for v, faces := range sm.faces {
+		for _, i := range faces {
+			if sm.deleted[i] {
+				t.Errorf("vertex %d: deleted face %d", v, i)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_DualContouring(t *testing.T) {
+	// vertices are placed on the sharp corners of a box
+	b, err := sdf.Box3D(sdf.V3{X: 20, Y: 10, Z: 10}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := renderMesh(b, 0.7, DualContouring, nil, nil)
+	if r := m.Check(); !r.OK() {
+		t.Errorf("box: %s", r)
+	}
+	for _, corner := range b.BoundingBox().Vertices() {
+		found := false
+		for _, v := range m.Vertex {
+			if v.Equals(corner, 1e-6) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("box: no vertex at corner %v", corner)
+		}
+	}
+
+	s, err := sdf.Sphere3D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = renderMesh(s, 1, DualContouring, nil, nil)
+	if r := m.Check(); !r.OK() {
+		t.Errorf("sphere: %s", r)
+	}
+	for _, v := range m.Vertex {
+		if d := math.Abs(s.Evaluate(v)); d > 0.1 {
+			t.Errorf("sphere: vertex %v distance %f", v, d)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_AdaptiveDualContouring(t *testing.T) {
+	p, err := obj.PanelBox3D(&obj.PanelBoxParms{
+		Size:       sdf.V3{X: 50, Y: 40, Z: 60},
+		Wall:       2.5,
+		Panel:      3,
+		Rounding:   5,
+		FrontInset: 2,
+		BackInset:  2,
+		Hole:       3,
+		SideTabs:   "TbtbT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flat panels have far fewer triangles
+	m0 := renderMesh(p[0], 0.5, MarchingCubes, nil, nil)
+	m1 := renderMesh(p[0], 0.5, AdaptiveDualContouring, nil, nil)
+	if r := m1.Check(); !r.OK() || len(m1.Face) > len(m0.Face)/3 {
+		t.Errorf("panel: %d to %d triangles, %s", len(m0.Face), len(m1.Face), r)
+	}
+
+	// curved surfaces stay accurate
+	s, err := sdf.Sphere3D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := renderMesh(s, 1, AdaptiveDualContouring, nil, nil)
+	if r := m.Check(); !r.OK() {
+		t.Errorf("sphere: %s", r)
+	}
+	for _, v := range m.Vertex {
+		if d := math.Abs(s.Evaluate(v)); d > 0.1 {
+			t.Errorf("sphere: vertex %v distance %f", v, d)
+		}
+	}
+
+	// polygons are only dropped as duplicates if they have the same vertex order
+	for _, test := range []struct {
+		a, b []int
+		same bool
+	}{
+		{[]int{1, 2, 3, 4}, []int{3, 4, 1, 2}, true},
+		{[]int{5, 2, 7}, []int{2, 7, 5}, true},
+		{[]int{1, 2, 3, 4}, []int{4, 3, 2, 1}, false},
+		{[]int{1, 2, 3, 4}, []int{1, 3, 2, 4}, false},
+		{[]int{1, 2, 3}, []int{1, 2, 3, 4}, false},
+	} {
+		if same := dcPolyKey(0, test.a) == dcPolyKey(0, test.b); same != test.same {
+			t.Errorf("%v %v: same key %v", test.a, test.b, same)
+		}
+	}
+	if dcPolyKey(0, []int{1, 2, 3}) == dcPolyKey(1, []int{1, 2, 3}) {
+		t.Error("same key for different axes")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_RenderErrors(t *testing.T) {
+	s, err := sdf.Sphere3D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// errors are returned
+	dir, err := ioutil.TempDir("", "sdfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ToSTL(s, 20, filepath.Join(dir, "missing", "sphere.stl")); err == nil {
+		t.Error("expected an error for a bad path")
+	}
+
+	// writers and progress reports
+	var messages []string
+	progress := OnProgress(func(p Progress) {
+		if p.Message != "" {
+			messages = append(messages, p.Message)
+		}
+	})
+	var buf bytes.Buffer
+	if err := ToSTLWriter(s, 20, &buf, progress); err != nil {
+		t.Fatal(err)
+	}
+	mesh, err := readSTL(buf.Bytes())
+	if err != nil || len(mesh) == 0 {
+		t.Errorf("stl: %d triangles, %v", len(mesh), err)
+	}
+	if len(messages) != 1 {
+		t.Errorf("progress: %v", messages)
+	}
+
+	c, err := sdf.Circle2D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := ToSVGWriter(c, 20, &buf, "fill:none;stroke:black"); err != nil || !strings.Contains(buf.String(), "<path") {
+		t.Errorf("svg: %v", err)
+	}
+	buf.Reset()
+	if err := ToDXFWriter(c, 20, &buf); err != nil || !strings.Contains(buf.String(), "LINE") {
+		t.Errorf("dxf: %v", err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Cancel(t *testing.T) {
+	s, err := sdf.Sphere3D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the final progress report has the counts
+	var last Progress
+	progress := OnProgress(func(p Progress) {
+		last = p
+	})
+	for _, m := range []Mesher{MarchingCubes, UniformMarchingCubes, DualContouring, AdaptiveDualContouring} {
+		var buf bytes.Buffer
+		if err := ToSTLWriter(s, 20, &buf, UseMesher(m), progress); err != nil {
+			t.Fatal(err)
+		}
+		if last.Triangles == 0 || last.Cells == 0 || last.Cells != last.Total || last.ETA != 0 {
+			t.Errorf("mesher %d: progress %+v", m, last)
+		}
+	}
+
+	// cancelled renders return the context error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dir, err := ioutil.TempDir("", "sdfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sphere.stl")
+	if err := ToSTL(s, 50, path, WithContext(ctx)); err != context.Canceled {
+		t.Errorf("stl: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("partial stl file not removed")
+	}
+	var buf bytes.Buffer
+	if err := ToSTLWriter(s, 50, &buf, WithContext(ctx), UseMesher(DualContouring)); err != context.Canceled || buf.Len() != 0 {
+		t.Errorf("stl writer: %v", err)
+	}
+	c, err := sdf.Circle2D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ToDXFWriter(c, 50, &buf, WithContext(ctx)); err != context.Canceled {
+		t.Errorf("dxf: %v", err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Contours(t *testing.T) {
+	// a washer and a square
+	c0, _ := sdf.Circle2D(10)
+	c1, _ := sdf.Circle2D(5)
+	washer := sdf.Difference2D(c0, c1)
+	box := sdf.Transform2D(sdf.Box2D(sdf.V2{X: 8, Y: 8}, 0), sdf.Translate2d(sdf.V2{X: 20, Y: 0}))
+	s := sdf.Union2D(washer, box)
+
+	resolution := 0.25
+	lines := renderLines(s, resolution, nil, nil)
+	contours := StitchLines(lines, resolution*stitchTolerance)
+	if len(contours) != 3 {
+		t.Fatalf("expected 3 contours, got %d", len(contours))
+	}
+	holes := 0
+	n := 0
+	for _, c := range contours {
+		if !c.Closed {
+			t.Error("open contour")
+		}
+		if c.Hole() {
+			holes++
+			if math.Abs(c.Area()+math.Pi*25) > 0.5 {
+				t.Errorf("hole area %f", c.Area())
+			}
+		}
+		for _, p := range c.Points {
+			if d := math.Abs(s.Evaluate(p)); d > 0.05 {
+				t.Errorf("point %v is %f from the surface", p, d)
+			}
+		}
+		n += len(c.Points)
+	}
+	if holes != 1 {
+		t.Errorf("expected 1 hole, got %d", holes)
+	}
+	if n != len(lines) {
+		t.Errorf("%d points from %d lines", n, len(lines))
+	}
+
+	// simplification keeps the contours within tolerance
+	contours = simplifyContours(contours, 0.05)
+	m := 0
+	for _, c := range contours {
+		for i, p0 := range c.Points {
+			p1 := c.Points[(i+1)%len(c.Points)]
+			if d := math.Abs(s.Evaluate(p0.Add(p1).MulScalar(0.5))); d > 0.1 {
+				t.Errorf("segment %v %v is %f from the surface", p0, p1, d)
+			}
+		}
+		m += len(c.Points)
+	}
+	if len(contours) != 3 || m > n/2 {
+		t.Errorf("simplified to %d contours with %d points (from %d)", len(contours), m, n)
+	}
+	// the box simplifies to its (chamfered) corners
+	for _, c := range contours {
+		if c.Points[0].X > 12 && len(c.Points) > 12 {
+			t.Errorf("box has %d points", len(c.Points))
+		}
+	}
+
+	// open polylines
+	open := StitchLines([]*Line{{{X: 1, Y: 0}, {X: 2, Y: 0}}, {{X: 0, Y: 0}, {X: 1, Y: 0}}, {{X: 2, Y: 0}, {X: 3, Y: 1}}}, tolerance)
+	if len(open) != 1 || open[0].Closed || len(open[0].Points) != 4 || open[0].Points[0] != (sdf.V2{X: 3, Y: 1}) {
+		t.Errorf("bad open contour %v", open)
+	}
+
+	// dxf output reads back as the same profile
+	var buf bytes.Buffer
+	if err := ToDXFWriter(s, 200, &buf, SimplifyContours(0.01)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "LWPOLYLINE") != 3 {
+		t.Error("expected 3 LWPOLYLINE entities")
+	}
+	s1, err := sdf.ImportDXF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []sdf.V2{{X: 0, Y: 0}, {X: 7.5, Y: 0}, {X: 20, Y: 0}, {X: 0, Y: 12}, {X: 22, Y: 3}} {
+		if d0, d1 := s.Evaluate(p), s1.Evaluate(p); math.Abs(d0-d1) > 0.1 {
+			t.Errorf("%v: %f != %f", p, d0, d1)
+		}
+	}
+
+	// svg output uses paths
+	buf.Reset()
+	if err := ToSVGWriter(s, 100, &buf, "fill:none;stroke:black"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "<path") != 3 || strings.Contains(buf.String(), "<line") {
+		t.Error("expected 3 svg paths")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_GCode(t *testing.T) {
+	// a plate with a hole
+	hole, _ := sdf.Circle2D(3)
+	s := sdf.Difference2D(sdf.Box2D(sdf.V2{X: 40, Y: 20}, 0), sdf.Transform2D(hole, sdf.Translate2d(sdf.V2{X: 10, Y: 0})))
+
+	k := &GCodeParms{
+		ToolDiameter: 2,
+		Side:         Outside,
+		Depth:        3,
+		StepDown:     1,
+		SafeZ:        5,
+		FeedRate:     600,
+		PlungeRate:   200,
+		SpindleSpeed: 12000,
+		Tabs:         2,
+		TabWidth:     2,
+		TabHeight:    1,
+	}
+	var buf bytes.Buffer
+	if err := ToGCodeWriter(s, 200, &buf, k, SimplifyContours(0.01)); err != nil {
+		t.Fatal(err)
+	}
+	code := buf.String()
+	for _, cmd := range []string{"G21", "G90", "M3 S12000", "M5", "M2"} {
+		if !strings.Contains(code, cmd) {
+			t.Errorf("missing %s", cmd)
+		}
+	}
+
+	// run the program
+	var p sdf.V3
+	var first sdf.V2
+	var path []sdf.V2 // the first pass around the plate
+	levels := map[float64]int{}
+	rises := 0
+	for _, line := range strings.Split(code, "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 || (f[0] != "G0" && f[0] != "G1") {
+			continue
+		}
+		q := p
+		for _, w := range f[1:] {
+			var v float64
+			fmt.Sscanf(w[1:], "%f", &v)
+			switch w[0] {
+			case 'X':
+				q.X = v
+			case 'Y':
+				q.Y = v
+			case 'Z':
+				q.Z = v
+			}
+		}
+		if f[0] == "G0" && q.Z < 0 {
+			t.Fatalf("rapid move into the stock: %s", line)
+		}
+		if q.Z < 0 {
+			xy := sdf.V2{X: q.X, Y: q.Y}
+			if first == (sdf.V2{}) {
+				first = xy
+			}
+			// the tool edge follows the profile
+			if d := s.Evaluate(xy); math.Abs(d-1) > 0.05 {
+				t.Errorf("%s: tool center is %f from the profile", line, d)
+			}
+			levels[q.Z]++
+			if q.Z > p.Z && p.Z < 0 {
+				rises++
+			}
+			if q.Z == -1 && (xy.X < -15 || len(path) > 0) {
+				path = append(path, xy)
+			}
+		}
+		p = q
+	}
+	// the hole is cut first
+	if d := first.Sub(sdf.V2{X: 10, Y: 0}).Length(); math.Abs(d-2) > 0.05 {
+		t.Errorf("first cut at %v", first)
+	}
+	for _, z := range []float64{-1, -2, -3} {
+		if levels[z] == 0 {
+			t.Errorf("no cuts at z = %f", z)
+		}
+	}
+	if rises != 2 {
+		t.Errorf("expected 2 tabs, got %d", rises)
+	}
+	// conventional milling goes counter-clockwise around the outside of the part
+	c := &Contour{Points: path, Closed: true}
+	if c.Area() <= 0 {
+		t.Errorf("clockwise outer contour")
+	}
+
+	if err := ToGCodeWriter(s, 200, &buf, &GCodeParms{Depth: 1, SafeZ: 1, FeedRate: 100}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Slice(t *testing.T) {
+	// a tube
+	c0, _ := sdf.Cylinder3D(10, 5, 0)
+	c1, _ := sdf.Cylinder3D(12, 2, 0)
+	s := sdf.Difference3D(c0, c1)
+
+	layers, err := SliceLayers(s, 1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 10 || math.Abs(layers[0].Z+4.5) > tolerance {
+		t.Fatalf("bad layers")
+	}
+	for _, l := range layers {
+		if len(l.Contours) != 2 {
+			t.Fatalf("z %f: %d contours", l.Z, len(l.Contours))
+		}
+		var area float64
+		for _, c := range l.Contours {
+			area += c.Area()
+		}
+		if math.Abs(area-math.Pi*21) > 0.5 {
+			t.Errorf("z %f: area %f", l.Z, area)
+		}
+	}
+
+	// multi-layer svg
+	var buf bytes.Buffer
+	if err := ToLayeredSVGWriter(s, 2, 100, &buf, "fill:black;fill-rule:evenodd"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), `<g id="layer`) != 5 || strings.Count(buf.String(), "<path") != 5 {
+		t.Error("expected 5 svg layers")
+	}
+
+	// png masks
+	img, err := SliceMask(s, 0, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 100 {
+		t.Errorf("image size %v", img.Bounds())
+	}
+	n := 0
+	for _, v := range img.Pix {
+		if v != 0 {
+			n++
+		}
+	}
+	if area := float64(n) * 0.01; math.Abs(area-math.Pi*21) > 0.5 {
+		t.Errorf("mask area %f", area)
+	}
+	if img.GrayAt(50, 50).Y != 0 || img.GrayAt(50, 5).Y != 255 {
+		t.Error("bad mask")
+	}
+
+	// layer files
+	dir, err := ioutil.TempDir("", "sdfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ToSVGLayers(s, 5, 50, filepath.Join(dir, "layer_%02d.svg"), "fill:black"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ToPNGLayers(s, 5, 0.5, filepath.Join(dir, "layer_%02d.png")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"layer_00.svg", "layer_01.svg", "layer_00.png", "layer_01.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := SliceLayers(s, 0, 100); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// stretchedSphere is a sphere with a distance field that isn't Euclidean (the gradient is 2).
+type stretchedSphere struct {
+	r float64
+}
+
+func (s stretchedSphere) Evaluate(p sdf.V3) float64 {
+	return 2 * (p.Length() - s.r)
+}
+
+func (s stretchedSphere) EvaluateInterval(b sdf.Box3) (float64, float64) {
+	d := b.MinMaxDist2(sdf.V3{})
+	return 2 * (math.Sqrt(d.X) - s.r), 2 * (math.Sqrt(d.Y) - s.r)
+}
+
+func (s stretchedSphere) BoundingBox() sdf.Box3 {
+	return sdf.NewBox3(sdf.V3{}, sdf.V3{X: 2, Y: 2, Z: 2}.MulScalar(s.r))
+}
+
+func Test_Volume(t *testing.T) {
+	s, _ := sdf.Sphere3D(5)
+	v, err := NewVolume(s, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := []sdf.V3{{X: 0, Y: 0, Z: 0}, {X: 1.3, Y: 2.7, Z: -0.6}, {X: 4.9, Y: 0.2, Z: 0.1}, {X: -3.1, Y: 3.3, Z: 1.7}, {X: 0, Y: 0, Z: 5.6}, {X: 20, Y: 0, Z: 0}}
+	for _, interp := range []sdf.Interpolation{sdf.Trilinear, sdf.Tricubic} {
+		vs, err := v.SDF3(interp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range test {
+			if d0, d1 := s.Evaluate(p), vs.Evaluate(p); math.Abs(d0-d1) > 0.02 {
+				t.Errorf("interpolation %d, %v: %f != %f", interp, p, d1, d0)
+			}
+		}
+	}
+
+	// file formats
+	var buf bytes.Buffer
+	var sizes []int
+	for _, write := range []func(io.Writer) error{v.WriteRaw, v.WriteNRRD, v.WriteSparse} {
+		buf.Reset()
+		if err := write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, buf.Len())
+		v1, err := ReadVolume(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v1.Size != v.Size || v1.Spacing != v.Spacing || !v1.Origin.Equals(v.Origin, tolerance) {
+			t.Fatalf("bad volume header %v %v %v", v1.Size, v1.Spacing, v1.Origin)
+		}
+		for i, d := range v.Data {
+			d1 := v1.Data[i]
+			// sparse volumes keep the samples near the surface
+			if d1 != d && (math.Abs(float64(d)) <= v.Spacing || d1*d <= 0 || math.Abs(float64(d1)) > math.Abs(float64(d))) {
+				t.Fatalf("sample %d: %f != %f", i, d1, d)
+			}
+		}
+	}
+	if sizes[2] >= sizes[0] {
+		t.Error("expected a smaller sparse volume")
+	}
+
+	// sparse sampling
+	v2, err := NewSparseVolume(s, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for i, d := range v.Data {
+		d2 := v2.Data[i]
+		if d2 != d {
+			n++
+			if math.Abs(float64(d)) <= v.Spacing || d2*d <= 0 || math.Abs(float64(d2)) > math.Abs(float64(d)) {
+				t.Fatalf("sample %d: %f != %f", i, d2, d)
+			}
+		}
+	}
+	if n == 0 {
+		t.Error("expected sparse sampling")
+	}
+
+	// sparse samples of a non-Euclidean field are bounded by the interval evaluator
+	ss := stretchedSphere{5}
+	v, err = NewVolume(ss, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err = NewSparseVolume(ss, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range v.Data {
+		if d2 := v2.Data[i]; d2 != d && (d2*d <= 0 || math.Abs(float64(d2)) > math.Abs(float64(d))) {
+			t.Fatalf("stretched sample %d: %f != %f", i, d2, d)
+		}
+	}
+
+	// nrrd vectors may have spaces within them
+	buf.Reset()
+	buf.WriteString("NRRD0004\ntype: float\ndimension: 3\nsizes: 2 2 2\n" +
+		"space directions: (0.5, 0, 0) (0, 0.5, 0) (0, 0, 0.5)\nspace origin: (1, 2, 3)\nencoding: raw\n\n")
+	binary.Write(&buf, binary.LittleEndian, make([]float32, 8))
+	v, err = ReadVolume(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Spacing != 0.5 || v.Origin != (sdf.V3{X: 1, Y: 2, Z: 3}) {
+		t.Errorf("bad nrrd header %v %v", v.Spacing, v.Origin)
+	}
+
+	if _, err := ReadVolume(strings.NewReader("NRRD0004\ntype: short\n\n")); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Engine(t *testing.T) {
+	e := NewEngine(4)
+	defer e.Close()
+	if e.Workers() != 4 {
+		t.Fatalf("expected 4 workers, got %d", e.Workers())
+	}
+
+	// every task runs once, nested jobs don't deadlock
+	const n = 100
+	var count [n][n]int32
+	var busy [5]int32
+	e.Run(n, func(w, i int) {
+		if w < 0 || w > e.Workers() {
+			t.Errorf("bad worker index %d", w)
+			return
+		}
+		if atomic.AddInt32(&busy[w], 1) != 1 {
+			t.Errorf("worker index %d is in use", w)
+		}
+		atomic.AddInt32(&busy[w], -1)
+		e.Run(n, func(_, j int) {
+			atomic.AddInt32(&count[i][j], 1)
+		})
+	})
+	for i := range count {
+		for j := range count[i] {
+			if count[i][j] != 1 {
+				t.Fatalf("task %d,%d ran %d times", i, j, count[i][j])
+			}
+		}
+	}
+
+	// batched evaluation
+	s, err := sdf.Sphere3D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]sdf.V3, 1000)
+	for i := range p {
+		p[i] = sdf.V3{X: float64(i) * 0.02, Y: 1, Z: 2}
+	}
+	out := make([]float64, len(p))
+	e.Evaluate3(s, p, out)
+	for i := range p {
+		if out[i] != s.Evaluate(p[i]) {
+			t.Fatalf("point %d: %f != %f", i, out[i], s.Evaluate(p[i]))
+		}
+	}
+
+	// an engine can run jobs after it is closed
+	c := NewEngine(2)
+	c.Close()
+	var sum int64
+	c.Run(n, func(_, i int) {
+		atomic.AddInt64(&sum, int64(i))
+	})
+	if sum != n*(n-1)/2 {
+		t.Errorf("closed engine: sum %d", sum)
+	}
+
+	// the meshers give the same result with any number of workers
+	b, err := sdf.Box3D(sdf.V3{X: 10, Y: 20, Z: 30}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []Mesher{MarchingCubes, DualContouring, UniformMarchingCubes} {
+		var faces [2]int
+		for k, o := range []RenderOption{Workers(1), UseEngine(e)} {
+			cfg := newRenderConfig([]RenderOption{UseMesher(m), o})
+			faces[k] = len(cfg.mesh(b, 1).Face)
+		}
+		if faces[0] == 0 || faces[0] != faces[1] {
+			t.Errorf("mesher %d: %d != %d faces", m, faces[0], faces[1])
+		}
+	}
+	c2, err := sdf.Circle2D(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines [2]int
+	for k, w := range []*Engine{sharedEngine(1), e} {
+		lines[k] = len(renderLines(c2, 0.1, w, nil))
+	}
+	if lines[0] == 0 || lines[0] != lines[1] {
+		t.Errorf("marching squares: %d != %d lines", lines[0], lines[1])
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_DistCache(t *testing.T) {
+	// the 64-bit fields of every slot are aligned for atomic operations on 32-bit platforms
+	if size := unsafe.Sizeof(cacheSlot{}); size%8 != 0 {
+		t.Errorf("cache slot size %d", size)
+	}
+	if size := unsafe.Sizeof(cacheShard{}); size != cacheLine {
+		t.Errorf("cache shard size %d", size)
+	}
+
+	// concurrent writers and readers, with more keys than fit in the first tables
+	c := newDistCache()
+	const n = 64
+	const m = 1 << 12
+	e := NewEngine(8)
+	defer e.Close()
+	e.Run(n, func(_, i int) {
+		for j := 0; j < m; j++ {
+			k := sdf.V3i{i, j, i ^ j}
+			if d, ok := c.read(key3(k)); ok && d != float64(i*m+j) {
+				t.Errorf("%v: read %f before it was written", k, d)
+			}
+			c.write(key3(k), float64(i*m+j))
+			// the other writers' keys
+			k = sdf.V3i{(i + 1) % n, j, ((i + 1) % n) ^ j}
+			if d, ok := c.read(key3(k)); ok && d != float64(k[0]*m+j) {
+				t.Errorf("%v: %f != %d", k, d, k[0]*m+j)
+			}
+		}
+	})
+	for i := 0; i < n; i++ {
+		for j := 0; j < m; j++ {
+			k := sdf.V3i{i, j, i ^ j}
+			if d, ok := c.read(key3(k)); !ok || d != float64(i*m+j) {
+				t.Fatalf("%v: %f, %v", k, d, ok)
+			}
+		}
+	}
+	if _, ok := c.read(key3(sdf.V3i{n, 0, 0})); ok {
+		t.Error("found a missing key")
+	}
+	if key2(sdf.V2i{1, 2}) == key2(sdf.V2i{2, 1}) || key3(sdf.V3i{1, 2, 3}) == key3(sdf.V3i{3, 2, 1}) {
+		t.Error("keys are not unique")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_IntervalPruning(t *testing.T) {
+	// twisted and scaled extrusions aren't distance fields,
+	// the octree meshers rely on interval bounds to find all of the surface
+	b := sdf.Transform2D(sdf.Box2D(sdf.V2{X: 3, Y: 3}, 0), sdf.Translate2d(sdf.V2{X: 6, Y: 0}))
+	for i, s := range []sdf.SDF3{
+		sdf.TwistExtrude3D(b, 10, sdf.Pi),
+		sdf.ScaleExtrude3D(b, 10, sdf.V2{X: 0.2, Y: 3}),
+	} {
+		for _, m := range []Mesher{MarchingCubes, DualContouring} {
+			r := renderMesh(s, 1, m, nil, nil).Check()
+			if !r.OK() || r.Components != 1 {
+				t.Errorf("%d, mesher %d: %s", i, m, r)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Screw(t *testing.T) {
+	// multi-start threads stretch space, the meshers need
+	// corrected distances to find all of the thread at coarse resolutions
+	iso, err := sdf.ISOThread(2, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := sdf.Screw3D(iso, 6, 1, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range []float64{0.25, 0.5} {
+		for _, m := range []Mesher{MarchingCubes, DualContouring} {
+			// dual contouring may have non-manifold edges for thin features
+			r := renderMesh(s, res, m, nil, nil).Check()
+			if len(r.BoundaryEdges) != 0 || r.Components != 1 {
+				t.Errorf("resolution %f, mesher %d: %s", res, m, r)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
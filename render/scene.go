@@ -1,10 +1,9 @@
 package render
 
 import (
-	"fmt"
+	"context"
 	"math"
 	"math/rand"
-	"sync"
 	"time"
 )
 
@@ -99,12 +98,29 @@ func (scene *Scene) render(rnd Rnd, pixel *pixel, raysPerPixel int) uint32 {
 // Render is the main method of a scene. It is non blocking and returns right away with the array of pixels
 // that will be computed asynchronously and a channel to indicate when the processing is complete. Note that
 // no synchronization is required on the array of pixels since it is an array of 32 bits values.
-// The image (width x height) will be split in lines each one processed in a separate goroutine (parallelCount
-// of them). The image will be progressively rendered using the passes defined in raysPerPixel
+// The image (width x height) will be split in lines processed in parallel by an evaluation engine with
+// parallelCount workers. The image will be progressively rendered using the passes defined in raysPerPixel
 func (scene *Scene) Render(parallelCount int) (Pixels, chan struct{}) {
-	pixels := make([]uint32, scene.width*scene.height)
+	pixels, errc := scene.RenderContext(context.Background(), parallelCount, printProgress)
 	completed := make(chan struct{})
 
+	go func() {
+		<-errc
+		// signal completion
+		completed <- struct{}{}
+	}()
+
+	return pixels, completed
+}
+
+// RenderContext is the same as Render but stops early when the context is cancelled. Progress is reported
+// to fn (if not nil) with Cells counting the rays cast so far. The returned channel receives nil when the
+// image is complete, or the context error if the rendering was cancelled.
+func (scene *Scene) RenderContext(ctx context.Context, parallelCount int, fn ProgressFunc) (Pixels, <-chan error) {
+	pixels := make([]uint32, scene.width*scene.height)
+	errc := make(chan error, 1)
+	t := newTracker(ctx, fn)
+
 	go func() {
 		allPixelsToProcess := make([]*pixel, scene.width*scene.height)
 
@@ -125,6 +141,17 @@ func (scene *Scene) Render(parallelCount int) (Pixels, chan struct{}) {
 		for _, rpp := range scene.raysPerPixel {
 			totalRaysPerPixel += rpp
 		}
+		t.setTotal(int64(scene.width * scene.height * totalRaysPerPixel))
+
+		// the lines are rendered by parallelCount workers
+		e := sharedEngine(parallelCount)
+
+		// due to high contention on global rand, each worker uses its own random number generator
+		// thus avoiding massive slowdown
+		rnd := make([]*rand.Rand, e.Workers()+1)
+		for i := range rnd {
+			rnd[i] = rand.New(rand.NewSource(rand.Int63()))
+		}
 
 		totalStart := time.Now()
 		accumulatedRaysPerPixel := 0
@@ -134,51 +161,32 @@ func (scene *Scene) Render(parallelCount int) (Pixels, chan struct{}) {
 
 			loopStart := time.Now()
 
-			// creates a channel which will be used to dispatch the line to process to each go routine
-			pixelsToProcess := make(chan []*pixel)
-
-			// asynchronously dispatch the lines to process
-			go func() {
-				for _, p := range lines {
-					pixelsToProcess <- p
+			// render the lines in parallel
+			e.Run(len(lines), func(w, i int) {
+				if t.cancelled() {
+					return
 				}
-				// done... signal the end
-				close(pixelsToProcess)
-			}()
-
-			// create a wait group to wait until all goroutine completes
-			wg := sync.WaitGroup{}
-
-			// create parallelCount goroutines
-			for c := 0; c < parallelCount; c++ {
-				wg.Add(1)
-				go func() {
-					// due to high contention on global rand, each goroutine uses its own random number generator
-					// thus avoiding massive slowdown
-					rnd := rand.New(rand.NewSource(rand.Int63()))
-
-					// process a bunch of pixels (in this case a line)
-					for ps := range pixelsToProcess {
-
-						// redisplay the line without gamma correction => make it darker to be more visible
-						for _, p := range ps {
-							if p.raysPerPixel > 0 {
-								col := p.color.Scale(1.0 / float64(p.raysPerPixel))
-								pixels[p.k] = col.PixelValue()
-							}
-						}
-
-						// render every pixel in the line
-						for _, p := range ps {
-							pixels[p.k] = scene.render(rnd, p, rpp)
-						}
+				ps := lines[i]
+
+				// redisplay the line without gamma correction => make it darker to be more visible
+				for _, p := range ps {
+					if p.raysPerPixel > 0 {
+						col := p.color.Scale(1.0 / float64(p.raysPerPixel))
+						pixels[p.k] = col.PixelValue()
 					}
-					wg.Done()
-				}()
-			}
+				}
 
-			// wait for the pass to be completed
-			wg.Wait()
+				// render every pixel in the line
+				for _, p := range ps {
+					pixels[p.k] = scene.render(rnd[w], p, rpp)
+				}
+				t.addCells(int64(len(ps) * rpp))
+			})
+
+			if err := t.err(); err != nil {
+				errc <- err
+				return
+			}
 
 			// compute stats for the pass
 			accumulatedRaysPerPixel += rpp
@@ -189,14 +197,13 @@ func (scene *Scene) Render(parallelCount int) (Pixels, chan struct{}) {
 			estimatedTotalTime := time.Duration(float64(totalTimeSoFar) * float64(totalRaysPerPixel) / float64(accumulatedRaysPerPixel))
 			erm := estimatedTotalTime - totalTimeSoFar
 
-			fmt.Printf("Processed %v rays per pixel in %v. Total %v in %v. ERM %v\n", rpp, time.Now().Sub(loopStart), accumulatedRaysPerPixel, totalTimeSoFar, erm)
+			t.report("Processed %v rays per pixel in %v. Total %v in %v. ERM %v", rpp, time.Now().Sub(loopStart), accumulatedRaysPerPixel, totalTimeSoFar, erm)
 		}
 
-		// signal completion
-		completed <- struct{}{}
+		errc <- nil
 	}()
 
-	return pixels, completed
+	return pixels, errc
 }
 
 // color computes the color of the ray by checking which hitable gets hit and scattering
@@ -0,0 +1,141 @@
+//-----------------------------------------------------------------------------
+/*
+
+Ray Tracing of SDF3s
+
+Sphere trace any SDF3 so it can be placed in a scene.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"math"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+const sdfMaxSteps = 512    // maximum number of sphere tracing steps per ray
+const sdfBisectSteps = 32  // maximum number of bisection steps to locate a surface
+const sdfEpsilon = 1e-5    // surface tolerance relative to the bounding box size
+const sdfBoxPadding = 1.01 // bounding box scaling to avoid surfaces on the box boundary
+
+// SDFHitable is a Hitable that ray traces an SDF3 by sphere tracing.
+type SDFHitable struct {
+	sdf      sdf.SDF3 // the sdf3 being ray traced
+	material Material // the material for the surface of the sdf3
+	epsilon  float64  // surface tolerance
+	bb       sdf.Box3 // bounding box for the ray marching
+}
+
+// NewSDFHitable returns a Hitable for an SDF3 with a given material.
+func NewSDFHitable(s sdf.SDF3, material Material) *SDFHitable {
+	bb := s.BoundingBox().ScaleAboutCenter(sdfBoxPadding)
+	return &SDFHitable{
+		sdf:      s,
+		material: material,
+		epsilon:  sdfEpsilon * bb.Size().MaxComponent(),
+		bb:       bb,
+	}
+}
+
+// clip returns the t-range of a ray within the bounding box.
+func (h *SDFHitable) clip(r *Ray3, tMin, tMax float64) (float64, float64, bool) {
+	o := [3]float64{r.Origin.X, r.Origin.Y, r.Origin.Z}
+	d := [3]float64{r.Direction.X, r.Direction.Y, r.Direction.Z}
+	bMin := [3]float64{h.bb.Min.X, h.bb.Min.Y, h.bb.Min.Z}
+	bMax := [3]float64{h.bb.Max.X, h.bb.Max.Y, h.bb.Max.Z}
+	t0, t1 := tMin, tMax
+	for i := 0; i < 3; i++ {
+		if d[i] == 0 {
+			// the ray is parallel to the slab
+			if o[i] < bMin[i] || o[i] > bMax[i] {
+				return 0, 0, false
+			}
+			continue
+		}
+		inv := 1 / d[i]
+		tNear := (bMin[i] - o[i]) * inv
+		tFar := (bMax[i] - o[i]) * inv
+		if tNear > tFar {
+			tNear, tFar = tFar, tNear
+		}
+		t0 = math.Max(t0, tNear)
+		t1 = math.Min(t1, tFar)
+		if t0 > t1 {
+			return 0, 0, false
+		}
+	}
+	return t0, t1, true
+}
+
+// normal returns the surface normal at p using central differences.
+func (h *SDFHitable) normal(p sdf.V3) sdf.V3 {
+	return sdfNormal(h.sdf, p, h.epsilon)
+}
+
+// bisect locates the surface crossing between t0 (on side) and t1 (not on side).
+func (h *SDFHitable) bisect(r *Ray3, t0, t1, side, tolerance float64) float64 {
+	for i := 0; i < sdfBisectSteps && t1-t0 > tolerance; i++ {
+		t := 0.5 * (t0 + t1)
+		if h.sdf.Evaluate(r.PointAt(t))*side > 0 {
+			t0 = t
+		} else {
+			t1 = t
+		}
+	}
+	return t1
+}
+
+// Hit implements the Hitable interface for an SDF3.
+// The ray is sphere traced from the point at which it enters the bounding box
+// until the sign of the distance function changes.
+func (h *SDFHitable) Hit(r *Ray3, tMin float64, tMax float64) (bool, *HitRecord) {
+	l := r.Direction.Length()
+	if l == 0 {
+		return false, nil
+	}
+	t0, t1, ok := h.clip(r, tMin, tMax)
+	if !ok {
+		return false, nil
+	}
+
+	// work out which side of the surface the ray starts on
+	t := t0
+	d := h.sdf.Evaluate(r.PointAt(t))
+	side := 1.0
+	if math.Abs(d) < h.epsilon {
+		// The ray starts on the surface (e.g. a scattered ray).
+		// The ray direction determines the side it is moving into.
+		if h.normal(r.PointAt(t)).Dot(r.Direction) < 0 {
+			side = -1.0
+		}
+	} else if d < 0 {
+		side = -1.0
+	}
+
+	// minimum step size in t units
+	tEpsilon := h.epsilon / l
+
+	for i := 0; i < sdfMaxSteps && t <= t1; i++ {
+		tNext := t + math.Max(math.Abs(d)/l, tEpsilon)
+		dNext := h.sdf.Evaluate(r.PointAt(tNext))
+		if d*side > 0 && dNext*side <= 0 {
+			// the surface lies between t and tNext
+			tHit := h.bisect(r, t, tNext, side, 0.1*tEpsilon)
+			if tHit < tMin || tHit > tMax {
+				return false, nil
+			}
+			p := r.PointAt(tHit)
+			return true, RecordHit(tHit, p, h.normal(p), h.material)
+		}
+		t, d = tNext, dNext
+	}
+
+	return false, nil
+}
+
+//-----------------------------------------------------------------------------
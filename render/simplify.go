@@ -0,0 +1,414 @@
+//-----------------------------------------------------------------------------
+/*
+
+Mesh Simplification
+
+Quadric error edge collapse decimation.
+See: Surface Simplification Using Quadric Error Metrics, Garland & Heckbert, 1997
+
+Each vertex has a quadric giving the sum of squared distances to the planes
+of its faces. Edges are collapsed in order of least error until the target
+face count or error bound is reached. Collapses that would fold faces over or
+make the mesh non-manifold are skipped.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// boundaryWeight scales the quadrics that keep boundary edges in place.
+const boundaryWeight = 1000.0
+
+// foldLimit is the minimum cosine between old and new face normals for a collapse.
+const foldLimit = 0.2
+
+//-----------------------------------------------------------------------------
+// Quadrics
+
+// quadric is a symmetric 4x4 matrix stored as its upper triangle.
+// a2, ab, ac, ad, b2, bc, bd, c2, cd, d2
+type quadric [10]float64
+
+// planeQuadric returns the quadric for the plane n.p + d = 0 (n is a unit vector).
+func planeQuadric(n sdf.V3, d float64) quadric {
+	return quadric{
+		n.X * n.X, n.X * n.Y, n.X * n.Z, n.X * d,
+		n.Y * n.Y, n.Y * n.Z, n.Y * d,
+		n.Z * n.Z, n.Z * d,
+		d * d,
+	}
+}
+
+// add returns the sum of two quadrics.
+func (q quadric) add(r quadric) quadric {
+	for i := range q {
+		q[i] += r[i]
+	}
+	return q
+}
+
+// mulScalar returns the quadric scaled by k.
+func (q quadric) mulScalar(k float64) quadric {
+	for i := range q {
+		q[i] *= k
+	}
+	return q
+}
+
+// eval returns the quadric error at p.
+func (q quadric) eval(p sdf.V3) float64 {
+	x, y, z := p.X, p.Y, p.Z
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x +
+		q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y +
+		q[7]*z*z + 2*q[8]*z +
+		q[9]
+}
+
+// minimum returns the point minimising the quadric error.
+// It returns false if the quadric is (nearly) singular.
+func (q quadric) minimum() (sdf.V3, bool) {
+	a := [3][3]float64{
+		{q[0], q[1], q[2]},
+		{q[1], q[4], q[5]},
+		{q[2], q[5], q[7]},
+	}
+	b := [3]float64{-q[3], -q[6], -q[8]}
+	det := a[0][0]*(a[1][1]*a[2][2]-a[1][2]*a[2][1]) -
+		a[0][1]*(a[1][0]*a[2][2]-a[1][2]*a[2][0]) +
+		a[0][2]*(a[1][0]*a[2][1]-a[1][1]*a[2][0])
+	scale := math.Max(a[0][0]+a[1][1]+a[2][2], epsilon)
+	if math.Abs(det) < 1e-6*scale*scale*scale {
+		return sdf.V3{}, false
+	}
+	// Cramer's rule
+	var x [3]float64
+	for i := 0; i < 3; i++ {
+		m := a
+		for j := 0; j < 3; j++ {
+			m[j][i] = b[j]
+		}
+		x[i] = (m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+			m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+			m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])) / det
+	}
+	return sdf.V3{X: x[0], Y: x[1], Z: x[2]}, true
+}
+
+//-----------------------------------------------------------------------------
+// Collapse Queue
+
+// collapse is a candidate edge collapse of vertex b into vertex a.
+type collapse struct {
+	a, b   int     // vertex indices
+	p      sdf.V3  // new vertex position
+	cost   float64 // quadric error of the collapse
+	va, vb int     // vertex versions when the collapse was queued
+}
+
+// collapseQueue is a priority queue of edge collapses ordered by cost.
+type collapseQueue []*collapse
+
+func (q collapseQueue) Len() int            { return len(q) }
+func (q collapseQueue) Less(i, j int) bool  { return q[i].cost < q[j].cost }
+func (q collapseQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *collapseQueue) Push(x interface{}) { *q = append(*q, x.(*collapse)) }
+func (q *collapseQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
+
+//-----------------------------------------------------------------------------
+
+// simplifier holds the working state for mesh simplification.
+type simplifier struct {
+	m       *Mesh
+	q       []quadric // vertex quadrics
+	faces   [][]int   // faces using each vertex
+	deleted []bool    // deleted faces
+	version []int     // vertex versions, incremented when a vertex changes (-1 = removed)
+	queue   collapseQueue
+	n       int // number of live faces
+}
+
+// newSimplifier builds the vertex quadrics and face adjacency for a mesh.
+func newSimplifier(m *Mesh) *simplifier {
+	s := &simplifier{
+		m:       m,
+		q:       make([]quadric, len(m.Vertex)),
+		faces:   make([][]int, len(m.Vertex)),
+		deleted: make([]bool, len(m.Face)),
+		version: make([]int, len(m.Vertex)),
+		n:       len(m.Face),
+	}
+	for i, f := range m.Face {
+		n, ok := s.normal(f)
+		if ok {
+			pq := planeQuadric(n, -n.Dot(m.Vertex[f[0]]))
+			for _, v := range f {
+				s.q[v] = s.q[v].add(pq)
+			}
+		}
+		for _, v := range f {
+			s.faces[v] = append(s.faces[v], i)
+		}
+	}
+	// hold boundary edges in place with planes perpendicular to their faces
+	for e, faces := range m.Edges() {
+		if len(faces) != 1 {
+			continue
+		}
+		n, ok := s.normal(m.Face[faces[0]])
+		if !ok {
+			continue
+		}
+		a, b := m.Vertex[e[0]], m.Vertex[e[1]]
+		en := b.Sub(a).Cross(n)
+		if en.Length() < epsilon {
+			continue
+		}
+		en = en.Normalize()
+		pq := planeQuadric(en, -en.Dot(a)).mulScalar(boundaryWeight)
+		s.q[e[0]] = s.q[e[0]].add(pq)
+		s.q[e[1]] = s.q[e[1]].add(pq)
+	}
+	return s
+}
+
+// triangleNormal returns the unit normal of a triangle.
+func triangleNormal(a, b, c sdf.V3) (sdf.V3, bool) {
+	n := b.Sub(a).Cross(c.Sub(a))
+	l := n.Length()
+	if l < epsilon {
+		return sdf.V3{}, false
+	}
+	return n.DivScalar(l), true
+}
+
+// normal returns the unit normal of a face.
+func (s *simplifier) normal(f TriangleI) (sdf.V3, bool) {
+	v := s.m.Vertex
+	return triangleNormal(v[f[0]], v[f[1]], v[f[2]])
+}
+
+// neighbours returns the vertices connected to vertex a by an edge.
+func (s *simplifier) neighbours(a int) map[int]bool {
+	n := make(map[int]bool)
+	for _, i := range s.faces[a] {
+		for _, v := range s.m.Face[i] {
+			if v != a {
+				n[v] = true
+			}
+		}
+	}
+	return n
+}
+
+// push queues the collapse of the edge between vertices a and b.
+func (s *simplifier) push(a, b int) {
+	q := s.q[a].add(s.q[b])
+	pa, pb := s.m.Vertex[a], s.m.Vertex[b]
+	p, ok := q.minimum()
+	if !ok || p.Sub(pa.Add(pb).MulScalar(0.5)).Length() > pa.Sub(pb).Length() {
+		// no well defined minimum, choose the best point on the edge
+		p = pa
+		for _, x := range []sdf.V3{pb, pa.Add(pb).MulScalar(0.5)} {
+			if q.eval(x) < q.eval(p) {
+				p = x
+			}
+		}
+	}
+	heap.Push(&s.queue, &collapse{
+		a:    a,
+		b:    b,
+		p:    p,
+		cost: math.Max(q.eval(p), 0),
+		va:   s.version[a],
+		vb:   s.version[b],
+	})
+}
+
+// valid returns true if collapsing b into a at p keeps the mesh manifold
+// and does not fold any faces over.
+func (s *simplifier) valid(a, b int, p sdf.V3) bool {
+	// The vertices shared by a and b must be exactly those of the faces on edge ab.
+	na := s.neighbours(a)
+	shared := 0
+	for v := range s.neighbours(b) {
+		if na[v] {
+			shared++
+		}
+	}
+	edgeFaces := 0
+	for _, i := range s.faces[a] {
+		if s.m.Face[i].hasEdge(a, b) {
+			edgeFaces++
+		}
+	}
+	if shared != edgeFaces {
+		return false
+	}
+	// The faces that remain must not flip or become degenerate.
+	for _, v := range []int{a, b} {
+		for _, i := range s.faces[v] {
+			f := s.m.Face[i]
+			if f.hasEdge(a, b) {
+				continue
+			}
+			n0, ok := s.normal(f)
+			if !ok {
+				continue
+			}
+			var t [3]sdf.V3
+			for j := range f {
+				t[j] = s.m.Vertex[f[j]]
+				if f[j] == v {
+					t[j] = p
+				}
+			}
+			n1, ok := triangleNormal(t[0], t[1], t[2])
+			if !ok || n0.Dot(n1) < foldLimit {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// apply collapses vertex b into vertex a at position p.
+func (s *simplifier) apply(a, b int, p sdf.V3) {
+	s.m.Vertex[a] = p
+	s.q[a] = s.q[a].add(s.q[b])
+	var opposite []int
+	for _, i := range s.faces[b] {
+		if s.deleted[i] {
+			continue
+		}
+		f := &s.m.Face[i]
+		if f.hasEdge(a, b) {
+			s.deleted[i] = true
+			s.n--
+			for _, v := range f {
+				if v != a && v != b {
+					opposite = append(opposite, v)
+				}
+			}
+			continue
+		}
+		for j := range f {
+			if f[j] == b {
+				f[j] = a
+			}
+		}
+		s.faces[a] = append(s.faces[a], i)
+	}
+	// remove the deleted faces from the adjacency of their remaining vertices
+	s.prune(a)
+	for _, v := range opposite {
+		s.prune(v)
+	}
+	s.faces[b] = nil
+	s.version[a]++
+	s.version[b] = -1
+	for v := range s.neighbours(a) {
+		s.push(a, v)
+	}
+}
+
+// prune removes the deleted faces from the adjacency of vertex v.
+func (s *simplifier) prune(v int) {
+	faces := s.faces[v][:0]
+	for _, i := range s.faces[v] {
+		if !s.deleted[i] {
+			faces = append(faces, i)
+		}
+	}
+	s.faces[v] = faces
+}
+
+// run collapses edges until the face count or error limit is reached.
+// maxError <= 0 means there is no error limit.
+func (s *simplifier) run(faces int, maxError float64) {
+	for e := range s.m.Edges() {
+		s.push(e[0], e[1])
+	}
+	maxCost := math.Inf(1)
+	if maxError > 0 {
+		maxCost = maxError*maxError + tolerance
+	}
+	for s.n > faces && s.queue.Len() > 0 {
+		c := heap.Pop(&s.queue).(*collapse)
+		if s.version[c.a] != c.va || s.version[c.b] != c.vb {
+			// stale queue entry
+			continue
+		}
+		if c.cost > maxCost {
+			break
+		}
+		if !s.valid(c.a, c.b, c.p) {
+			continue
+		}
+		s.apply(c.a, c.b, c.p)
+	}
+}
+
+// result rebuilds the mesh from the remaining vertices and faces.
+func (s *simplifier) result() {
+	m := s.m
+	index := make([]int, len(m.Vertex))
+	for i := range index {
+		index[i] = -1
+	}
+	var vertex []sdf.V3
+	var face []TriangleI
+	for i, f := range m.Face {
+		if s.deleted[i] {
+			continue
+		}
+		for j, v := range f {
+			if index[v] < 0 {
+				index[v] = len(vertex)
+				vertex = append(vertex, m.Vertex[v])
+			}
+			f[j] = index[v]
+		}
+		face = append(face, f)
+	}
+	m.Vertex = vertex
+	m.Face = face
+	m.weld = newVertexSet(m.weld.tolerance)
+	for i, v := range m.Vertex {
+		m.weld.add(v, i)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// Simplify reduces the number of faces in the mesh by collapsing edges.
+// Collapsing stops when the mesh has no more than faces faces, or when the
+// quadric error of the next collapse is more than maxError squared. The quadric
+// error is the sum of the squared distances from the new vertex to the planes of
+// the original faces around it, so each of those distances is no more than maxError.
+// Use faces <= 0 for no face limit and maxError <= 0 for no error limit, with
+// neither limit the mesh is left as it is.
+func (m *Mesh) Simplify(faces int, maxError float64) {
+	if faces <= 0 && maxError <= 0 {
+		return
+	}
+	s := newSimplifier(m)
+	s.run(faces, maxError)
+	s.result()
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,273 @@
+//-----------------------------------------------------------------------------
+/*
+
+Layer Slicing
+
+Slice an SDF3 into horizontal layers for layer based printing.
+Each layer is sampled at the middle of its height.
+
+The layers can be written as:
+
+* a stack of SVG files (one per layer)
+* a single SVG file with a group per layer
+* a stack of PNG masks (white is solid) for resin (MSLA) printers
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	svg "github.com/ajstarks/svgo/float"
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// Layer is a horizontal slice of an SDF3.
+type Layer struct {
+	Z        float64    // height of the slice
+	Contours []*Contour // outer contours are counter-clockwise, holes are clockwise
+}
+
+// layerHeights returns the slice heights for the layers of an SDF3.
+func layerHeights(s sdf.SDF3, layerHeight float64) ([]float64, error) {
+	if layerHeight <= 0 {
+		return nil, errors.New("layerHeight <= 0")
+	}
+	bb := s.BoundingBox()
+	n := int(math.Ceil(bb.Size().Z/layerHeight - epsilon))
+	z := make([]float64, n)
+	for i := range z {
+		z[i] = bb.Min.Z + (float64(i)+0.5)*layerHeight
+	}
+	return z, nil
+}
+
+// sliceLayer returns the slice of an SDF3 at height z.
+func sliceLayer(s sdf.SDF3, z float64) sdf.SDF2 {
+	return sdf.Slice2D(s, sdf.V3{X: 0, Y: 0, Z: z}, sdf.V3{X: 0, Y: 0, Z: 1})
+}
+
+// sliceBox returns the xy bounding box of an SDF3.
+func sliceBox(s sdf.SDF3) sdf.Box2 {
+	bb := s.BoundingBox()
+	return sdf.Box2{Min: sdf.V2{X: bb.Min.X, Y: bb.Min.Y}, Max: sdf.V2{X: bb.Max.X, Y: bb.Max.Y}}
+}
+
+// sliceLayers returns the contours for each layer of an SDF3.
+func sliceLayers(s sdf.SDF3, layerHeight float64, meshCells int, cfg *renderConfig) ([]*Layer, error) {
+	heights, err := layerHeights(s, layerHeight)
+	if err != nil {
+		return nil, err
+	}
+	resolution := sliceBox(s).Size().MaxComponent() / float64(meshCells)
+	cfg.report("slicing %d layers (layer height %.2f, resolution %.2f)", len(heights), layerHeight, resolution)
+	// progress is reported in layers
+	cfg.t.setTotal(int64(len(heights)))
+	layers := make([]*Layer, len(heights))
+	for i, z := range heights {
+		if err := cfg.t.err(); err != nil {
+			return nil, err
+		}
+		lines := renderLines(sliceLayer(s, z), resolution, cfg.engine, nil)
+		contours := StitchLines(lines, resolution*stitchTolerance)
+		layers[i] = &Layer{
+			Z:        z,
+			Contours: simplifyContours(contours, cfg.contourTolerance),
+		}
+		cfg.t.addCells(1)
+	}
+	cfg.t.flush()
+	return layers, nil
+}
+
+// SliceLayers slices an SDF3 into horizontal layers and returns the contours for each layer.
+func SliceLayers(
+	s sdf.SDF3, // sdf3 to slice
+	layerHeight float64, // height of each layer
+	meshCells int, // number of cells on the longest xy axis. e.g 200
+	options ...RenderOption, // optional settings
+) ([]*Layer, error) {
+	return sliceLayers(s, layerHeight, meshCells, newRenderConfig(options))
+}
+
+//-----------------------------------------------------------------------------
+// SVG Output
+
+// writeLayersSVG writes layers to an SVG file with a group per layer.
+// The contours of a layer are a single path so holes can be filled with the even-odd rule.
+func writeLayersSVG(w io.Writer, layers []*Layer, bb sdf.Box2, style string) error {
+	ew := &errWriter{w: w}
+	s := NewSVG("", style)
+	s.Bounds(bb)
+	canvas := svg.New(ew)
+	canvas.Start(s.max.X-s.min.X, s.max.Y-s.min.Y)
+	for i, l := range layers {
+		canvas.Group(fmt.Sprintf(`id="layer%d"`, i), fmt.Sprintf(`data-z="%g"`, l.Z))
+		var d strings.Builder
+		for _, c := range l.Contours {
+			d.WriteString(s.path(c, canvas.Decimals))
+		}
+		if d.Len() > 0 {
+			canvas.Path(d.String(), style)
+		}
+		canvas.Gend()
+	}
+	canvas.End()
+	return ew.err
+}
+
+// saveLayersSVG writes layers to an SVG file.
+func saveLayersSVG(path string, layers []*Layer, bb sdf.Box2, style string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeLayersSVG(f, layers, bb, style); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ToSVGLayers slices an SDF3 and writes each layer to a separate SVG file.
+// The pattern gives the filenames from the layer number, e.g. "layer_%04d.svg".
+// All the files have the same coordinates.
+func ToSVGLayers(
+	s sdf.SDF3, // sdf3 to slice
+	layerHeight float64, // height of each layer
+	meshCells int, // number of cells on the longest xy axis. e.g 200
+	pattern string, // filename pattern
+	style string, // SVG path style, e.g. "fill:black;fill-rule:evenodd"
+	options ...RenderOption, // optional settings
+) error {
+	layers, err := SliceLayers(s, layerHeight, meshCells, options...)
+	if err != nil {
+		return err
+	}
+	bb := sliceBox(s)
+	for i, l := range layers {
+		if err := saveLayersSVG(fmt.Sprintf(pattern, i), []*Layer{l}, bb, style); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToLayeredSVG slices an SDF3 and writes the layers to a single SVG file.
+// Each layer is a group with the id "layer<n>" and a "data-z" height attribute.
+func ToLayeredSVG(
+	s sdf.SDF3, // sdf3 to slice
+	layerHeight float64, // height of each layer
+	meshCells int, // number of cells on the longest xy axis. e.g 200
+	path string, // path to filename
+	style string, // SVG path style, e.g. "fill:black;fill-rule:evenodd"
+	options ...RenderOption, // optional settings
+) error {
+	layers, err := SliceLayers(s, layerHeight, meshCells, options...)
+	if err != nil {
+		return err
+	}
+	return saveLayersSVG(path, layers, sliceBox(s), style)
+}
+
+// ToLayeredSVGWriter slices an SDF3 and writes the layers as SVG to a writer.
+func ToLayeredSVGWriter(
+	s sdf.SDF3, // sdf3 to slice
+	layerHeight float64, // height of each layer
+	meshCells int, // number of cells on the longest xy axis. e.g 200
+	w io.Writer, // output writer
+	style string, // SVG path style, e.g. "fill:black;fill-rule:evenodd"
+	options ...RenderOption, // optional settings
+) error {
+	layers, err := SliceLayers(s, layerHeight, meshCells, options...)
+	if err != nil {
+		return err
+	}
+	return writeLayersSVG(w, layers, sliceBox(s), style)
+}
+
+//-----------------------------------------------------------------------------
+// PNG Masks
+
+// SliceMask returns the mask image for the slice of an SDF3 at height z.
+// The image covers the xy bounding box of the SDF3 with square pixels of the
+// given size. Pixels with centers inside the SDF3 are white.
+func SliceMask(s sdf.SDF3, z, pixelSize float64, options ...RenderOption) (*image.Gray, error) {
+	return sliceMask(s, z, pixelSize, newRenderConfig(options).engine)
+}
+
+// sliceMask returns the mask image for the slice of an SDF3 at height z.
+func sliceMask(s sdf.SDF3, z, pixelSize float64, e *Engine) (*image.Gray, error) {
+	if pixelSize <= 0 {
+		return nil, errors.New("pixelSize <= 0")
+	}
+	bb := sliceBox(s)
+	size := bb.Size().DivScalar(pixelSize).Ceil().ToV2i()
+	img := image.NewGray(image.Rect(0, 0, size[0], size[1]))
+	// sample the rows in parallel
+	e.Run(size[1], func(_, y int) {
+		p := sdf.V3{Y: bb.Max.Y - (float64(y)+0.5)*pixelSize, Z: z}
+		for x := 0; x < size[0]; x++ {
+			p.X = bb.Min.X + (float64(x)+0.5)*pixelSize
+			if s.Evaluate(p) < 0 {
+				img.SetGray(x, y, color.Gray{255})
+			}
+		}
+	})
+	return img, nil
+}
+
+// ToPNGLayers slices an SDF3 and writes each layer to a PNG mask file.
+// The pattern gives the filenames from the layer number, e.g. "layer_%04d.png".
+func ToPNGLayers(
+	s sdf.SDF3, // sdf3 to slice
+	layerHeight float64, // height of each layer
+	pixelSize float64, // size of a pixel
+	pattern string, // filename pattern
+	options ...RenderOption, // optional settings
+) error {
+	cfg := newRenderConfig(options)
+	heights, err := layerHeights(s, layerHeight)
+	if err != nil {
+		return err
+	}
+	cfg.report("slicing %d layers (layer height %.2f, pixel size %.3f)", len(heights), layerHeight, pixelSize)
+	cfg.t.setTotal(int64(len(heights)))
+	for i, z := range heights {
+		if err := cfg.t.err(); err != nil {
+			return err
+		}
+		img, err := sliceMask(s, z, pixelSize, cfg.engine)
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(fmt.Sprintf(pattern, i))
+		if err != nil {
+			return err
+		}
+		err = png.Encode(f, img)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		cfg.t.addCells(1)
+	}
+	cfg.t.flush()
+	return nil
+}
+
+//-----------------------------------------------------------------------------
@@ -10,9 +10,15 @@ package render
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/jakoblorz/sdfx/sdf"
@@ -34,52 +40,162 @@ type STLTriangle struct {
 
 //-----------------------------------------------------------------------------
 
-// SaveSTL writes a triangle mesh to an STL file.
-func SaveSTL(path string, mesh []*Triangle3) error {
-	file, err := os.Create(path)
+// LoadSTL reads a triangle mesh from a binary or ASCII STL file.
+func LoadSTL(path string) ([]*Triangle3, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return readSTL(data)
+}
+
+// readSTL parses binary or ASCII STL data.
+func readSTL(data []byte) ([]*Triangle3, error) {
+	// Some binary files also start with "solid", so check the binary size first.
+	const hdrSize = 84
+	const triSize = 50
+	if len(data) >= hdrSize {
+		count := binary.LittleEndian.Uint32(data[80:hdrSize])
+		if len(data) == hdrSize+int(count)*triSize {
+			return readSTLBinary(bytes.NewReader(data))
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return readSTLASCII(bytes.NewReader(data))
+	}
+	return nil, errors.New("unrecognised STL format")
+}
+
+// readSTLBinary reads a triangle mesh from binary STL data.
+func readSTLBinary(r io.Reader) ([]*Triangle3, error) {
+	hdr := STLHeader{}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	mesh := make([]*Triangle3, hdr.Count)
+	var d STLTriangle
+	for i := range mesh {
+		if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
+			return nil, err
+		}
+		mesh[i] = NewTriangle3(
+			sdf.V3{X: float64(d.Vertex1[0]), Y: float64(d.Vertex1[1]), Z: float64(d.Vertex1[2])},
+			sdf.V3{X: float64(d.Vertex2[0]), Y: float64(d.Vertex2[1]), Z: float64(d.Vertex2[2])},
+			sdf.V3{X: float64(d.Vertex3[0]), Y: float64(d.Vertex3[1]), Z: float64(d.Vertex3[2])},
+		)
+	}
+	return mesh, nil
+}
+
+// readSTLASCII reads a triangle mesh from ASCII STL data.
+func readSTLASCII(r io.Reader) ([]*Triangle3, error) {
+	var mesh []*Triangle3
+	var v []sdf.V3
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "vertex":
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("line %d: bad vertex", line)
+			}
+			var x [3]float64
+			for i := range x {
+				f, err := strconv.ParseFloat(fields[i+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s", line, err)
+				}
+				x[i] = f
+			}
+			v = append(v, sdf.V3{X: x[0], Y: x[1], Z: x[2]})
+		case "endloop":
+			if len(v) != 3 {
+				return nil, fmt.Errorf("line %d: facet has %d vertices", line, len(v))
+			}
+			mesh = append(mesh, NewTriangle3(v[0], v[1], v[2]))
+			v = v[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
-	defer file.Close()
+	return mesh, nil
+}
+
+// STLMesh3D returns an SDF3 for a closed triangle mesh (E.g. from LoadSTL).
+func STLMesh3D(mesh []*Triangle3) (sdf.SDF3, error) {
+	m := make([][3]sdf.V3, len(mesh))
+	for i, t := range mesh {
+		m[i] = t.V
+	}
+	return sdf.Mesh3D(m)
+}
 
-	buf := bufio.NewWriter(file)
+//-----------------------------------------------------------------------------
+
+// stlTriangle returns the STL record for a triangle.
+func stlTriangle(t *Triangle3) *STLTriangle {
+	var d STLTriangle
+	n := t.Normal()
+	d.Normal[0] = float32(n.X)
+	d.Normal[1] = float32(n.Y)
+	d.Normal[2] = float32(n.Z)
+	d.Vertex1[0] = float32(t.V[0].X)
+	d.Vertex1[1] = float32(t.V[0].Y)
+	d.Vertex1[2] = float32(t.V[0].Z)
+	d.Vertex2[0] = float32(t.V[1].X)
+	d.Vertex2[1] = float32(t.V[1].Y)
+	d.Vertex2[2] = float32(t.V[1].Z)
+	d.Vertex3[0] = float32(t.V[2].X)
+	d.Vertex3[1] = float32(t.V[2].Y)
+	d.Vertex3[2] = float32(t.V[2].Z)
+	return &d
+}
+
+// EncodeSTL writes a triangle mesh as binary STL.
+func EncodeSTL(w io.Writer, mesh []*Triangle3) error {
+	buf := bufio.NewWriter(w)
 	header := STLHeader{}
 	header.Count = uint32(len(mesh))
 	if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
 		return err
 	}
-
-	var d STLTriangle
 	for _, triangle := range mesh {
-		n := triangle.Normal()
-		d.Normal[0] = float32(n.X)
-		d.Normal[1] = float32(n.Y)
-		d.Normal[2] = float32(n.Z)
-		d.Vertex1[0] = float32(triangle.V[0].X)
-		d.Vertex1[1] = float32(triangle.V[0].Y)
-		d.Vertex1[2] = float32(triangle.V[0].Z)
-		d.Vertex2[0] = float32(triangle.V[1].X)
-		d.Vertex2[1] = float32(triangle.V[1].Y)
-		d.Vertex2[2] = float32(triangle.V[1].Z)
-		d.Vertex3[0] = float32(triangle.V[2].X)
-		d.Vertex3[1] = float32(triangle.V[2].Y)
-		d.Vertex3[2] = float32(triangle.V[2].Z)
-		if err := binary.Write(buf, binary.LittleEndian, &d); err != nil {
+		if err := binary.Write(buf, binary.LittleEndian, stlTriangle(triangle)); err != nil {
 			return err
 		}
 	}
-
 	return buf.Flush()
 }
 
+// SaveSTL writes a triangle mesh to an STL file.
+func SaveSTL(path string, mesh []*Triangle3) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := EncodeSTL(file, mesh); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 //-----------------------------------------------------------------------------
 
-// WriteSTL writes a stream of triangles to an STL file.
-func WriteSTL(wg *sync.WaitGroup, path string) (chan<- *Triangle3, error) {
+// StreamSTL writes a stream of triangles to an STL file.
+// Close the triangle channel when done, the result of the file
+// write is then sent on the error channel.
+func StreamSTL(path string) (chan<- *Triangle3, <-chan error, error) {
 
 	f, err := os.Create(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Use buffered IO for optimal IO writes.
@@ -89,91 +205,144 @@ func WriteSTL(wg *sync.WaitGroup, path string) (chan<- *Triangle3, error) {
 	// write an empty header
 	hdr := STLHeader{}
 	if err := binary.Write(buf, binary.LittleEndian, &hdr); err != nil {
-		return nil, err
+		f.Close()
+		return nil, nil, err
 	}
 
 	// External code writes triangles to this channel.
 	// This goroutine reads the channel and writes triangles to the file.
 	c := make(chan *Triangle3)
+	errc := make(chan error, 1)
 
-	wg.Add(1)
 	go func() {
-		defer wg.Done()
-		defer f.Close()
-
 		var count uint32
-		var d STLTriangle
+		var err error
 		// read triangles from the channel and write them to the file
 		for t := range c {
-			n := t.Normal()
-			d.Normal[0] = float32(n.X)
-			d.Normal[1] = float32(n.Y)
-			d.Normal[2] = float32(n.Z)
-			d.Vertex1[0] = float32(t.V[0].X)
-			d.Vertex1[1] = float32(t.V[0].Y)
-			d.Vertex1[2] = float32(t.V[0].Z)
-			d.Vertex2[0] = float32(t.V[1].X)
-			d.Vertex2[1] = float32(t.V[1].Y)
-			d.Vertex2[2] = float32(t.V[1].Z)
-			d.Vertex3[0] = float32(t.V[2].X)
-			d.Vertex3[1] = float32(t.V[2].Y)
-			d.Vertex3[2] = float32(t.V[2].Z)
-			if err := binary.Write(buf, binary.LittleEndian, &d); err != nil {
-				fmt.Printf("%s\n", err)
-				return
+			if err != nil {
+				// keep reading so the sender doesn't block
+				continue
 			}
+			err = binary.Write(buf, binary.LittleEndian, stlTriangle(t))
 			count++
 		}
 		// flush the triangles
-		buf.Flush()
-
+		if err == nil {
+			err = buf.Flush()
+		}
 		// back to the start of the file
-		if _, err := f.Seek(0, 0); err != nil {
-			fmt.Printf("%s\n", err)
-			return
+		if err == nil {
+			_, err = f.Seek(0, 0)
 		}
 		// rewrite the header with the correct mesh count
-		hdr.Count = count
-		if err := binary.Write(f, binary.LittleEndian, &hdr); err != nil {
-			fmt.Printf("%s\n", err)
-			return
+		if err == nil {
+			hdr.Count = count
+			err = binary.Write(f, binary.LittleEndian, &hdr)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
 		}
+		errc <- err
 	}()
 
+	return c, errc, nil
+}
+
+// WriteSTL writes a stream of triangles to an STL file.
+// Write errors are printed, use StreamSTL to get them.
+func WriteSTL(wg *sync.WaitGroup, path string) (chan<- *Triangle3, error) {
+	c, errc, err := StreamSTL(path)
+	if err != nil {
+		return nil, err
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := <-errc; err != nil {
+			fmt.Printf("%s\n", err)
+		}
+	}()
 	return c, nil
 }
 
 //-----------------------------------------------------------------------------
 
-// RenderSTL renders an SDF3 as an STL file (uses octree sampling).
-func RenderSTL(
-	s sdf.SDF3, //sdf3 to render
-	meshCells int, //number of cells on the longest axis. e.g 200
-	path string, //path to filename
-) {
-
-	// work out the sampling resolution to use
+// meshResolution returns the sampling resolution for an SDF3.
+func meshResolution(s sdf.SDF3, meshCells int) (float64, sdf.V3i) {
 	bbSize := s.BoundingBox().Size()
 	resolution := bbSize.MaxComponent() / float64(meshCells)
-	cells := bbSize.DivScalar(resolution).ToV3i()
+	return resolution, bbSize.DivScalar(resolution).ToV3i()
+}
 
-	fmt.Printf("rendering %s (%dx%dx%d, resolution %.2f)\n", path, cells[0], cells[1], cells[2], resolution)
+// ToSTL renders an SDF3 as an STL file.
+func ToSTL(
+	s sdf.SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	path string, //path to filename
+	options ...RenderOption, //optional settings
+) error {
+	cfg := newRenderConfig(options)
+	resolution, cells := meshResolution(s, meshCells)
+	cfg.report("rendering %s (%dx%dx%d, resolution %.2f)", path, cells[0], cells[1], cells[2], resolution)
+
+	if cfg.simplify {
+		// build the whole mesh, simplify it, then write it
+		triangles := cfg.triangles(s, resolution)
+		if err := cfg.t.err(); err != nil {
+			return err
+		}
+		return SaveSTL(path, triangles)
+	}
 
-	// write the triangles to an STL file
-	var wg sync.WaitGroup
-	output, err := WriteSTL(&wg, path)
+	// write the triangles to an STL file as they are generated
+	output, errc, err := StreamSTL(path)
 	if err != nil {
-		fmt.Printf("%s", err)
-		return
+		return err
 	}
-
-	// run marching cubes to generate the triangle mesh
-	marchingCubesOctree(s, resolution, output)
-
+	cfg.mesher.generate(s, resolution, output, cfg.engine, cfg.t)
 	// stop the STL writer reading on the channel
 	close(output)
 	// wait for the file write to complete
-	wg.Wait()
+	if err := <-errc; err != nil {
+		return err
+	}
+	if err := cfg.t.err(); err != nil {
+		// don't leave a partial file
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// ToSTLWriter renders an SDF3 as STL to a writer.
+func ToSTLWriter(
+	s sdf.SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	w io.Writer, //output writer
+	options ...RenderOption, //optional settings
+) error {
+	cfg := newRenderConfig(options)
+	resolution, cells := meshResolution(s, meshCells)
+	cfg.report("rendering stl (%dx%dx%d, resolution %.2f)", cells[0], cells[1], cells[2], resolution)
+	// the triangle count is written first, so build the whole mesh
+	triangles := cfg.triangles(s, resolution)
+	if err := cfg.t.err(); err != nil {
+		return err
+	}
+	return EncodeSTL(w, triangles)
+}
+
+// RenderSTL renders an SDF3 as an STL file (uses octree sampling).
+func RenderSTL(
+	s sdf.SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	path string, //path to filename
+	options ...RenderOption, //optional settings
+) {
+	options = append([]RenderOption{OnProgress(printProgress)}, options...)
+	if err := ToSTL(s, meshCells, path, options...); err != nil {
+		fmt.Printf("%s\n", err)
+	}
 }
 
 // RenderSTLSlow renders an SDF3 as an STL file (uses uniform grid sampling).
@@ -182,24 +351,57 @@ func RenderSTLSlow(
 	meshCells int, //number of cells on the longest axis. e.g 200
 	path string, //path to filename
 ) {
-	// work out the region we will sample
-	bb0 := s.BoundingBox()
-	bb0Size := bb0.Size()
-	meshInc := bb0Size.MaxComponent() / float64(meshCells)
-	bb1Size := bb0Size.DivScalar(meshInc)
-	bb1Size = bb1Size.Ceil().AddScalar(1)
-	cells := bb1Size.ToV3i()
-	bb1Size = bb1Size.MulScalar(meshInc)
-	bb := sdf.NewBox3(bb0.Center(), bb1Size)
-
-	fmt.Printf("rendering %s (%dx%dx%d)\n", path, cells[0], cells[1], cells[2])
-
-	// run marching cubes to generate the triangle mesh
-	m := marchingCubes(s, bb, meshInc)
-	err := SaveSTL(path, m)
-	if err != nil {
-		fmt.Printf("%s", err)
+	RenderSTL(s, meshCells, path, UseMesher(UniformMarchingCubes))
+}
+
+//-----------------------------------------------------------------------------
+
+// triangles returns the triangle mesh for an SDF3, simplified if required.
+func (cfg *renderConfig) triangles(s sdf.SDF3, resolution float64) []*Triangle3 {
+	if !cfg.simplify {
+		var triangles []*Triangle3
+		c := make(chan *Triangle3)
+		done := make(chan bool)
+		go func() {
+			for t := range c {
+				triangles = append(triangles, t)
+			}
+			done <- true
+		}()
+		cfg.mesher.generate(s, resolution, c, cfg.engine, cfg.t)
+		close(c)
+		<-done
+		return triangles
+	}
+	return cfg.mesh(s, resolution).Triangles()
+}
+
+// mesh returns the indexed mesh for an SDF3, simplified if required.
+func (cfg *renderConfig) mesh(s sdf.SDF3, resolution float64) *Mesh {
+	m := renderMesh(s, resolution, cfg.mesher, cfg.engine, cfg.t)
+	if cfg.simplify && !cfg.t.cancelled() {
+		n := len(m.Face)
+		m.Simplify(cfg.faces, cfg.maxError)
+		cfg.report("simplified %d to %d triangles", n, len(m.Face))
 	}
+	return m
+}
+
+// renderMesh returns the indexed mesh for an SDF3.
+func renderMesh(s sdf.SDF3, resolution float64, mesher Mesher, e *Engine, t *tracker) *Mesh {
+	m := NewMesh(weldTolerance)
+	c := make(chan *Triangle3)
+	done := make(chan bool)
+	go func() {
+		for t := range c {
+			m.Add(t)
+		}
+		done <- true
+	}()
+	mesher.generate(s, resolution, c, e, t)
+	close(c)
+	<-done
+	return m
 }
 
 //-----------------------------------------------------------------------------
@@ -10,7 +10,9 @@ package render
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"sync"
 
 	svg "github.com/ajstarks/svgo/float"
@@ -24,7 +26,9 @@ type SVG struct {
 	filename  string
 	lineStyle string
 	p0s, p1s  []sdf.V2
+	contours  []*Contour
 	min, max  sdf.V2
+	bounded   bool // min/max have been set
 }
 
 // NewSVG returns an SVG renderer.
@@ -35,49 +39,129 @@ func NewSVG(filename, lineStyle string) *SVG {
 	}
 }
 
-// Line outputs a line to the SVG file.
-func (s *SVG) Line(p0, p1 sdf.V2) {
-	if len(s.p0s) == 0 {
-		s.min = p0.Min(p1)
-		s.max = p0.Max(p1)
+// extend extends the SVG bounds to include a point.
+func (s *SVG) extend(p sdf.V2) {
+	if !s.bounded {
+		s.min = p
+		s.max = p
+		s.bounded = true
 	} else {
-		s.min = s.min.Min(p0)
-		s.min = s.min.Min(p1)
-		s.max = s.max.Max(p0)
-		s.max = s.max.Max(p1)
+		s.min = s.min.Min(p)
+		s.max = s.max.Max(p)
 	}
+}
+
+// Bounds extends the SVG area to include a box.
+// Use it to give a set of SVG files the same coordinates.
+func (s *SVG) Bounds(bb sdf.Box2) {
+	s.extend(bb.Min)
+	s.extend(bb.Max)
+}
+
+// Line outputs a line to the SVG file.
+func (s *SVG) Line(p0, p1 sdf.V2) {
+	s.extend(p0)
+	s.extend(p1)
 	s.p0s = append(s.p0s, p0)
 	s.p1s = append(s.p1s, p1)
 }
 
-// Save closes the SVG file.
-func (s *SVG) Save() error {
-	f, err := os.Create(s.filename)
-	if err != nil {
-		return err
+// Contour outputs a contour to the SVG file as a path.
+func (s *SVG) Contour(c *Contour) {
+	if len(c.Points) == 0 {
+		return
+	}
+	for _, p := range c.Points {
+		s.extend(p)
+	}
+	s.contours = append(s.contours, c)
+}
+
+// path returns the path data for a contour.
+func (s *SVG) path(c *Contour, decimals int) string {
+	var sb strings.Builder
+	for i, p := range c.Points {
+		cmd := "L"
+		if i == 0 {
+			cmd = "M"
+		}
+		fmt.Fprintf(&sb, "%s%.*f %.*f", cmd, decimals, p.X-s.min.X, decimals, s.max.Y-p.Y)
+	}
+	if c.Closed {
+		sb.WriteString("Z")
 	}
+	return sb.String()
+}
+
+// errWriter is a writer that records the first write error.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
 
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// Write writes the SVG to a writer.
+func (s *SVG) Write(w io.Writer) error {
+	ew := &errWriter{w: w}
 	width := s.max.X - s.min.X
 	height := s.max.Y - s.min.Y
-	canvas := svg.New(f)
+	canvas := svg.New(ew)
 	canvas.Start(width, height)
 	for i, p0 := range s.p0s {
 		p1 := s.p1s[i]
 		canvas.Line(p0.X-s.min.X, s.max.Y-p0.Y, p1.X-s.min.X, s.max.Y-p1.Y, s.lineStyle)
 	}
+	for _, c := range s.contours {
+		canvas.Path(s.path(c, canvas.Decimals), s.lineStyle)
+	}
 	canvas.End()
+	return ew.err
+}
+
+// Save closes the SVG file.
+func (s *SVG) Save() error {
+	f, err := os.Create(s.filename)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(f); err != nil {
+		f.Close()
+		return err
+	}
 	return f.Close()
 }
 
 //-----------------------------------------------------------------------------
 
-// SaveSVG writes line segments to an SVG file.
-func SaveSVG(path, lineStyle string, mesh []*Line) error {
+// newSVGLines returns an SVG renderer with line segments.
+func newSVGLines(path, lineStyle string, mesh []*Line) *SVG {
 	s := NewSVG(path, lineStyle)
 	for _, v := range mesh {
 		s.Line(v[0], v[1])
 	}
-	if err := s.Save(); err != nil {
+	return s
+}
+
+// newSVGContours returns an SVG renderer with contours.
+func newSVGContours(path, lineStyle string, contours []*Contour) *SVG {
+	s := NewSVG(path, lineStyle)
+	for _, c := range contours {
+		s.Contour(c)
+	}
+	return s
+}
+
+// SaveSVG writes line segments to an SVG file.
+func SaveSVG(path, lineStyle string, mesh []*Line) error {
+	if err := newSVGLines(path, lineStyle, mesh).Save(); err != nil {
 		return err
 	}
 	return nil
@@ -111,35 +195,50 @@ func WriteSVG(wg *sync.WaitGroup, path, lineStyle string) (chan<- *Line, error)
 
 //-----------------------------------------------------------------------------
 
-// RenderSVG renders an SDF2 as an SVG file. (uses quadtree sampling)
-func RenderSVG(
+// ToSVG renders an SDF2 as an SVG file.
+func ToSVG(
 	s sdf.SDF2, // sdf2 to render
 	meshCells int, // number of cells on the longest axis. e.g 200
 	path string, // path to filename
 	lineStyle string, // SVG line style
+	options ...RenderOption, // optional settings
 ) error {
-	// work out the sampling resolution to use
-	bbSize := s.BoundingBox().Size()
-	resolution := bbSize.MaxComponent() / float64(meshCells)
-	cells := bbSize.DivScalar(resolution).ToV2i()
-
-	fmt.Printf("rendering %s (%dx%d, resolution %.2f)\n", path, cells[0], cells[1], resolution)
-
-	// write the line segments to an SVG file
-	var wg sync.WaitGroup
-	output, err := WriteSVG(&wg, path, lineStyle)
-	if err != nil {
+	cfg := newRenderConfig(options)
+	resolution, cells := lineResolution(s, meshCells)
+	cfg.report("rendering %s (%dx%d, resolution %.2f)", path, cells[0], cells[1], resolution)
+	contours := renderContours(s, resolution, cfg)
+	if err := cfg.t.err(); err != nil {
 		return err
 	}
+	return newSVGContours(path, lineStyle, contours).Save()
+}
 
-	// run marching squares to generate the line segments
-	marchingSquaresQuadtree(s, resolution, output)
+// ToSVGWriter renders an SDF2 as SVG to a writer.
+func ToSVGWriter(
+	s sdf.SDF2, // sdf2 to render
+	meshCells int, // number of cells on the longest axis. e.g 200
+	w io.Writer, // output writer
+	lineStyle string, // SVG line style
+	options ...RenderOption, // optional settings
+) error {
+	cfg := newRenderConfig(options)
+	resolution, cells := lineResolution(s, meshCells)
+	cfg.report("rendering svg (%dx%d, resolution %.2f)", cells[0], cells[1], resolution)
+	contours := renderContours(s, resolution, cfg)
+	if err := cfg.t.err(); err != nil {
+		return err
+	}
+	return newSVGContours("", lineStyle, contours).Write(w)
+}
 
-	// stop the SVG writer reading on the channel
-	close(output)
-	// wait for the file write to complete
-	wg.Wait()
-	return nil
+// RenderSVG renders an SDF2 as an SVG file. (uses quadtree sampling)
+func RenderSVG(
+	s sdf.SDF2, // sdf2 to render
+	meshCells int, // number of cells on the longest axis. e.g 200
+	path string, // path to filename
+	lineStyle string, // SVG line style
+) error {
+	return ToSVG(s, meshCells, path, lineStyle, OnProgress(printProgress))
 }
 
 // RenderSVGSlow renders an SDF2 as an SVG file. (uses uniform grid sampling)
@@ -162,8 +261,8 @@ func RenderSVGSlow(
 	fmt.Printf("rendering %s (%dx%d)\n", path, cells[0], cells[1])
 
 	// run marching squares to generate the line segments
-	m := marchingSquares(s, bb, meshInc)
-	return SaveSVG(path, lineStyle, m)
+	m := marchingSquares(s, bb, meshInc, nil)
+	return newSVGContours(path, lineStyle, StitchLines(m, meshInc*stitchTolerance)).Save()
 }
 
 //-----------------------------------------------------------------------------
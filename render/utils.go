@@ -52,9 +52,18 @@ func mapCombinations(n, k int, f func([]int)) {
 
 //-----------------------------------------------------------------------------
 
+// sdfNormal returns the surface normal of an SDF3 at p.
+// The analytic gradient is used if the SDF3 has one, otherwise the
+// normal is estimated with central differences of step h.
+func sdfNormal(s sdf.SDF3, p sdf.V3, h float64) sdf.V3 {
+	return sdf.Gradient3(s, p, h).Normalize()
+}
+
+//-----------------------------------------------------------------------------
+
 func RandomInUnitSphere(rnd Rnd) sdf.V3 {
 	for {
-		p := sdf.V3{2.0*rnd.Float64() - 1.0, 2.0*rnd.Float64() - 1.0, 2.0*rnd.Float64() - 1.0}
+		p := sdf.V3{X: 2.0*rnd.Float64() - 1.0, Y: 2.0*rnd.Float64() - 1.0, Z: 2.0*rnd.Float64() - 1.0}
 		if p.Dot(p) < 1.0 {
 			return p
 		}
@@ -63,7 +72,7 @@ func RandomInUnitSphere(rnd Rnd) sdf.V3 {
 
 func RandomInUnitDisk(rnd Rnd) sdf.V3 {
 	for {
-		p := sdf.V3{2.0*rnd.Float64() - 1.0, 2.0*rnd.Float64() - 1.0, 0}
+		p := sdf.V3{X: 2.0*rnd.Float64() - 1.0, Y: 2.0*rnd.Float64() - 1.0, Z: 0}
 		if p.Dot(p) < 1.0 {
 			return p
 		}
@@ -0,0 +1,585 @@
+//-----------------------------------------------------------------------------
+/*
+
+Distance Volumes
+
+Sample an SDF3 onto a regular grid and save/load the distance volume.
+Reloaded volumes are SDF3s (see sdf.Volume3D), so expensive SDFs can be
+cached or passed to other tools.
+
+File formats:
+
+* raw: a binary header (see volumeHeader) followed by float32 samples.
+* nrrd: NRRD with raw little endian float samples.
+* sparse: the raw header followed by blocks of samples. Blocks away from the
+  surface are stored as a single (conservative) distance value.
+
+All binary values are little endian. Samples are ordered x fastest, then y, then z.
+
+*/
+//-----------------------------------------------------------------------------
+
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// volumeBlock is the side length (in samples) of the blocks in a sparse volume.
+const volumeBlock = 8
+
+// Volume file magic numbers.
+const (
+	volumeRawMagic    = "SDFV"
+	volumeSparseMagic = "SDFS"
+	volumeNRRDMagic   = "NRRD"
+)
+
+// volumeHeader is the header for the raw and sparse volume formats.
+type volumeHeader struct {
+	Magic   [4]byte
+	Version uint32
+	Size    [3]uint32
+	Origin  [3]float64
+	Spacing float64
+}
+
+//-----------------------------------------------------------------------------
+
+// Volume is a regular grid of SDF3 distance samples.
+type Volume struct {
+	Origin  sdf.V3    // position of sample (0,0,0)
+	Spacing float64   // distance between samples
+	Size    sdf.V3i   // number of samples on each axis
+	Data    []float32 // samples, x varies fastest then y then z
+}
+
+// newVolume returns an empty volume covering the bounding box of an SDF3.
+// There is a one sample margin around the bounding box.
+func newVolume(s sdf.SDF3, spacing float64) (*Volume, error) {
+	if spacing <= 0 {
+		return nil, errors.New("spacing <= 0")
+	}
+	bb := s.BoundingBox()
+	size := bb.Size().DivScalar(spacing).Ceil().ToV3i().AddScalar(3)
+	center := bb.Center()
+	half := size.SubScalar(1).ToV3().MulScalar(0.5 * spacing)
+	return &Volume{
+		Origin:  center.Sub(half),
+		Spacing: spacing,
+		Size:    size,
+		Data:    make([]float32, size[0]*size[1]*size[2]),
+	}, nil
+}
+
+// index returns the data index of sample (x, y, z).
+func (v *Volume) index(x, y, z int) int {
+	return (z*v.Size[1]+y)*v.Size[0] + x
+}
+
+// position returns the position of sample (x, y, z).
+func (v *Volume) position(x, y, z int) sdf.V3 {
+	return v.Origin.Add(sdf.V3{X: float64(x), Y: float64(y), Z: float64(z)}.MulScalar(v.Spacing))
+}
+
+// blocks returns the number of blocks on each axis.
+func (v *Volume) blocks() sdf.V3i {
+	n := v.Size.AddScalar(volumeBlock - 1)
+	return sdf.V3i{n[0] / volumeBlock, n[1] / volumeBlock, n[2] / volumeBlock}
+}
+
+// blockRange returns the sample range [min, max) for a block.
+func (v *Volume) blockRange(b sdf.V3i) (sdf.V3i, sdf.V3i) {
+	min := sdf.V3i{b[0] * volumeBlock, b[1] * volumeBlock, b[2] * volumeBlock}
+	max := min.AddScalar(volumeBlock)
+	for i := range max {
+		if max[i] > v.Size[i] {
+			max[i] = v.Size[i]
+		}
+	}
+	return min, max
+}
+
+// NewVolume samples an SDF3 onto a grid with the given sample spacing.
+func NewVolume(s sdf.SDF3, spacing float64, options ...RenderOption) (*Volume, error) {
+	cfg := newRenderConfig(options)
+	v, err := newVolume(s, spacing)
+	if err != nil {
+		return nil, err
+	}
+	cfg.report("sampling volume (%dx%dx%d)", v.Size[0], v.Size[1], v.Size[2])
+	// progress is reported in z layers
+	cfg.t.setTotal(int64(v.Size[2]))
+	cfg.engine.Run(v.Size[2], func(_, z int) {
+		if cfg.t.cancelled() {
+			return
+		}
+		for y := 0; y < v.Size[1]; y++ {
+			for x := 0; x < v.Size[0]; x++ {
+				v.Data[v.index(x, y, z)] = float32(s.Evaluate(v.position(x, y, z)))
+			}
+		}
+		cfg.t.addCells(1)
+	})
+	cfg.t.flush()
+	if err := cfg.t.err(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewSparseVolume samples an SDF3 onto a grid with the given sample spacing.
+// Only the blocks near the surface are fully sampled, samples in the other blocks
+// are set to a bound on the distance over the block (see sdf.EvaluateInterval3).
+func NewSparseVolume(s sdf.SDF3, spacing float64, options ...RenderOption) (*Volume, error) {
+	cfg := newRenderConfig(options)
+	v, err := newVolume(s, spacing)
+	if err != nil {
+		return nil, err
+	}
+	nb := v.blocks()
+	cfg.report("sampling sparse volume (%dx%dx%d, %d blocks)", v.Size[0], v.Size[1], v.Size[2], nb[0]*nb[1]*nb[2])
+	// progress is reported in blocks
+	cfg.t.setTotal(int64(nb[0] * nb[1] * nb[2]))
+	cfg.engine.Run(nb[0]*nb[1]*nb[2], func(_, i int) {
+		if cfg.t.cancelled() {
+			return
+		}
+		min, max := v.blockRange(sdf.V3i{i % nb[0], (i / nb[0]) % nb[1], i / (nb[0] * nb[1])})
+		p0, p1 := v.position(min[0], min[1], min[2]), v.position(max[0]-1, max[1]-1, max[2]-1)
+		lo, hi := sdf.EvaluateInterval3(s, sdf.Box3{Min: p0, Max: p1})
+		// the bound with the smallest magnitude if the block is away from the surface
+		tile := math.NaN()
+		if lo > spacing {
+			tile = lo
+		} else if hi < -spacing {
+			tile = hi
+		}
+		for z := min[2]; z < max[2]; z++ {
+			for y := min[1]; y < max[1]; y++ {
+				for x := min[0]; x < max[0]; x++ {
+					if !math.IsNaN(tile) {
+						v.Data[v.index(x, y, z)] = float32(tile)
+					} else {
+						v.Data[v.index(x, y, z)] = float32(s.Evaluate(v.position(x, y, z)))
+					}
+				}
+			}
+		}
+		cfg.t.addCells(1)
+	})
+	cfg.t.flush()
+	if err := cfg.t.err(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// SDF3 returns an SDF3 interpolated from the volume samples.
+func (v *Volume) SDF3(interp sdf.Interpolation) (sdf.SDF3, error) {
+	return sdf.Volume3D(v.Origin, v.Spacing, v.Size, v.Data, interp)
+}
+
+//-----------------------------------------------------------------------------
+// Raw and Sparse Formats
+
+// header returns the raw/sparse file header for a volume.
+func (v *Volume) header(magic string) *volumeHeader {
+	h := &volumeHeader{
+		Version: 1,
+		Size:    [3]uint32{uint32(v.Size[0]), uint32(v.Size[1]), uint32(v.Size[2])},
+		Origin:  [3]float64{v.Origin.X, v.Origin.Y, v.Origin.Z},
+		Spacing: v.Spacing,
+	}
+	copy(h.Magic[:], magic)
+	return h
+}
+
+// volumeFromHeader returns an empty volume for a raw/sparse file header.
+func volumeFromHeader(h *volumeHeader) (*Volume, error) {
+	if h.Version != 1 {
+		return nil, fmt.Errorf("unsupported volume version %d", h.Version)
+	}
+	size := sdf.V3i{int(h.Size[0]), int(h.Size[1]), int(h.Size[2])}
+	if size[0] < 2 || size[1] < 2 || size[2] < 2 || float64(size[0])*float64(size[1])*float64(size[2]) > math.MaxInt32 {
+		return nil, errors.New("bad volume size")
+	}
+	if !(h.Spacing > 0) {
+		return nil, errors.New("bad volume spacing")
+	}
+	return &Volume{
+		Origin:  sdf.V3{X: h.Origin[0], Y: h.Origin[1], Z: h.Origin[2]},
+		Spacing: h.Spacing,
+		Size:    size,
+		Data:    make([]float32, size[0]*size[1]*size[2]),
+	}, nil
+}
+
+// WriteRaw writes the volume in the raw format.
+func (v *Volume) WriteRaw(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.LittleEndian, v.header(volumeRawMagic)); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, v.Data); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// WriteSparse writes the volume in the sparse block format.
+// Blocks with no samples within a sample spacing of the surface are stored as
+// their minimum distance.
+func (v *Volume) WriteSparse(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.LittleEndian, v.header(volumeSparseMagic)); err != nil {
+		return err
+	}
+	nb := v.blocks()
+	buf := make([]float32, 0, volumeBlock*volumeBlock*volumeBlock)
+	for bz := 0; bz < nb[2]; bz++ {
+		for by := 0; by < nb[1]; by++ {
+			for bx := 0; bx < nb[0]; bx++ {
+				min, max := v.blockRange(sdf.V3i{bx, by, bz})
+				buf = buf[:0]
+				tile := math.Inf(1)
+				sign := 0.0
+				for z := min[2]; z < max[2]; z++ {
+					for y := min[1]; y < max[1]; y++ {
+						for x := min[0]; x < max[0]; x++ {
+							d := v.Data[v.index(x, y, z)]
+							buf = append(buf, d)
+							if sign == 0 {
+								sign = math.Copysign(1, float64(d))
+							}
+							if math.Copysign(1, float64(d)) != sign || math.Abs(float64(d)) <= v.Spacing {
+								tile = 0
+							} else {
+								tile = math.Min(tile, math.Abs(float64(d)))
+							}
+						}
+					}
+				}
+				var err error
+				if tile > 0 {
+					_, err = bw.Write([]byte{0})
+					if err == nil {
+						err = binary.Write(bw, binary.LittleEndian, float32(sign*tile))
+					}
+				} else {
+					_, err = bw.Write([]byte{1})
+					if err == nil {
+						err = binary.Write(bw, binary.LittleEndian, buf)
+					}
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// readRaw reads the samples of a raw volume.
+func (v *Volume) readRaw(r io.Reader) error {
+	return binary.Read(r, binary.LittleEndian, v.Data)
+}
+
+// readSparse reads the blocks of a sparse volume.
+func (v *Volume) readSparse(r io.Reader) error {
+	nb := v.blocks()
+	buf := make([]float32, volumeBlock*volumeBlock*volumeBlock)
+	var flag [1]byte
+	var tile float32
+	for bz := 0; bz < nb[2]; bz++ {
+		for by := 0; by < nb[1]; by++ {
+			for bx := 0; bx < nb[0]; bx++ {
+				min, max := v.blockRange(sdf.V3i{bx, by, bz})
+				n := (max[0] - min[0]) * (max[1] - min[1]) * (max[2] - min[2])
+				if _, err := io.ReadFull(r, flag[:]); err != nil {
+					return err
+				}
+				switch flag[0] {
+				case 0:
+					if err := binary.Read(r, binary.LittleEndian, &tile); err != nil {
+						return err
+					}
+					for i := range buf[:n] {
+						buf[i] = tile
+					}
+				case 1:
+					if err := binary.Read(r, binary.LittleEndian, buf[:n]); err != nil {
+						return err
+					}
+				default:
+					return errors.New("bad sparse volume block")
+				}
+				i := 0
+				for z := min[2]; z < max[2]; z++ {
+					for y := min[1]; y < max[1]; y++ {
+						for x := min[0]; x < max[0]; x++ {
+							v.Data[v.index(x, y, z)] = buf[i]
+							i++
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// NRRD Format
+
+// WriteNRRD writes the volume in the NRRD format.
+func (v *Volume) WriteNRRD(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	s := v.Spacing
+	fmt.Fprintf(bw, "NRRD0004\n")
+	fmt.Fprintf(bw, "# sdfx distance volume\n")
+	fmt.Fprintf(bw, "type: float\n")
+	fmt.Fprintf(bw, "dimension: 3\n")
+	fmt.Fprintf(bw, "space dimension: 3\n")
+	fmt.Fprintf(bw, "sizes: %d %d %d\n", v.Size[0], v.Size[1], v.Size[2])
+	fmt.Fprintf(bw, "space directions: (%g,0,0) (0,%g,0) (0,0,%g)\n", s, s, s)
+	fmt.Fprintf(bw, "space origin: (%g,%g,%g)\n", v.Origin.X, v.Origin.Y, v.Origin.Z)
+	fmt.Fprintf(bw, "endian: little\n")
+	fmt.Fprintf(bw, "encoding: raw\n\n")
+	if err := binary.Write(bw, binary.LittleEndian, v.Data); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// nrrdVector parses an NRRD vector, e.g. "(1,2,3)".
+func nrrdVector(s string) ([]float64, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("bad nrrd vector %q", s)
+	}
+	var v []float64
+	for _, f := range strings.Split(s[1:len(s)-1], ",") {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, x)
+	}
+	return v, nil
+}
+
+// nrrdVectors parses a space separated list of NRRD vectors.
+// The vectors may have spaces within them, e.g. "(1, 0, 0) (0, 1, 0)".
+func nrrdVectors(s string) ([][]float64, error) {
+	var vs [][]float64
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		i := strings.Index(s, ")")
+		if i < 0 {
+			return nil, fmt.Errorf("bad nrrd vector %q", s)
+		}
+		v, err := nrrdVector(s[:i+1])
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+		s = s[i+1:]
+	}
+	return vs, nil
+}
+
+// readNRRD reads an NRRD volume.
+func readNRRD(r *bufio.Reader) (*Volume, error) {
+	fields := make(map[string]string)
+	for i := 0; ; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if i == 0 {
+			if !strings.HasPrefix(line, volumeNRRDMagic) {
+				return nil, errors.New("not an nrrd file")
+			}
+			continue
+		}
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 || strings.HasPrefix(kv[1], "=") {
+			// key/value pairs (key:=value) are ignored
+			continue
+		}
+		fields[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	if t := fields["type"]; t != "float" && t != "float32" {
+		return nil, fmt.Errorf("unsupported nrrd type %q", t)
+	}
+	if fields["dimension"] != "3" {
+		return nil, errors.New("nrrd dimension must be 3")
+	}
+	if e := fields["encoding"]; e != "raw" {
+		return nil, fmt.Errorf("unsupported nrrd encoding %q", e)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if fields["endian"] == "big" {
+		order = binary.BigEndian
+	}
+	h := &volumeHeader{Version: 1}
+	sizes := strings.Fields(fields["sizes"])
+	if len(sizes) != 3 {
+		return nil, errors.New("bad nrrd sizes")
+	}
+	for i, s := range sizes {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		h.Size[i] = uint32(n)
+	}
+	// sample spacing (must be the same on each axis)
+	var spacing []float64
+	if d, ok := fields["space directions"]; ok {
+		vs, err := nrrdVectors(d)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range vs {
+			if len(v) != 3 {
+				return nil, errors.New("bad nrrd space directions")
+			}
+			for j := range v {
+				if j != i && v[j] != 0 {
+					return nil, errors.New("nrrd space directions must be axis aligned")
+				}
+			}
+			spacing = append(spacing, v[i])
+		}
+	} else {
+		for _, f := range strings.Fields(fields["spacings"]) {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, err
+			}
+			spacing = append(spacing, v)
+		}
+	}
+	if len(spacing) != 3 || math.Abs(spacing[0]-spacing[1]) > tolerance || math.Abs(spacing[0]-spacing[2]) > tolerance {
+		return nil, errors.New("nrrd spacing must be the same on each axis")
+	}
+	h.Spacing = spacing[0]
+	if o, ok := fields["space origin"]; ok {
+		v, err := nrrdVector(o)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) != 3 {
+			return nil, errors.New("bad nrrd space origin")
+		}
+		copy(h.Origin[:], v)
+	}
+	v, err := volumeFromHeader(h)
+	if err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, order, v.Data); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// ReadVolume reads a volume in the raw, sparse or NRRD format.
+func ReadVolume(r io.Reader) (*Volume, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(magic, []byte(volumeNRRDMagic)) {
+		return readNRRD(br)
+	}
+	if !bytes.Equal(magic, []byte(volumeRawMagic)) && !bytes.Equal(magic, []byte(volumeSparseMagic)) {
+		return nil, errors.New("unrecognised volume format")
+	}
+	h := &volumeHeader{}
+	if err := binary.Read(br, binary.LittleEndian, h); err != nil {
+		return nil, err
+	}
+	v, err := volumeFromHeader(h)
+	if err != nil {
+		return nil, err
+	}
+	if string(h.Magic[:]) == volumeSparseMagic {
+		err = v.readSparse(br)
+	} else {
+		err = v.readRaw(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// LoadVolume reads a volume file in the raw, sparse or NRRD format.
+func LoadVolume(path string) (*Volume, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadVolume(f)
+}
+
+// saveVolume writes a volume file, the file is removed on error.
+func saveVolume(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// SaveRaw writes the volume to a file in the raw format.
+func (v *Volume) SaveRaw(path string) error {
+	return saveVolume(path, v.WriteRaw)
+}
+
+// SaveSparse writes the volume to a file in the sparse block format.
+func (v *Volume) SaveSparse(path string) error {
+	return saveVolume(path, v.WriteSparse)
+}
+
+// SaveNRRD writes the volume to a file in the NRRD format.
+func (v *Volume) SaveNRRD(path string) error {
+	return saveVolume(path, v.WriteNRRD)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,84 @@
+//-----------------------------------------------------------------------------
+/*
+
+Registered Models
+
+A model is a named part, built in Go code or loaded from a scene file, that the
+sdfx tool can render by name. E.g.
+
+func init() {
+	scene.RegisterModel("bracket", scene.SDF3Func(bracket))
+}
+
+*/
+//-----------------------------------------------------------------------------
+
+package scene
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// SDF2Func is a function that builds an SDF2. It is a Shape2.
+type SDF2Func func() (sdf.SDF2, error)
+
+// SDF2 builds the SDF2.
+func (f SDF2Func) SDF2() (sdf.SDF2, error) {
+	return f()
+}
+
+// SDF3Func is a function that builds an SDF3. It is a Shape3.
+type SDF3Func func() (sdf.SDF3, error)
+
+// SDF3 builds the SDF3.
+func (f SDF3Func) SDF3() (sdf.SDF3, error) {
+	return f()
+}
+
+//-----------------------------------------------------------------------------
+
+var models = make(map[string]Node) // model name to model
+
+// RegisterModel adds a model with the given name.
+// The model is a Shape2, a Shape3 or a scene graph Node.
+func RegisterModel(name string, model interface{}) {
+	n, ok := model.(Node)
+	if !ok {
+		_, ok2 := model.(Shape2)
+		_, ok3 := model.(Shape3)
+		if !ok2 && !ok3 {
+			panic(fmt.Sprintf("model \"%s\" doesn't build an SDF2 or SDF3", name))
+		}
+		n = NewNode(model)
+	}
+	if _, ok := models[name]; ok {
+		panic(fmt.Sprintf("model \"%s\" is already registered", name))
+	}
+	models[name] = n
+}
+
+// Model returns the registered model with the given name.
+func Model(name string) (Node, error) {
+	n, ok := models[name]
+	if !ok {
+		return Node{}, fmt.Errorf("unknown model \"%s\"", name)
+	}
+	return n, nil
+}
+
+// Models returns the sorted names of the registered models.
+func Models() []string {
+	s := make([]string, 0, len(models))
+	for k := range models {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
+
+//-----------------------------------------------------------------------------
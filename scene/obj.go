@@ -0,0 +1,461 @@
+//-----------------------------------------------------------------------------
+/*
+
+Scene graph nodes for the constructors of the obj package.
+
+The nodes for constructors with a parameter structure embed it, so the
+parameters are fields of the node.
+
+*/
+//-----------------------------------------------------------------------------
+
+package scene
+
+import (
+	"fmt"
+
+	"github.com/jakoblorz/sdfx/obj"
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+func init() {
+	Register("obj.Angle2D", &Angle2D{})
+	Register("obj.Angle3D", &Angle3D{})
+	Register("obj.Arrow3D", &Arrow3D{})
+	Register("obj.Axes3D", &Axes3D{})
+	Register("obj.Bolt", &Bolt{})
+	Register("obj.Nut", &Nut{})
+	Register("obj.HexHead3D", &HexHead3D{})
+	Register("obj.KnurledHead3D", &KnurledHead3D{})
+	Register("obj.Knurl3D", &Knurl3D{})
+	Register("obj.ChamferedCylinder", &ChamferedCylinder{})
+	Register("obj.FingerButton2D", &FingerButton2D{})
+	Register("obj.InvoluteGear", &InvoluteGear{})
+	Register("obj.Geneva2D", &Geneva2D{})
+	Register("obj.CounterBoredHole3D", &CounterBoredHole3D{})
+	Register("obj.ChamferedHole3D", &ChamferedHole3D{})
+	Register("obj.CounterSunkHole3D", &CounterSunkHole3D{})
+	Register("obj.BoltCircle2D", &BoltCircle2D{})
+	Register("obj.BoltCircle3D", &BoltCircle3D{})
+	Register("obj.Keyway2D", &Keyway2D{})
+	Register("obj.Keyway3D", &Keyway3D{})
+	Register("obj.Panel2D", &Panel2D{})
+	Register("obj.Panel3D", &Panel3D{})
+	Register("obj.EuroRackPanel2D", &EuroRackPanel2D{})
+	Register("obj.EuroRackPanel3D", &EuroRackPanel3D{})
+	Register("obj.PanelHole3D", &PanelHole3D{})
+	Register("obj.PanelBox3D", &PanelBox3D{})
+	Register("obj.Pipe3D", &Pipe3D{})
+	Register("obj.StdPipe3D", &StdPipe3D{})
+	Register("obj.PipeConnector3D", &PipeConnector3D{})
+	Register("obj.StdPipeConnector3D", &StdPipeConnector3D{})
+	Register("obj.Standoff3D", &Standoff3D{})
+	Register("obj.TruncRectPyramid3D", &TruncRectPyramid3D{})
+	Register("obj.Washer2D", &Washer2D{})
+	Register("obj.Washer3D", &Washer3D{})
+}
+
+//-----------------------------------------------------------------------------
+// Angles and Arrows
+
+// Angle2D is a node for obj.Angle2D.
+type Angle2D struct {
+	obj.AngleParms
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Angle2D) SDF2() (sdf.SDF2, error) {
+	return obj.Angle2D(&n.AngleParms)
+}
+
+// Angle3D is a node for obj.Angle3D.
+type Angle3D struct {
+	obj.AngleParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Angle3D) SDF3() (sdf.SDF3, error) {
+	return obj.Angle3D(&n.AngleParms)
+}
+
+// Arrow3D is a node for obj.Arrow3D.
+type Arrow3D struct {
+	obj.ArrowParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Arrow3D) SDF3() (sdf.SDF3, error) {
+	return obj.Arrow3D(&n.ArrowParms)
+}
+
+// Axes3D is a node for obj.Axes3D.
+type Axes3D struct {
+	P0, P1 sdf.V3
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Axes3D) SDF3() (sdf.SDF3, error) {
+	return obj.Axes3D(n.P0, n.P1)
+}
+
+//-----------------------------------------------------------------------------
+// Nuts and Bolts
+
+// Bolt is a node for obj.Bolt.
+type Bolt struct {
+	obj.BoltParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Bolt) SDF3() (sdf.SDF3, error) {
+	return obj.Bolt(&n.BoltParms)
+}
+
+// Nut is a node for obj.Nut.
+type Nut struct {
+	obj.NutParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Nut) SDF3() (sdf.SDF3, error) {
+	return obj.Nut(&n.NutParms)
+}
+
+// HexHead3D is a node for obj.HexHead3D.
+type HexHead3D struct {
+	Radius float64
+	Height float64
+	Round  string // rounding control (t)top, (b)bottom, (tb)top/bottom
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *HexHead3D) SDF3() (sdf.SDF3, error) {
+	return obj.HexHead3D(n.Radius, n.Height, n.Round)
+}
+
+// KnurledHead3D is a node for obj.KnurledHead3D.
+type KnurledHead3D struct {
+	Radius float64
+	Height float64
+	Pitch  float64 // knurl pitch
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *KnurledHead3D) SDF3() (sdf.SDF3, error) {
+	return obj.KnurledHead3D(n.Radius, n.Height, n.Pitch)
+}
+
+// Knurl3D is a node for obj.Knurl3D.
+type Knurl3D struct {
+	obj.KnurlParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Knurl3D) SDF3() (sdf.SDF3, error) {
+	return obj.Knurl3D(&n.KnurlParms)
+}
+
+// ChamferedCylinder is a node for obj.ChamferedCylinder.
+type ChamferedCylinder struct {
+	SDF    Node
+	KB, KT float64 // bottom/top chamfer (fraction of radius)
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *ChamferedCylinder) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return obj.ChamferedCylinder(s, n.KB, n.KT)
+}
+
+//-----------------------------------------------------------------------------
+// Mechanisms
+
+// FingerButton2D is a node for obj.FingerButton2D.
+type FingerButton2D struct {
+	obj.FingerButtonParms
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *FingerButton2D) SDF2() (sdf.SDF2, error) {
+	return obj.FingerButton2D(&n.FingerButtonParms)
+}
+
+// InvoluteGear is a node for obj.InvoluteGear.
+type InvoluteGear struct {
+	obj.InvoluteGearParms
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *InvoluteGear) SDF2() (sdf.SDF2, error) {
+	return obj.InvoluteGear(&n.InvoluteGearParms)
+}
+
+// Geneva2D is a node for obj.Geneva2D.
+type Geneva2D struct {
+	obj.GenevaParms
+	Driven bool // the driven (or driver) wheel
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Geneva2D) SDF2() (sdf.SDF2, error) {
+	driver, driven, err := obj.Geneva2D(&n.GenevaParms)
+	if n.Driven {
+		return driven, err
+	}
+	return driver, err
+}
+
+//-----------------------------------------------------------------------------
+// Holes
+
+// CounterBoredHole3D is a node for obj.CounterBoredHole3D.
+type CounterBoredHole3D struct {
+	Length   float64 // total length (includes counterbore)
+	Radius   float64 // hole radius
+	CBRadius float64 // counter bore radius
+	CBDepth  float64 // counter bore depth
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *CounterBoredHole3D) SDF3() (sdf.SDF3, error) {
+	return obj.CounterBoredHole3D(n.Length, n.Radius, n.CBRadius, n.CBDepth)
+}
+
+// ChamferedHole3D is a node for obj.ChamferedHole3D.
+type ChamferedHole3D struct {
+	Length   float64 // total length (includes chamfer)
+	Radius   float64 // hole radius
+	CHRadius float64 // chamfer radius
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *ChamferedHole3D) SDF3() (sdf.SDF3, error) {
+	return obj.ChamferedHole3D(n.Length, n.Radius, n.CHRadius)
+}
+
+// CounterSunkHole3D is a node for obj.CounterSunkHole3D.
+type CounterSunkHole3D struct {
+	Length float64 // total length
+	Radius float64 // hole radius
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *CounterSunkHole3D) SDF3() (sdf.SDF3, error) {
+	return obj.CounterSunkHole3D(n.Length, n.Radius)
+}
+
+// BoltCircle2D is a node for obj.BoltCircle2D.
+type BoltCircle2D struct {
+	HoleRadius   float64 // radius of bolt holes
+	CircleRadius float64 // radius of bolt circle
+	NumHoles     int     // number of bolts
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *BoltCircle2D) SDF2() (sdf.SDF2, error) {
+	return obj.BoltCircle2D(n.HoleRadius, n.CircleRadius, n.NumHoles)
+}
+
+// BoltCircle3D is a node for obj.BoltCircle3D.
+type BoltCircle3D struct {
+	HoleDepth    float64 // depth of bolt holes
+	HoleRadius   float64 // radius of bolt holes
+	CircleRadius float64 // radius of bolt circle
+	NumHoles     int     // number of bolts
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *BoltCircle3D) SDF3() (sdf.SDF3, error) {
+	return obj.BoltCircle3D(n.HoleDepth, n.HoleRadius, n.CircleRadius, n.NumHoles)
+}
+
+// Keyway2D is a node for obj.Keyway2D.
+type Keyway2D struct {
+	obj.KeywayParameters
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Keyway2D) SDF2() (sdf.SDF2, error) {
+	return obj.Keyway2D(&n.KeywayParameters)
+}
+
+// Keyway3D is a node for obj.Keyway3D.
+type Keyway3D struct {
+	obj.KeywayParameters
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Keyway3D) SDF3() (sdf.SDF3, error) {
+	return obj.Keyway3D(&n.KeywayParameters)
+}
+
+//-----------------------------------------------------------------------------
+// Panels
+
+// Panel2D is a node for obj.Panel2D.
+type Panel2D struct {
+	obj.PanelParms
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Panel2D) SDF2() (sdf.SDF2, error) {
+	return obj.Panel2D(&n.PanelParms)
+}
+
+// Panel3D is a node for obj.Panel3D.
+type Panel3D struct {
+	obj.PanelParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Panel3D) SDF3() (sdf.SDF3, error) {
+	return obj.Panel3D(&n.PanelParms)
+}
+
+// EuroRackPanel2D is a node for obj.EuroRackPanel2D.
+type EuroRackPanel2D struct {
+	obj.EuroRackParms
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *EuroRackPanel2D) SDF2() (sdf.SDF2, error) {
+	return obj.EuroRackPanel2D(&n.EuroRackParms)
+}
+
+// EuroRackPanel3D is a node for obj.EuroRackPanel3D.
+type EuroRackPanel3D struct {
+	obj.EuroRackParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *EuroRackPanel3D) SDF3() (sdf.SDF3, error) {
+	return obj.EuroRackPanel3D(&n.EuroRackParms)
+}
+
+// PanelHole3D is a node for obj.PanelHole3D.
+type PanelHole3D struct {
+	obj.PanelHoleParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *PanelHole3D) SDF3() (sdf.SDF3, error) {
+	return obj.PanelHole3D(&n.PanelHoleParms)
+}
+
+// PanelBox3D is a node for one of the parts of obj.PanelBox3D.
+type PanelBox3D struct {
+	obj.PanelBoxParms
+	Part int // 0 = panel, 1 = top, 2 = bottom
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *PanelBox3D) SDF3() (sdf.SDF3, error) {
+	parts, err := obj.PanelBox3D(&n.PanelBoxParms)
+	if err != nil {
+		return nil, err
+	}
+	if n.Part < 0 || n.Part >= len(parts) {
+		return nil, fmt.Errorf("part %d not in [0, %d]", n.Part, len(parts)-1)
+	}
+	return parts[n.Part], nil
+}
+
+//-----------------------------------------------------------------------------
+// Pipes
+
+// Pipe3D is a node for obj.Pipe3D.
+type Pipe3D struct {
+	OuterRadius float64
+	InnerRadius float64
+	Length      float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Pipe3D) SDF3() (sdf.SDF3, error) {
+	return obj.Pipe3D(n.OuterRadius, n.InnerRadius, n.Length)
+}
+
+// StdPipe3D is a node for obj.StdPipe3D.
+type StdPipe3D struct {
+	Name   string // pipe name, E.g. "sch40:1"
+	Units  string // "inch" or "mm"
+	Length float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *StdPipe3D) SDF3() (sdf.SDF3, error) {
+	return obj.StdPipe3D(n.Name, n.Units, n.Length)
+}
+
+// PipeConnector3D is a node for obj.PipeConnector3D.
+type PipeConnector3D struct {
+	obj.PipeConnectorParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *PipeConnector3D) SDF3() (sdf.SDF3, error) {
+	return obj.PipeConnector3D(&n.PipeConnectorParms)
+}
+
+// StdPipeConnector3D is a node for obj.StdPipeConnector3D.
+type StdPipeConnector3D struct {
+	Name          string // pipe name, E.g. "sch40:1"
+	Units         string // "inch" or "mm"
+	Length        float64
+	Configuration [6]bool // position of arms. +x,-x,+y,-y,+z,-z
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *StdPipeConnector3D) SDF3() (sdf.SDF3, error) {
+	return obj.StdPipeConnector3D(n.Name, n.Units, n.Length, n.Configuration)
+}
+
+//-----------------------------------------------------------------------------
+// Miscellaneous
+
+// Standoff3D is a node for obj.Standoff3D.
+type Standoff3D struct {
+	obj.StandoffParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Standoff3D) SDF3() (sdf.SDF3, error) {
+	return obj.Standoff3D(&n.StandoffParms)
+}
+
+// TruncRectPyramid3D is a node for obj.TruncRectPyramid3D.
+type TruncRectPyramid3D struct {
+	obj.TruncRectPyramidParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *TruncRectPyramid3D) SDF3() (sdf.SDF3, error) {
+	return obj.TruncRectPyramid3D(&n.TruncRectPyramidParms)
+}
+
+// Washer2D is a node for obj.Washer2D.
+type Washer2D struct {
+	obj.WasherParms
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Washer2D) SDF2() (sdf.SDF2, error) {
+	return obj.Washer2D(&n.WasherParms)
+}
+
+// Washer3D is a node for obj.Washer3D.
+type Washer3D struct {
+	obj.WasherParms
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Washer3D) SDF3() (sdf.SDF3, error) {
+	return obj.Washer3D(&n.WasherParms)
+}
+
+//-----------------------------------------------------------------------------
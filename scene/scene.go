@@ -0,0 +1,518 @@
+//-----------------------------------------------------------------------------
+/*
+
+Scene Graphs
+
+A scene graph is a declarative description of a model. Each node of the graph
+holds the arguments for one of the sdf or obj constructors, so a model can be
+written as a JSON or YAML file rather than as a Go program.
+
+A node is an object with a "Type" (the constructor name) and the constructor
+arguments as fields. Vectors are objects with X/Y/Z fields, angles are in radians,
+and nodes for SDF arguments are nested within their parent node. E.g.
+
+Type: Union3D
+Min: {Type: RoundMin, K: 1}
+SDF:
+  - Type: Box3D
+    Size: {X: 10, Y: 10, Z: 10}
+  - Type: Transform3D
+    Matrix:
+      - Translate: {X: 0, Y: 0, Z: 5}
+    SDF: {Type: Sphere3D, Radius: 5}
+
+Field names are matched without regard to case when decoding.
+
+*/
+//-----------------------------------------------------------------------------
+
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jakoblorz/sdfx/sdf"
+	"gopkg.in/yaml.v3"
+)
+
+//-----------------------------------------------------------------------------
+
+// Shape2 is a scene graph node that builds an SDF2.
+type Shape2 interface {
+	SDF2() (sdf.SDF2, error)
+}
+
+// Shape3 is a scene graph node that builds an SDF3.
+type Shape3 interface {
+	SDF3() (sdf.SDF3, error)
+}
+
+// Node is a node of a scene graph.
+// It holds a shape (a Shape2 or a Shape3) and encodes it with its type name.
+type Node struct {
+	Shape interface{}
+}
+
+// NewNode returns a scene graph node for a shape.
+func NewNode(shape interface{}) Node {
+	return Node{shape}
+}
+
+// Is3D returns true if the node builds an SDF3.
+func (n Node) Is3D() bool {
+	_, ok := n.Shape.(Shape3)
+	return ok
+}
+
+// SDF2 builds the SDF2 for a node.
+func (n Node) SDF2() (sdf.SDF2, error) {
+	if n.Shape == nil {
+		return nil, fmt.Errorf("missing SDF2")
+	}
+	s, ok := n.Shape.(Shape2)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an SDF2", typeName(n.Shape))
+	}
+	s2, err := s.SDF2()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", typeName(n.Shape), err)
+	}
+	if s2 == nil {
+		return nil, fmt.Errorf("%s: empty SDF2", typeName(n.Shape))
+	}
+	return s2, nil
+}
+
+// SDF3 builds the SDF3 for a node.
+func (n Node) SDF3() (sdf.SDF3, error) {
+	if n.Shape == nil {
+		return nil, fmt.Errorf("missing SDF3")
+	}
+	s, ok := n.Shape.(Shape3)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an SDF3", typeName(n.Shape))
+	}
+	s3, err := s.SDF3()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", typeName(n.Shape), err)
+	}
+	if s3 == nil {
+		return nil, fmt.Errorf("%s: empty SDF3", typeName(n.Shape))
+	}
+	return s3, nil
+}
+
+//-----------------------------------------------------------------------------
+// Shape Registry
+
+var (
+	shapes = make(map[string]reflect.Type) // type name to shape type
+	names  = make(map[reflect.Type]string) // shape type to type name
+)
+
+// Register adds a shape to the scene graph types with the given type name.
+// The shape is a pointer to a struct that implements Shape2 or Shape3.
+func Register(name string, shape interface{}) {
+	t := reflect.TypeOf(shape)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("shape \"%s\" is not a pointer to a struct", name))
+	}
+	_, ok2 := shape.(Shape2)
+	_, ok3 := shape.(Shape3)
+	if !ok2 && !ok3 {
+		panic(fmt.Sprintf("shape \"%s\" doesn't build an SDF2 or SDF3", name))
+	}
+	if _, ok := shapes[name]; ok {
+		panic(fmt.Sprintf("shape \"%s\" is already registered", name))
+	}
+	shapes[name] = t.Elem()
+	names[t.Elem()] = name
+}
+
+// Types returns the sorted type names of the registered shapes.
+func Types() []string {
+	s := make([]string, 0, len(shapes))
+	for k := range shapes {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// typeName returns the type name for a shape.
+func typeName(shape interface{}) string {
+	t := reflect.TypeOf(shape)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return t.String()
+}
+
+//-----------------------------------------------------------------------------
+// Encoding
+
+// typeKey is the object key for the type name of a node.
+const typeKey = "Type"
+
+// MarshalJSON encodes a node as a JSON object with its type name.
+func (n Node) MarshalJSON() ([]byte, error) {
+	if n.Shape == nil {
+		return []byte("null"), nil
+	}
+	t := reflect.TypeOf(n.Shape)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name, ok := names[t]
+	if !ok {
+		return nil, fmt.Errorf("shape type %s is not registered", t)
+	}
+	fields, err := json.Marshal(n.Shape)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "{\"%s\":%q", typeKey, name)
+	if len(fields) > 2 {
+		// add the fields of the shape object
+		b.WriteByte(',')
+		b.Write(fields[1:])
+	} else {
+		b.WriteByte('}')
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a node from a JSON object with a type name.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		n.Shape = nil
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	// find the type name
+	var name string
+	for k, v := range fields {
+		if strings.EqualFold(k, typeKey) {
+			if err := json.Unmarshal(v, &name); err != nil {
+				return fmt.Errorf("bad type name %s", v)
+			}
+			delete(fields, k)
+			break
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("missing type name")
+	}
+	t, ok := shapes[name]
+	if !ok {
+		return fmt.Errorf("unknown shape type \"%s\"", name)
+	}
+	// decode the fields of the shape object
+	shape := reflect.New(t).Interface()
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(shape); err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	n.Shape = shape
+	return nil
+}
+
+// MarshalYAML encodes a node as a YAML mapping with its type name.
+func (n Node) MarshalYAML() (interface{}, error) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, decoding it this way keeps the order of the fields
+	var y yaml.Node
+	if err := yaml.Unmarshal(data, &y); err != nil {
+		return nil, err
+	}
+	if y.Kind == yaml.DocumentNode {
+		y = *y.Content[0]
+	}
+	yamlStyle(&y)
+	return &y, nil
+}
+
+// yamlStyle sets the block style for YAML nodes, and the flow style
+// for short collections of scalars (E.g. vectors).
+func yamlStyle(y *yaml.Node) {
+	y.Style = 0
+	flow := len(y.Content) > 0 && len(y.Content) <= 8
+	for _, c := range y.Content {
+		yamlStyle(c)
+		if c.Kind != yaml.ScalarNode {
+			flow = false
+		}
+	}
+	if flow && (y.Kind == yaml.MappingNode || y.Kind == yaml.SequenceNode) {
+		y.Style = yaml.FlowStyle
+	}
+}
+
+// UnmarshalYAML decodes a node from a YAML mapping with a type name.
+func (n *Node) UnmarshalYAML(y *yaml.Node) error {
+	var v interface{}
+	if err := y.Decode(&v); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("line %d: %s", y.Line, err)
+	}
+	if err := n.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("line %d: %s", y.Line, err)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// Scene Files
+
+// Decode decodes a scene graph from JSON or YAML (JSON is YAML).
+func Decode(data []byte) (Node, error) {
+	var n Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return Node{}, err
+	}
+	if n.Shape == nil {
+		return Node{}, fmt.Errorf("empty scene")
+	}
+	return n, nil
+}
+
+// EncodeJSON encodes a scene graph as indented JSON.
+func EncodeJSON(n Node) ([]byte, error) {
+	return json.MarshalIndent(n, "", "  ")
+}
+
+// EncodeYAML encodes a scene graph as YAML.
+func EncodeYAML(n Node) ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Load reads a scene graph from a JSON or YAML file.
+func Load(path string) (Node, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Node{}, err
+	}
+	n, err := Decode(data)
+	if err != nil {
+		return Node{}, fmt.Errorf("%s: %s", path, err)
+	}
+	return n, nil
+}
+
+// Save writes a scene graph to a file.
+// The file is JSON for a ".json" extension, and YAML otherwise.
+func Save(path string, n Node) error {
+	var data []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err = EncodeJSON(n)
+	} else {
+		data, err = EncodeYAML(n)
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+//-----------------------------------------------------------------------------
+// Blending
+
+// Blend selects a min/max function to blend SDFs.
+// The types are RoundMin, ChamferMin, ExpMin, PowMin and PolyMin for unions,
+// and PolyMax for differences and intersections.
+type Blend struct {
+	Type string
+	K    float64
+}
+
+// min returns the minimum function for a blend, math.Min for no blend.
+func (b *Blend) min() (sdf.Minimizer, error) {
+	if b == nil {
+		return sdf.MinFunc(math.Min), nil
+	}
+	switch b.Type {
+	case "RoundMin":
+		return sdf.RoundMin(b.K), nil
+	case "ChamferMin":
+		return sdf.ChamferMin(b.K), nil
+	case "ExpMin":
+		return sdf.ExpMin(b.K), nil
+	case "PowMin":
+		return sdf.PowMin(b.K), nil
+	case "PolyMin":
+		return sdf.PolyMin(b.K), nil
+	}
+	return nil, fmt.Errorf("unknown min function \"%s\"", b.Type)
+}
+
+// max returns the maximum function for a blend, math.Max for no blend.
+func (b *Blend) max() (sdf.MaxFunc, error) {
+	if b == nil {
+		return math.Max, nil
+	}
+	switch b.Type {
+	case "PolyMax":
+		return sdf.PolyMax(b.K), nil
+	}
+	return nil, fmt.Errorf("unknown max function \"%s\"", b.Type)
+}
+
+//-----------------------------------------------------------------------------
+// Transforms
+
+// Transform3 is a single 3d transform. Only one of the fields is set.
+type Transform3 struct {
+	Translate *sdf.V3   `json:",omitempty"`
+	Scale     *sdf.V3   `json:",omitempty"`
+	RotateX   *float64  `json:",omitempty"` // radians
+	RotateY   *float64  `json:",omitempty"` // radians
+	RotateZ   *float64  `json:",omitempty"` // radians
+	Rotate    *Rotation `json:",omitempty"`
+	Mirror    string    `json:",omitempty"` // "XY", "XZ" or "YZ"
+}
+
+// Rotation is a rotation about an axis.
+type Rotation struct {
+	Axis  sdf.V3
+	Angle float64 // radians
+}
+
+// Matrix3 is a sequence of 3d transforms, applied in order.
+type Matrix3 []Transform3
+
+// M44 returns the transform matrix.
+func (m Matrix3) M44() (sdf.M44, error) {
+	x := sdf.Identity3d()
+	for i, t := range m {
+		var k sdf.M44
+		n := 0
+		if t.Translate != nil {
+			k = sdf.Translate3d(*t.Translate)
+			n++
+		}
+		if t.Scale != nil {
+			k = sdf.Scale3d(*t.Scale)
+			n++
+		}
+		if t.RotateX != nil {
+			k = sdf.RotateX(*t.RotateX)
+			n++
+		}
+		if t.RotateY != nil {
+			k = sdf.RotateY(*t.RotateY)
+			n++
+		}
+		if t.RotateZ != nil {
+			k = sdf.RotateZ(*t.RotateZ)
+			n++
+		}
+		if t.Rotate != nil {
+			k = sdf.Rotate3d(t.Rotate.Axis, t.Rotate.Angle)
+			n++
+		}
+		if t.Mirror != "" {
+			switch t.Mirror {
+			case "XY":
+				k = sdf.MirrorXY()
+			case "XZ":
+				k = sdf.MirrorXZ()
+			case "YZ":
+				k = sdf.MirrorYZ()
+			default:
+				return sdf.M44{}, fmt.Errorf("transform %d: bad mirror plane \"%s\"", i, t.Mirror)
+			}
+			n++
+		}
+		if n != 1 {
+			return sdf.M44{}, fmt.Errorf("transform %d: %d transforms, expected 1", i, n)
+		}
+		x = k.Mul(x)
+	}
+	return x, nil
+}
+
+// Transform2 is a single 2d transform. Only one of the fields is set.
+type Transform2 struct {
+	Translate *sdf.V2  `json:",omitempty"`
+	Scale     *sdf.V2  `json:",omitempty"`
+	Rotate    *float64 `json:",omitempty"` // radians
+	Mirror    string   `json:",omitempty"` // "X" or "Y"
+}
+
+// Matrix2 is a sequence of 2d transforms, applied in order.
+type Matrix2 []Transform2
+
+// M33 returns the transform matrix.
+func (m Matrix2) M33() (sdf.M33, error) {
+	x := sdf.Identity2d()
+	for i, t := range m {
+		var k sdf.M33
+		n := 0
+		if t.Translate != nil {
+			k = sdf.Translate2d(*t.Translate)
+			n++
+		}
+		if t.Scale != nil {
+			k = sdf.Scale2d(*t.Scale)
+			n++
+		}
+		if t.Rotate != nil {
+			k = sdf.Rotate2d(*t.Rotate)
+			n++
+		}
+		if t.Mirror != "" {
+			switch t.Mirror {
+			case "X":
+				k = sdf.MirrorX()
+			case "Y":
+				k = sdf.MirrorY()
+			default:
+				return sdf.M33{}, fmt.Errorf("transform %d: bad mirror axis \"%s\"", i, t.Mirror)
+			}
+			n++
+		}
+		if n != 1 {
+			return sdf.M33{}, fmt.Errorf("transform %d: %d transforms, expected 1", i, n)
+		}
+		x = k.Mul(x)
+	}
+	return x, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,227 @@
+//-----------------------------------------------------------------------------
+
+//-----------------------------------------------------------------------------
+
+package scene
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jakoblorz/sdfx/obj"
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+const tolerance = 1e-9
+
+// sameSDF3 checks that two SDF3s have the same distances.
+func sameSDF3(t *testing.T, a, b sdf.SDF3) {
+	t.Helper()
+	if a.BoundingBox() != b.BoundingBox() {
+		t.Fatalf("bounding box %v != %v", a.BoundingBox(), b.BoundingBox())
+	}
+	bb := a.BoundingBox()
+	bb = sdf.NewBox3(bb.Center(), bb.Size().MulScalar(1.2))
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		p := bb.Min.Add(bb.Size().Mul(sdf.V3{X: rnd.Float64(), Y: rnd.Float64(), Z: rnd.Float64()}))
+		if da, db := a.Evaluate(p), b.Evaluate(p); math.Abs(da-db) > tolerance {
+			t.Fatalf("%v: %f != %f", p, da, db)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+const testScene = `
+Type: Union3D
+Min: {Type: RoundMin, K: 1}
+SDF:
+  - Type: Box3D
+    Size: {X: 10, Y: 10, Z: 10}
+  - Type: Transform3D
+    Matrix:
+      - Translate: {X: 0, Y: 0, Z: 5}
+    SDF: {Type: Sphere3D, Radius: 5}
+  # field names are matched without regard to case
+  - type: obj.Standoff3D
+    pillarHeight: 20
+    pillarDiameter: 6
+    holeDepth: 10
+    holeDiameter: 2.4
+`
+
+func Test_Decode(t *testing.T) {
+	n, err := Decode([]byte(testScene))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s0, err := n.SDF3()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same model in Go
+	box, _ := sdf.Box3D(sdf.V3{X: 10, Y: 10, Z: 10}, 0)
+	sphere, _ := sdf.Sphere3D(5)
+	standoff, _ := obj.Standoff3D(&obj.StandoffParms{
+		PillarHeight:   20,
+		PillarDiameter: 6,
+		HoleDepth:      10,
+		HoleDiameter:   2.4,
+	})
+	s1 := sdf.Union3D(box, sdf.Transform3D(sphere, sdf.Translate3d(sdf.V3{X: 0, Y: 0, Z: 5})), standoff)
+	s1.(*sdf.UnionSDF3).SetMin(sdf.RoundMin(1))
+	sameSDF3(t, s0, s1)
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_RoundTrip(t *testing.T) {
+	z := math.Pi / 4
+	n := NewNode(&Difference3D{
+		SDF0: NewNode(&Union3D{
+			SDF: []Node{
+				NewNode(&Bolt{obj.BoltParms{
+					Thread:      "M16x2",
+					Style:       "hex",
+					TotalLength: 50,
+					ShankLength: 10,
+				}}),
+				NewNode(&Transform3D{
+					SDF: NewNode(&Cylinder3D{Height: 10, Radius: 20, Round: 1}),
+					Matrix: Matrix3{
+						{RotateZ: &z},
+						{Translate: &sdf.V3{X: 0, Y: 0, Z: -5}},
+					},
+				}),
+			},
+			Min: &Blend{Type: "ChamferMin", K: 2},
+		}),
+		SDF1: NewNode(&Extrude3D{
+			SDF:    NewNode(&Polygon2D{Vertices: []sdf.V2{{X: 15, Y: 0}, {X: 25, Y: -5}, {X: 25, Y: 5}}}),
+			Height: 100,
+		}),
+		Max: &Blend{Type: "PolyMax", K: 0.5},
+	})
+	s0, err := n.SDF3()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []struct {
+		name   string
+		encode func(Node) ([]byte, error)
+	}{
+		{"json", EncodeJSON},
+		{"yaml", EncodeYAML},
+	} {
+		data0, err := f.encode(n)
+		if err != nil {
+			t.Fatalf("%s: %s", f.name, err)
+		}
+		m, err := Decode(data0)
+		if err != nil {
+			t.Fatalf("%s: %s\n%s", f.name, err, data0)
+		}
+		if !reflect.DeepEqual(n, m) {
+			t.Fatalf("%s: decoded node is not the same\n%s", f.name, data0)
+		}
+		data1, err := f.encode(m)
+		if err != nil {
+			t.Fatalf("%s: %s", f.name, err)
+		}
+		if !bytes.Equal(data0, data1) {
+			t.Fatalf("%s: encodings are not the same\n%s\n%s", f.name, data0, data1)
+		}
+		s1, err := m.SDF3()
+		if err != nil {
+			t.Fatalf("%s: %s", f.name, err)
+		}
+		sameSDF3(t, s0, s1)
+	}
+
+	// the zero values of all shapes
+	for _, name := range Types() {
+		s := NewNode(reflect.New(shapes[name]).Interface())
+		data, err := EncodeJSON(s)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		m, err := Decode(data)
+		if err != nil {
+			t.Fatalf("%s: %s\n%s", name, err, data)
+		}
+		if !reflect.DeepEqual(s, m) {
+			t.Fatalf("%s: decoded node is not the same\n%s", name, data)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Files(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	n, err := Decode([]byte(testScene))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"test.json", "test.yaml"} {
+		path := filepath.Join(dir, name)
+		if err := Save(path, n); err != nil {
+			t.Fatal(err)
+		}
+		m, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(n, m) {
+			t.Fatalf("%s: loaded node is not the same", name)
+		}
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "test.json"))
+	if !strings.HasPrefix(string(data), "{\n  \"Type\": \"Union3D\",") {
+		t.Errorf("unexpected json\n%s", data)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Errors(t *testing.T) {
+	for _, test := range []struct {
+		scene string
+		err   string // expected error (decode or build)
+	}{
+		{`{Type: Box4D}`, "unknown shape type \"Box4D\""},
+		{`{Radius: 1}`, "missing type name"},
+		{`{Type: Sphere3D, Radius: 1, Round: 1}`, "unknown field \"Round\""},
+		{`{Type: Sphere3D, Radius: -1}`, "Sphere3D: "},
+		{`{Type: Extrude3D, Height: 1}`, "Extrude3D: missing SDF2"},
+		{`{Type: Extrude3D, Height: 1, SDF: {Type: Sphere3D, Radius: 1}}`, "Sphere3D is not an SDF2"},
+		{`{Type: Union3D, SDF: [{Type: Sphere3D, Radius: 1}], Min: {Type: Round}}`, "unknown min function"},
+		{`{Type: Transform3D, SDF: {Type: Sphere3D, Radius: 1}, Matrix: [{Mirror: XY, RotateX: 1}]}`, "2 transforms"},
+		{`{Type: Union3D, SDF: [{Type: Box3D, Size: {X: 1, Y: 1, Z: 1}}, {Type: Sphere3D, Radius: 0}]}`, "Union3D: SDF 1: Sphere3D"},
+	} {
+		n, err := Decode([]byte(test.scene))
+		if err == nil {
+			_, err = n.SDF3()
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error \"%s\", got \"%v\"", test.scene, test.err, err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
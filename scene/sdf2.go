@@ -0,0 +1,488 @@
+//-----------------------------------------------------------------------------
+/*
+
+Scene graph nodes for the SDF2 constructors of the sdf package.
+
+*/
+//-----------------------------------------------------------------------------
+
+package scene
+
+import (
+	"fmt"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+func init() {
+	Register("Circle2D", &Circle2D{})
+	Register("Box2D", &Box2D{})
+	Register("Line2D", &Line2D{})
+	Register("Polygon2D", &Polygon2D{})
+	Register("CubicSpline2D", &CubicSpline2D{})
+	Register("ArcSpiral2D", &ArcSpiral2D{})
+	Register("FlatFlankCam2D", &FlatFlankCam2D{})
+	Register("ThreeArcCam2D", &ThreeArcCam2D{})
+	Register("GearRack2D", &GearRack2D{})
+	Register("ISOThread", &ISOThread{})
+	Register("AcmeThread", &AcmeThread{})
+	Register("ANSIButtressThread", &ANSIButtressThread{})
+	Register("PlasticButtressThread", &PlasticButtressThread{})
+	Register("LoadSVG", &LoadSVG{})
+	Register("LoadDXF", &LoadDXF{})
+	Register("Offset2D", &Offset2D{})
+	Register("Cut2D", &Cut2D{})
+	Register("Transform2D", &Transform2D{})
+	Register("ScaleUniform2D", &ScaleUniform2D{})
+	Register("Center2D", &Center2D{})
+	Register("CenterAndScale2D", &CenterAndScale2D{})
+	Register("Union2D", &Union2D{})
+	Register("Difference2D", &Difference2D{})
+	Register("Intersect2D", &Intersect2D{})
+	Register("Elongate2D", &Elongate2D{})
+	Register("Array2D", &Array2D{})
+	Register("RotateCopy2D", &RotateCopy2D{})
+	Register("Multi2D", &Multi2D{})
+	Register("LineOf2D", &LineOf2D{})
+}
+
+//-----------------------------------------------------------------------------
+
+// sdf2s builds the SDF2s for a list of nodes.
+func sdf2s(nodes []Node) ([]sdf.SDF2, error) {
+	s := make([]sdf.SDF2, len(nodes))
+	for i, n := range nodes {
+		var err error
+		s[i], err = n.SDF2()
+		if err != nil {
+			return nil, fmt.Errorf("SDF %d: %s", i, err)
+		}
+	}
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
+// Primitives
+
+// Circle2D is a node for sdf.Circle2D.
+type Circle2D struct {
+	Radius float64
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Circle2D) SDF2() (sdf.SDF2, error) {
+	return sdf.Circle2D(n.Radius)
+}
+
+// Box2D is a node for sdf.Box2D.
+type Box2D struct {
+	Size  sdf.V2
+	Round float64
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Box2D) SDF2() (sdf.SDF2, error) {
+	return sdf.Box2D(n.Size, n.Round), nil
+}
+
+// Line2D is a node for sdf.Line2D.
+type Line2D struct {
+	Length float64
+	Round  float64
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Line2D) SDF2() (sdf.SDF2, error) {
+	return sdf.Line2D(n.Length, n.Round), nil
+}
+
+// Polygon2D is a node for sdf.Polygon2D.
+type Polygon2D struct {
+	Vertices []sdf.V2
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Polygon2D) SDF2() (sdf.SDF2, error) {
+	return sdf.Polygon2D(n.Vertices)
+}
+
+// CubicSpline2D is a node for sdf.CubicSpline2D.
+type CubicSpline2D struct {
+	Knots []sdf.V2
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *CubicSpline2D) SDF2() (sdf.SDF2, error) {
+	return sdf.CubicSpline2D(n.Knots)
+}
+
+// ArcSpiral2D is a node for sdf.ArcSpiral2D.
+type ArcSpiral2D struct {
+	A, K       float64 // r = a*theta + k
+	Start, End float64 // start/end angle (radians)
+	D          float64 // offset distance
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *ArcSpiral2D) SDF2() (sdf.SDF2, error) {
+	return sdf.ArcSpiral2D(n.A, n.K, n.Start, n.End, n.D)
+}
+
+// FlatFlankCam2D is a node for sdf.FlatFlankCam2D.
+type FlatFlankCam2D struct {
+	Distance   float64 // circle to circle center distance
+	BaseRadius float64 // radius of base circle
+	NoseRadius float64 // radius of nose circle
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *FlatFlankCam2D) SDF2() (sdf.SDF2, error) {
+	return sdf.FlatFlankCam2D(n.Distance, n.BaseRadius, n.NoseRadius)
+}
+
+// ThreeArcCam2D is a node for sdf.ThreeArcCam2D.
+type ThreeArcCam2D struct {
+	Distance    float64 // circle to circle center distance
+	BaseRadius  float64 // radius of base circle
+	NoseRadius  float64 // radius of nose circle
+	FlankRadius float64 // radius of flank arc
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *ThreeArcCam2D) SDF2() (sdf.SDF2, error) {
+	return sdf.ThreeArcCam2D(n.Distance, n.BaseRadius, n.NoseRadius, n.FlankRadius)
+}
+
+// GearRack2D is a node for sdf.GearRack2D.
+type GearRack2D struct {
+	sdf.GearRackParms
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *GearRack2D) SDF2() (sdf.SDF2, error) {
+	return sdf.GearRack2D(&n.GearRackParms)
+}
+
+//-----------------------------------------------------------------------------
+// Thread Profiles
+
+// ISOThread is a node for sdf.ISOThread.
+type ISOThread struct {
+	Radius   float64 // radius of thread
+	Pitch    float64 // thread to thread distance
+	External bool    // external (or internal) thread
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *ISOThread) SDF2() (sdf.SDF2, error) {
+	return sdf.ISOThread(n.Radius, n.Pitch, n.External)
+}
+
+// AcmeThread is a node for sdf.AcmeThread.
+type AcmeThread struct {
+	Radius float64 // radius of thread
+	Pitch  float64 // thread to thread distance
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *AcmeThread) SDF2() (sdf.SDF2, error) {
+	return sdf.AcmeThread(n.Radius, n.Pitch)
+}
+
+// ANSIButtressThread is a node for sdf.ANSIButtressThread.
+type ANSIButtressThread struct {
+	Radius float64 // radius of thread
+	Pitch  float64 // thread to thread distance
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *ANSIButtressThread) SDF2() (sdf.SDF2, error) {
+	return sdf.ANSIButtressThread(n.Radius, n.Pitch)
+}
+
+// PlasticButtressThread is a node for sdf.PlasticButtressThread.
+type PlasticButtressThread struct {
+	Radius float64 // radius of thread
+	Pitch  float64 // thread to thread distance
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *PlasticButtressThread) SDF2() (sdf.SDF2, error) {
+	return sdf.PlasticButtressThread(n.Radius, n.Pitch)
+}
+
+//-----------------------------------------------------------------------------
+// Files
+
+// LoadSVG is a node for sdf.LoadSVG.
+type LoadSVG struct {
+	File string
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *LoadSVG) SDF2() (sdf.SDF2, error) {
+	return sdf.LoadSVG(n.File)
+}
+
+// LoadDXF is a node for sdf.LoadDXF.
+type LoadDXF struct {
+	File   string
+	Layers []string `json:",omitempty"` // all layers if empty
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *LoadDXF) SDF2() (sdf.SDF2, error) {
+	return sdf.LoadDXF(n.File, n.Layers...)
+}
+
+//-----------------------------------------------------------------------------
+// Operations
+
+// Offset2D is a node for sdf.Offset2D.
+type Offset2D struct {
+	SDF    Node
+	Offset float64
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Offset2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Offset2D(s, n.Offset), nil
+}
+
+// Cut2D is a node for sdf.Cut2D.
+type Cut2D struct {
+	SDF Node
+	A   sdf.V2 // point on line
+	V   sdf.V2 // direction of line
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Cut2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Cut2D(s, n.A, n.V), nil
+}
+
+// Transform2D is a node for sdf.Transform2D.
+type Transform2D struct {
+	SDF    Node
+	Matrix Matrix2
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Transform2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	m, err := n.Matrix.M33()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Transform2D(s, m), nil
+}
+
+// ScaleUniform2D is a node for sdf.ScaleUniform2D.
+type ScaleUniform2D struct {
+	SDF Node
+	K   float64
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *ScaleUniform2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.ScaleUniform2D(s, n.K), nil
+}
+
+// Center2D is a node for sdf.Center2D.
+type Center2D struct {
+	SDF Node
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Center2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Center2D(s), nil
+}
+
+// CenterAndScale2D is a node for sdf.CenterAndScale2D.
+type CenterAndScale2D struct {
+	SDF Node
+	K   float64
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *CenterAndScale2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.CenterAndScale2D(s, n.K), nil
+}
+
+// Union2D is a node for sdf.Union2D.
+type Union2D struct {
+	SDF []Node
+	Min *Blend `json:",omitempty"`
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Union2D) SDF2() (sdf.SDF2, error) {
+	s, err := sdf2s(n.SDF)
+	if err != nil {
+		return nil, err
+	}
+	min, err := n.Min.min()
+	if err != nil {
+		return nil, err
+	}
+	u := sdf.Union2D(s...)
+	if x, ok := u.(*sdf.UnionSDF2); ok {
+		x.SetMin(min)
+	}
+	return u, nil
+}
+
+// Difference2D is a node for sdf.Difference2D.
+type Difference2D struct {
+	SDF0, SDF1 Node
+	Max        *Blend `json:",omitempty"`
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Difference2D) SDF2() (sdf.SDF2, error) {
+	s, err := sdf2s([]Node{n.SDF0, n.SDF1})
+	if err != nil {
+		return nil, err
+	}
+	max, err := n.Max.max()
+	if err != nil {
+		return nil, err
+	}
+	d := sdf.Difference2D(s[0], s[1])
+	d.(*sdf.DifferenceSDF2).SetMax(max)
+	return d, nil
+}
+
+// Intersect2D is a node for sdf.Intersect2D.
+type Intersect2D struct {
+	SDF0, SDF1 Node
+	Max        *Blend `json:",omitempty"`
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Intersect2D) SDF2() (sdf.SDF2, error) {
+	s, err := sdf2s([]Node{n.SDF0, n.SDF1})
+	if err != nil {
+		return nil, err
+	}
+	max, err := n.Max.max()
+	if err != nil {
+		return nil, err
+	}
+	d := sdf.Intersect2D(s[0], s[1])
+	d.(*sdf.IntersectionSDF2).SetMax(max)
+	return d, nil
+}
+
+// Elongate2D is a node for sdf.Elongate2D.
+type Elongate2D struct {
+	SDF Node
+	H   sdf.V2
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Elongate2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Elongate2D(s, n.H), nil
+}
+
+// Array2D is a node for sdf.Array2D.
+type Array2D struct {
+	SDF  Node
+	Num  sdf.V2i
+	Step sdf.V2
+	Min  *Blend `json:",omitempty"`
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Array2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	min, err := n.Min.min()
+	if err != nil {
+		return nil, err
+	}
+	a := sdf.Array2D(s, n.Num, n.Step)
+	if x, ok := a.(*sdf.ArraySDF2); ok {
+		x.SetMin(min)
+	}
+	return a, nil
+}
+
+// RotateCopy2D is a node for sdf.RotateCopy2D.
+type RotateCopy2D struct {
+	SDF Node
+	Num int
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *RotateCopy2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.RotateCopy2D(s, n.Num), nil
+}
+
+// Multi2D is a node for sdf.Multi2D.
+type Multi2D struct {
+	SDF       Node
+	Positions sdf.V2Set
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *Multi2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Multi2D(s, n.Positions), nil
+}
+
+// LineOf2D is a node for sdf.LineOf2D.
+type LineOf2D struct {
+	SDF     Node
+	P0, P1  sdf.V2
+	Pattern string // "x" for a copy, "." for a gap
+}
+
+// SDF2 returns the SDF2 for the node.
+func (n *LineOf2D) SDF2() (sdf.SDF2, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.LineOf2D(s, n.P0, n.P1, n.Pattern), nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,533 @@
+//-----------------------------------------------------------------------------
+/*
+
+Scene graph nodes for the SDF3 constructors of the sdf package.
+
+*/
+//-----------------------------------------------------------------------------
+
+package scene
+
+import (
+	"fmt"
+
+	"github.com/jakoblorz/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+func init() {
+	Register("Box3D", &Box3D{})
+	Register("Sphere3D", &Sphere3D{})
+	Register("Cylinder3D", &Cylinder3D{})
+	Register("Capsule3D", &Capsule3D{})
+	Register("Cone3D", &Cone3D{})
+	Register("Extrude3D", &Extrude3D{})
+	Register("TwistExtrude3D", &TwistExtrude3D{})
+	Register("ScaleExtrude3D", &ScaleExtrude3D{})
+	Register("ScaleTwistExtrude3D", &ScaleTwistExtrude3D{})
+	Register("ExtrudeRounded3D", &ExtrudeRounded3D{})
+	Register("Loft3D", &Loft3D{})
+	Register("Revolve3D", &Revolve3D{})
+	Register("RevolveTheta3D", &RevolveTheta3D{})
+	Register("Screw3D", &Screw3D{})
+	Register("Transform3D", &Transform3D{})
+	Register("ScaleUniform3D", &ScaleUniform3D{})
+	Register("Union3D", &Union3D{})
+	Register("Difference3D", &Difference3D{})
+	Register("Intersect3D", &Intersect3D{})
+	Register("Elongate3D", &Elongate3D{})
+	Register("Cut3D", &Cut3D{})
+	Register("Offset3D", &Offset3D{})
+	Register("Array3D", &Array3D{})
+	Register("RotateUnion3D", &RotateUnion3D{})
+	Register("RotateCopy3D", &RotateCopy3D{})
+	Register("Multi3D", &Multi3D{})
+	Register("LineOf3D", &LineOf3D{})
+	Register("Orient3D", &Orient3D{})
+}
+
+//-----------------------------------------------------------------------------
+
+// sdf3s builds the SDF3s for a list of nodes.
+func sdf3s(nodes []Node) ([]sdf.SDF3, error) {
+	s := make([]sdf.SDF3, len(nodes))
+	for i, n := range nodes {
+		var err error
+		s[i], err = n.SDF3()
+		if err != nil {
+			return nil, fmt.Errorf("SDF %d: %s", i, err)
+		}
+	}
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
+// Primitives
+
+// Box3D is a node for sdf.Box3D.
+type Box3D struct {
+	Size  sdf.V3
+	Round float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Box3D) SDF3() (sdf.SDF3, error) {
+	return sdf.Box3D(n.Size, n.Round)
+}
+
+// Sphere3D is a node for sdf.Sphere3D.
+type Sphere3D struct {
+	Radius float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Sphere3D) SDF3() (sdf.SDF3, error) {
+	return sdf.Sphere3D(n.Radius)
+}
+
+// Cylinder3D is a node for sdf.Cylinder3D.
+type Cylinder3D struct {
+	Height float64
+	Radius float64
+	Round  float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Cylinder3D) SDF3() (sdf.SDF3, error) {
+	return sdf.Cylinder3D(n.Height, n.Radius, n.Round)
+}
+
+// Capsule3D is a node for sdf.Capsule3D.
+type Capsule3D struct {
+	Height float64
+	Radius float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Capsule3D) SDF3() (sdf.SDF3, error) {
+	return sdf.Capsule3D(n.Height, n.Radius)
+}
+
+// Cone3D is a node for sdf.Cone3D.
+type Cone3D struct {
+	Height float64
+	R0, R1 float64 // bottom/top radius
+	Round  float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Cone3D) SDF3() (sdf.SDF3, error) {
+	return sdf.Cone3D(n.Height, n.R0, n.R1, n.Round)
+}
+
+//-----------------------------------------------------------------------------
+// SDF2 to SDF3
+
+// Extrude3D is a node for sdf.Extrude3D.
+type Extrude3D struct {
+	SDF    Node // SDF2
+	Height float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Extrude3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Extrude3D(s, n.Height), nil
+}
+
+// TwistExtrude3D is a node for sdf.TwistExtrude3D.
+type TwistExtrude3D struct {
+	SDF    Node // SDF2
+	Height float64
+	Twist  float64 // radians
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *TwistExtrude3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.TwistExtrude3D(s, n.Height, n.Twist), nil
+}
+
+// ScaleExtrude3D is a node for sdf.ScaleExtrude3D.
+type ScaleExtrude3D struct {
+	SDF    Node // SDF2
+	Height float64
+	Scale  sdf.V2
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *ScaleExtrude3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.ScaleExtrude3D(s, n.Height, n.Scale), nil
+}
+
+// ScaleTwistExtrude3D is a node for sdf.ScaleTwistExtrude3D.
+type ScaleTwistExtrude3D struct {
+	SDF    Node // SDF2
+	Height float64
+	Twist  float64 // radians
+	Scale  sdf.V2
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *ScaleTwistExtrude3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.ScaleTwistExtrude3D(s, n.Height, n.Twist, n.Scale), nil
+}
+
+// ExtrudeRounded3D is a node for sdf.ExtrudeRounded3D.
+type ExtrudeRounded3D struct {
+	SDF    Node // SDF2
+	Height float64
+	Round  float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *ExtrudeRounded3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.ExtrudeRounded3D(s, n.Height, n.Round)
+}
+
+// Loft3D is a node for sdf.Loft3D.
+type Loft3D struct {
+	SDF0, SDF1 Node // bottom/top SDF2
+	Height     float64
+	Round      float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Loft3D) SDF3() (sdf.SDF3, error) {
+	s, err := sdf2s([]Node{n.SDF0, n.SDF1})
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Loft3D(s[0], s[1], n.Height, n.Round)
+}
+
+// Revolve3D is a node for sdf.Revolve3D.
+type Revolve3D struct {
+	SDF Node // SDF2
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Revolve3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Revolve3D(s)
+}
+
+// RevolveTheta3D is a node for sdf.RevolveTheta3D.
+type RevolveTheta3D struct {
+	SDF   Node    // SDF2
+	Theta float64 // radians
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *RevolveTheta3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.RevolveTheta3D(s, n.Theta)
+}
+
+// Screw3D is a node for sdf.Screw3D.
+type Screw3D struct {
+	Thread Node // SDF2 thread profile
+	Length float64
+	Pitch  float64
+	Starts int // < 0 for left hand threads
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Screw3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.Thread.SDF2()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Screw3D(s, n.Length, n.Pitch, n.Starts)
+}
+
+//-----------------------------------------------------------------------------
+// Operations
+
+// Transform3D is a node for sdf.Transform3D.
+type Transform3D struct {
+	SDF    Node
+	Matrix Matrix3
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Transform3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	m, err := n.Matrix.M44()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Transform3D(s, m), nil
+}
+
+// ScaleUniform3D is a node for sdf.ScaleUniform3D.
+type ScaleUniform3D struct {
+	SDF Node
+	K   float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *ScaleUniform3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.ScaleUniform3D(s, n.K), nil
+}
+
+// Union3D is a node for sdf.Union3D.
+type Union3D struct {
+	SDF []Node
+	Min *Blend `json:",omitempty"`
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Union3D) SDF3() (sdf.SDF3, error) {
+	s, err := sdf3s(n.SDF)
+	if err != nil {
+		return nil, err
+	}
+	min, err := n.Min.min()
+	if err != nil {
+		return nil, err
+	}
+	u := sdf.Union3D(s...)
+	if x, ok := u.(*sdf.UnionSDF3); ok {
+		x.SetMin(min)
+	}
+	return u, nil
+}
+
+// Difference3D is a node for sdf.Difference3D.
+type Difference3D struct {
+	SDF0, SDF1 Node
+	Max        *Blend `json:",omitempty"`
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Difference3D) SDF3() (sdf.SDF3, error) {
+	s, err := sdf3s([]Node{n.SDF0, n.SDF1})
+	if err != nil {
+		return nil, err
+	}
+	max, err := n.Max.max()
+	if err != nil {
+		return nil, err
+	}
+	d := sdf.Difference3D(s[0], s[1])
+	d.(*sdf.DifferenceSDF3).SetMax(max)
+	return d, nil
+}
+
+// Intersect3D is a node for sdf.Intersect3D.
+type Intersect3D struct {
+	SDF0, SDF1 Node
+	Max        *Blend `json:",omitempty"`
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Intersect3D) SDF3() (sdf.SDF3, error) {
+	s, err := sdf3s([]Node{n.SDF0, n.SDF1})
+	if err != nil {
+		return nil, err
+	}
+	max, err := n.Max.max()
+	if err != nil {
+		return nil, err
+	}
+	d := sdf.Intersect3D(s[0], s[1])
+	d.(*sdf.IntersectionSDF3).SetMax(max)
+	return d, nil
+}
+
+// Elongate3D is a node for sdf.Elongate3D.
+type Elongate3D struct {
+	SDF Node
+	H   sdf.V3
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Elongate3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Elongate3D(s, n.H), nil
+}
+
+// Cut3D is a node for sdf.Cut3D.
+type Cut3D struct {
+	SDF Node
+	A   sdf.V3 // point on plane
+	N   sdf.V3 // normal to plane
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Cut3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Cut3D(s, n.A, n.N), nil
+}
+
+// Offset3D is a node for sdf.Offset3D.
+type Offset3D struct {
+	SDF    Node
+	Offset float64
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Offset3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Offset3D(s, n.Offset), nil
+}
+
+// Array3D is a node for sdf.Array3D.
+type Array3D struct {
+	SDF  Node
+	Num  sdf.V3i
+	Step sdf.V3
+	Min  *Blend `json:",omitempty"`
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Array3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	min, err := n.Min.min()
+	if err != nil {
+		return nil, err
+	}
+	a := sdf.Array3D(s, n.Num, n.Step)
+	if x, ok := a.(*sdf.ArraySDF3); ok {
+		x.SetMin(min)
+	}
+	return a, nil
+}
+
+// RotateUnion3D is a node for sdf.RotateUnion3D.
+type RotateUnion3D struct {
+	SDF  Node
+	Num  int
+	Step Matrix3
+	Min  *Blend `json:",omitempty"`
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *RotateUnion3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	m, err := n.Step.M44()
+	if err != nil {
+		return nil, err
+	}
+	min, err := n.Min.min()
+	if err != nil {
+		return nil, err
+	}
+	r := sdf.RotateUnion3D(s, n.Num, m)
+	if x, ok := r.(*sdf.RotateUnionSDF3); ok {
+		x.SetMin(min)
+	}
+	return r, nil
+}
+
+// RotateCopy3D is a node for sdf.RotateCopy3D.
+type RotateCopy3D struct {
+	SDF Node
+	Num int
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *RotateCopy3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.RotateCopy3D(s, n.Num), nil
+}
+
+// Multi3D is a node for sdf.Multi3D.
+type Multi3D struct {
+	SDF       Node
+	Positions sdf.V3Set
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Multi3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Multi3D(s, n.Positions), nil
+}
+
+// LineOf3D is a node for sdf.LineOf3D.
+type LineOf3D struct {
+	SDF     Node
+	P0, P1  sdf.V3
+	Pattern string // "x" for a copy, "." for a gap
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *LineOf3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.LineOf3D(s, n.P0, n.P1, n.Pattern), nil
+}
+
+// Orient3D is a node for sdf.Orient3D.
+type Orient3D struct {
+	SDF        Node
+	Base       sdf.V3
+	Directions sdf.V3Set
+}
+
+// SDF3 returns the SDF3 for the node.
+func (n *Orient3D) SDF3() (sdf.SDF3, error) {
+	s, err := n.SDF.SDF3()
+	if err != nil {
+		return nil, err
+	}
+	return sdf.Orient3D(s, n.Base, n.Directions), nil
+}
+
+//-----------------------------------------------------------------------------
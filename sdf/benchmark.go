@@ -17,10 +17,13 @@ import (
 
 const nEvals = 10000000
 
+// benchBatch is the number of points per batch for batch evaluators.
+const benchBatch = 256
+
 //-----------------------------------------------------------------------------
 
-// fmtEPS returns a string with a formatted evaluations per second.
-func fmtEPS(eps float64) string {
+// FormatEvalRate returns a formatted evaluation rate (evaluations per second).
+func FormatEvalRate(eps float64) string {
 	if eps > 1000000000.0 {
 		return fmt.Sprintf("%.2f G evals/sec", eps/1000000000.0)
 	} else if eps > 1000000.0 {
@@ -33,38 +36,70 @@ func fmtEPS(eps float64) string {
 
 //-----------------------------------------------------------------------------
 
-// BenchmarkSDF2 reports the evaluation speed for an SDF2.
-func BenchmarkSDF2(description string, s SDF2) {
+// EvalRateSDF2 returns the evaluation rate (evaluations per second) for an SDF2
+// evaluated at n random points.
+func EvalRateSDF2(s SDF2, n int) float64 {
 	// sample over a region larger than the bounding box
 	box := NewBox2(s.BoundingBox().Center(), s.BoundingBox().Size().MulScalar(1.2))
-	points := box.RandomSet(nEvals)
+	points := box.RandomSet(n)
 
 	start := time.Now()
-	for _, p := range points {
-		s.Evaluate(p)
+	if _, ok := s.(BatchEvaluator2); ok {
+		out := make([]float64, benchBatch)
+		for i := 0; i < len(points); i += benchBatch {
+			j := i + benchBatch
+			if j > len(points) {
+				j = len(points)
+			}
+			Evaluate2N(s, points[i:j], out[:j-i])
+		}
+	} else {
+		for _, p := range points {
+			s.Evaluate(p)
+		}
 	}
 	elapsed := time.Since(start)
 
-	eps := float64(nEvals) * float64(time.Second) / float64(elapsed)
-	fmt.Printf("%s %s\n", description, fmtEPS(eps))
+	return float64(n) * float64(time.Second) / float64(elapsed)
+}
+
+// BenchmarkSDF2 reports the evaluation speed for an SDF2.
+func BenchmarkSDF2(description string, s SDF2) {
+	fmt.Printf("%s %s\n", description, FormatEvalRate(EvalRateSDF2(s, nEvals)))
 }
 
 //-----------------------------------------------------------------------------
 
-// BenchmarkSDF3 reports the evaluation speed for an SDF3.
-func BenchmarkSDF3(description string, s SDF3) {
+// EvalRateSDF3 returns the evaluation rate (evaluations per second) for an SDF3
+// evaluated at n random points.
+func EvalRateSDF3(s SDF3, n int) float64 {
 	// sample over a region larger than the bounding box
 	box := NewBox3(s.BoundingBox().Center(), s.BoundingBox().Size().MulScalar(1.2))
-	points := box.RandomSet(nEvals)
+	points := box.RandomSet(n)
 
 	start := time.Now()
-	for _, p := range points {
-		s.Evaluate(p)
+	if _, ok := s.(BatchEvaluator); ok {
+		out := make([]float64, benchBatch)
+		for i := 0; i < len(points); i += benchBatch {
+			j := i + benchBatch
+			if j > len(points) {
+				j = len(points)
+			}
+			Evaluate3N(s, points[i:j], out[:j-i])
+		}
+	} else {
+		for _, p := range points {
+			s.Evaluate(p)
+		}
 	}
 	elapsed := time.Since(start)
 
-	eps := float64(nEvals) * float64(time.Second) / float64(elapsed)
-	fmt.Printf("%s %s\n", description, fmtEPS(eps))
+	return float64(n) * float64(time.Second) / float64(elapsed)
+}
+
+// BenchmarkSDF3 reports the evaluation speed for an SDF3.
+func BenchmarkSDF3(description string, s SDF3) {
+	fmt.Printf("%s %s\n", description, FormatEvalRate(EvalRateSDF3(s, nEvals)))
 }
 
 //-----------------------------------------------------------------------------
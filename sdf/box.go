@@ -246,44 +246,18 @@ func (a Box2) MinMaxDist2(p V2) V2 {
 // Points within the box have minimum distance = 0.
 func (a Box3) MinMaxDist2(p V3) V2 {
 	maxDist2 := 0.0
-	minDist2 := 0.0
 
 	// translate the box so p is at the origin
 	a = a.Translate(p.Neg())
 
-	// consider the vertices
+	// the farthest point is a vertex (for the maximum)
 	vs := a.Vertices()
 	for i := range vs {
-		d2 := vs[i].Length2()
-		if i == 0 {
-			minDist2 = d2
-		} else {
-			minDist2 = math.Min(minDist2, d2)
-		}
-		maxDist2 = math.Max(maxDist2, d2)
+		maxDist2 = math.Max(maxDist2, vs[i].Length2())
 	}
 
-	// consider the faces (for the minimum)
-	withinX := a.Min.X < 0 && a.Max.X > 0
-	withinY := a.Min.Y < 0 && a.Max.Y > 0
-	withinZ := a.Min.Z < 0 && a.Max.Z > 0
-
-	if withinX && withinY && withinZ {
-		minDist2 = 0
-	} else {
-		if withinX && withinY {
-			d := math.Min(math.Abs(a.Max.Z), math.Abs(a.Min.Z))
-			minDist2 = math.Min(minDist2, d*d)
-		}
-		if withinX && withinZ {
-			d := math.Min(math.Abs(a.Max.Y), math.Abs(a.Min.Y))
-			minDist2 = math.Min(minDist2, d*d)
-		}
-		if withinY && withinZ {
-			d := math.Min(math.Abs(a.Max.X), math.Abs(a.Min.X))
-			minDist2 = math.Min(minDist2, d*d)
-		}
-	}
+	// the closest point may be on a face, an edge or a vertex (for the minimum)
+	minDist2 := V3{}.Clamp(a.Min, a.Max).Length2()
 
 	return V2{minDist2, maxDist2}
 }
@@ -0,0 +1,482 @@
+//-----------------------------------------------------------------------------
+/*
+
+DXF Import
+
+Convert the 2D outlines of a DXF file into an SDF2.
+
+Supported entities: LINE, ARC, CIRCLE, LWPOLYLINE (with bulges),
+POLYLINE/VERTEX, SPLINE and ELLIPSE. Other entities (E.g. text, dimensions,
+hatches and block inserts) are ignored.
+
+The entities are converted to polylines and chained end to end into closed
+contours. Nested contours are holes (the even-odd fill rule is used).
+The entities can be filtered by layer, so a drawing with several parts
+(or construction/dimension layers) can be imported one part at a time.
+
+See: https://help.autodesk.com/view/OARX/2018/ENU/?guid=GUID-7D07C886-FD1D-4A0C-A7AB-B4D21F18E484
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+const dxfArcSegments = 64    // line segments per full circle/ellipse
+const dxfSplineSegments = 16 // line segments per spline knot span
+const dxfTolerance = 1e-6    // end point matching tolerance (relative to the drawing size)
+
+//-----------------------------------------------------------------------------
+// Group Codes
+
+// dxfGroup is a DXF group code/value pair.
+type dxfGroup struct {
+	code  int
+	value string
+}
+
+// dxfEntity is a DXF entity with its group codes.
+type dxfEntity struct {
+	name  string
+	group []dxfGroup
+}
+
+// float returns the first float value for a group code.
+func (e *dxfEntity) float(code int, v float64) float64 {
+	for _, g := range e.group {
+		if g.code == code {
+			if x, err := strconv.ParseFloat(g.value, 64); err == nil {
+				return x
+			}
+		}
+	}
+	return v
+}
+
+// floats returns all the float values for a group code.
+func (e *dxfEntity) floats(code int) []float64 {
+	var v []float64
+	for _, g := range e.group {
+		if g.code == code {
+			x, _ := strconv.ParseFloat(g.value, 64)
+			v = append(v, x)
+		}
+	}
+	return v
+}
+
+// integer returns the first integer value for a group code.
+func (e *dxfEntity) integer(code int) int {
+	for _, g := range e.group {
+		if g.code == code {
+			x, _ := strconv.Atoi(g.value)
+			return x
+		}
+	}
+	return 0
+}
+
+// layer returns the entity layer.
+func (e *dxfEntity) layer() string {
+	for _, g := range e.group {
+		if g.code == 8 {
+			return g.value
+		}
+	}
+	return "0"
+}
+
+// mirrored returns true if the entity coordinate system is mirrored
+// (the extrusion direction is -z).
+func (e *dxfEntity) mirrored() bool {
+	return e.float(230, 1) < 0
+}
+
+// dxfEntities reads the entities section of a DXF file.
+func dxfEntities(r io.Reader) ([]*dxfEntity, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var entities []*dxfEntity
+	var e *dxfEntity
+	inEntities := false
+	prevSection := false
+	line := 0
+	for sc.Scan() {
+		line++
+		code, err := strconv.Atoi(strings.TrimSpace(sc.Text()))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad group code \"%s\"", line, sc.Text())
+		}
+		if !sc.Scan() {
+			return nil, fmt.Errorf("line %d: missing group value", line)
+		}
+		line++
+		value := strings.TrimSpace(sc.Text())
+		if code == 0 {
+			e = nil
+			switch value {
+			case "SECTION":
+				prevSection = true
+				continue
+			case "ENDSEC", "EOF":
+				inEntities = false
+			default:
+				if inEntities {
+					e = &dxfEntity{name: value}
+					entities = append(entities, e)
+				}
+			}
+		} else if code == 2 && prevSection {
+			inEntities = value == "ENTITIES"
+		} else if e != nil {
+			e.group = append(e.group, dxfGroup{code, value})
+		}
+		prevSection = false
+	}
+	return entities, sc.Err()
+}
+
+//-----------------------------------------------------------------------------
+// Entity Conversion
+
+// dxfArc returns the points on an arc (angles in radians, counter-clockwise).
+func dxfArc(c V2, r, a0, a1 float64) []V2 {
+	for a1 <= a0 {
+		a1 += Tau
+	}
+	n := int(math.Ceil((a1 - a0) / Tau * dxfArcSegments))
+	v := make([]V2, n+1)
+	for i := range v {
+		a := a0 + (a1-a0)*float64(i)/float64(n)
+		v[i] = c.Add(PolarToXY(r, a))
+	}
+	return v
+}
+
+// dxfBulge returns the points on a polyline segment with a bulge (excluding p0).
+// The bulge is tan(theta/4), where theta is the included angle of an arc.
+func dxfBulge(p0, p1 V2, bulge float64) []V2 {
+	if math.Abs(bulge) < epsilon {
+		return []V2{p1}
+	}
+	theta := 4 * math.Atan(bulge)
+	d := p1.Sub(p0)
+	l := d.Length()
+	r := l / (2 * math.Sin(theta/2))
+	// the center is on the perpendicular bisector of the chord
+	h := r * math.Cos(theta/2)
+	m := p0.Add(d.MulScalar(0.5))
+	c := m.Add(V2{-d.Y, d.X}.MulScalar(h / l))
+	a0 := math.Atan2(p0.Y-c.Y, p0.X-c.X)
+	n := int(math.Ceil(math.Abs(theta) / Tau * dxfArcSegments))
+	v := make([]V2, n)
+	for i := 1; i < n; i++ {
+		v[i-1] = c.Add(PolarToXY(math.Abs(r), a0+theta*float64(i)/float64(n)))
+	}
+	v[n-1] = p1
+	return v
+}
+
+// dxfVertices returns a polyline with bulges.
+func dxfVertices(x, y, bulge []float64, closed bool) []V2 {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	if n == 0 {
+		return nil
+	}
+	b := func(i int) float64 {
+		if i < len(bulge) {
+			return bulge[i]
+		}
+		return 0
+	}
+	v := []V2{{x[0], y[0]}}
+	for i := 1; i < n; i++ {
+		v = append(v, dxfBulge(V2{x[i-1], y[i-1]}, V2{x[i], y[i]}, b(i-1))...)
+	}
+	if closed {
+		v = append(v, dxfBulge(V2{x[n-1], y[n-1]}, v[0], b(n-1))...)
+	}
+	return v
+}
+
+// dxfSpline returns the points on a spline.
+func dxfSpline(e *dxfEntity) []V2 {
+	x, y := e.floats(10), e.floats(20)
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	knot := e.floats(40)
+	p := e.integer(71)
+	if n < 2 || p < 1 || len(knot) != n+p+1 {
+		// no usable control points, use the fit points
+		fx, fy := e.floats(11), e.floats(21)
+		if len(fy) < len(fx) {
+			fx = fx[:len(fy)]
+		}
+		v := make([]V2, len(fx))
+		for i := range fx {
+			v[i] = V2{fx[i], fy[i]}
+		}
+		return v
+	}
+	w := e.floats(41)
+	b := &bsplineBasis{p: p, n: n, knot: knot}
+	f0 := make([]float64, n)
+	f1 := make([]float64, n)
+	f2 := make([]float64, n)
+	t0, t1 := knot[p], knot[n]
+	m := dxfSplineSegments * (n - p)
+	v := make([]V2, m+1)
+	for i := range v {
+		b.eval(t0+(t1-t0)*float64(i)/float64(m), f0, f1, f2)
+		var s V2
+		var ws float64
+		for j := 0; j < n; j++ {
+			wj := 1.0
+			if j < len(w) {
+				wj = w[j]
+			}
+			s = s.Add(V2{x[j], y[j]}.MulScalar(f0[j] * wj))
+			ws += f0[j] * wj
+		}
+		v[i] = s.DivScalar(ws)
+	}
+	return v
+}
+
+// dxfEllipse returns the points on an ellipse.
+func dxfEllipse(e *dxfEntity) []V2 {
+	c := V2{e.float(10, 0), e.float(20, 0)}
+	major := V2{e.float(11, 0), e.float(21, 0)}
+	minor := V2{-major.Y, major.X}.MulScalar(e.float(40, 1))
+	t0, t1 := e.float(41, 0), e.float(42, Tau)
+	for t1 <= t0 {
+		t1 += Tau
+	}
+	n := int(math.Ceil((t1 - t0) / Tau * dxfArcSegments))
+	v := make([]V2, n+1)
+	for i := range v {
+		s, k := math.Sincos(t0 + (t1-t0)*float64(i)/float64(n))
+		v[i] = c.Add(major.MulScalar(k)).Add(minor.MulScalar(s))
+	}
+	return v
+}
+
+// dxfPolyline returns the polyline for an entity.
+func dxfPolyline(e *dxfEntity) []V2 {
+	var v []V2
+	switch e.name {
+	case "LINE":
+		v = []V2{{e.float(10, 0), e.float(20, 0)}, {e.float(11, 0), e.float(21, 0)}}
+	case "ARC":
+		c := V2{e.float(10, 0), e.float(20, 0)}
+		v = dxfArc(c, e.float(40, 0), DtoR(e.float(50, 0)), DtoR(e.float(51, 0)))
+	case "CIRCLE":
+		c := V2{e.float(10, 0), e.float(20, 0)}
+		v = dxfArc(c, e.float(40, 0), 0, Tau)
+	case "LWPOLYLINE":
+		// bulges are optional, so they need to be matched to their vertex
+		var x, y, bulge []float64
+		for _, g := range e.group {
+			switch g.code {
+			case 10:
+				f, _ := strconv.ParseFloat(g.value, 64)
+				x = append(x, f)
+				bulge = append(bulge, 0)
+			case 20:
+				f, _ := strconv.ParseFloat(g.value, 64)
+				y = append(y, f)
+			case 42:
+				if len(bulge) > 0 {
+					bulge[len(bulge)-1], _ = strconv.ParseFloat(g.value, 64)
+				}
+			}
+		}
+		v = dxfVertices(x, y, bulge, e.integer(70)&1 != 0)
+	case "SPLINE":
+		v = dxfSpline(e)
+	case "ELLIPSE":
+		v = dxfEllipse(e)
+	default:
+		return nil
+	}
+	return dxfMirror(e, v)
+}
+
+// dxfMirror flips the points of an entity with a mirrored coordinate system.
+func dxfMirror(e *dxfEntity, v []V2) []V2 {
+	if e.mirrored() {
+		for i := range v {
+			v[i].X = -v[i].X
+		}
+	}
+	return v
+}
+
+// dxfPolylines returns the polylines for a set of entities on the selected layers.
+func dxfPolylines(entities []*dxfEntity, layers []string) [][]V2 {
+	selected := func(e *dxfEntity) bool {
+		if len(layers) == 0 {
+			return true
+		}
+		for _, l := range layers {
+			if strings.EqualFold(l, e.layer()) {
+				return true
+			}
+		}
+		return false
+	}
+	var lines [][]V2
+	for i := 0; i < len(entities); i++ {
+		e := entities[i]
+		if e.name == "POLYLINE" {
+			// the vertices are the following VERTEX entities
+			var x, y, bulge []float64
+			j := i + 1
+			for ; j < len(entities) && entities[j].name == "VERTEX"; j++ {
+				x = append(x, entities[j].float(10, 0))
+				y = append(y, entities[j].float(20, 0))
+				bulge = append(bulge, entities[j].float(42, 0))
+			}
+			i = j - 1
+			if selected(e) {
+				lines = append(lines, dxfMirror(e, dxfVertices(x, y, bulge, e.integer(70)&1 != 0)))
+			}
+			continue
+		}
+		if !selected(e) {
+			continue
+		}
+		if v := dxfPolyline(e); len(v) >= 2 {
+			lines = append(lines, v)
+		}
+	}
+	return lines
+}
+
+//-----------------------------------------------------------------------------
+// Chaining
+
+// dxfChain joins polylines end to end into closed contours.
+func dxfChain(lines [][]V2) ([][]V2, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	// work out the matching tolerance
+	bb := Box2{lines[0][0], lines[0][0]}
+	for _, l := range lines {
+		for _, v := range l {
+			bb = bb.Extend(Box2{v, v})
+		}
+	}
+	tol := dxfTolerance * math.Max(1, bb.Size().MaxComponent())
+	// index the polyline end points on a grid
+	key := func(v V2) V2i {
+		return V2i{int(math.Floor(v.X / tol)), int(math.Floor(v.Y / tol))}
+	}
+	ends := make(map[V2i][]int)
+	for i, l := range lines {
+		for _, v := range []V2{l[0], l[len(l)-1]} {
+			k := key(v)
+			ends[k] = append(ends[k], i)
+		}
+	}
+	used := make([]bool, len(lines))
+	// find returns an unused polyline with an end point at v
+	find := func(v V2) (int, bool) {
+		k := key(v)
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, i := range ends[V2i{k[0] + dx, k[1] + dy}] {
+					if used[i] {
+						continue
+					}
+					l := lines[i]
+					if l[0].Equals(v, tol) {
+						return i, false
+					}
+					if l[len(l)-1].Equals(v, tol) {
+						return i, true
+					}
+				}
+			}
+		}
+		return -1, false
+	}
+
+	var contours [][]V2
+	for i := range lines {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		c := append([]V2{}, lines[i]...)
+		for !c[0].Equals(c[len(c)-1], tol) {
+			j, reverse := find(c[len(c)-1])
+			if j < 0 {
+				return nil, fmt.Errorf("open contour at %v", c[len(c)-1])
+			}
+			used[j] = true
+			l := lines[j]
+			if reverse {
+				for k := len(l) - 2; k >= 0; k-- {
+					c = append(c, l[k])
+				}
+			} else {
+				c = append(c, l[1:]...)
+			}
+		}
+		c = c[:len(c)-1]
+		if len(c) >= 3 {
+			contours = append(contours, c)
+		}
+	}
+	return contours, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// ImportDXF returns an SDF2 for the closed contours of a DXF document.
+// Only the entities on the given layers are used (all layers if none are given).
+func ImportDXF(r io.Reader, layers ...string) (SDF2, error) {
+	entities, err := dxfEntities(r)
+	if err != nil {
+		return nil, err
+	}
+	contours, err := dxfChain(dxfPolylines(entities, layers))
+	if err != nil {
+		return nil, err
+	}
+	if len(contours) == 0 {
+		return nil, ErrMsg("no closed contours")
+	}
+	return Polygons2D(contours, EvenOdd)
+}
+
+// LoadDXF returns an SDF2 for the closed contours of a DXF file.
+// Only the entities on the given layers are used (all layers if none are given).
+func LoadDXF(fname string, layers ...string) (SDF2, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ImportDXF(f, layers...)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,308 @@
+//-----------------------------------------------------------------------------
+/*
+
+SDF for 3D triangle meshes.
+
+The mesh triangles are stored in a bounding volume hierarchy.
+The distance is the distance to the closest triangle. The sign is
+determined by counting ray/mesh crossings, so the mesh should be closed.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"math"
+	"sort"
+)
+
+//-----------------------------------------------------------------------------
+
+const meshLeafSize = 4    // maximum number of triangles in a bvh leaf node
+const meshStackSize = 128 // bvh traversal stack size
+
+// The ray directions used for inside/outside testing.
+// These are deliberately not axis aligned to avoid hitting mesh edges/vertices.
+var meshRays = [3]V3{
+	{0.5773502691896258, 0.5773502691896257, 0.5773502691896258},
+	{-0.7071067811865475, 0.4082482904638631, 0.5773502691896258},
+	{0.2672612419124244, -0.8017837257372732, 0.5345224838248488},
+}
+
+// meshTriangle is a triangle with pre-calculated edge vectors.
+type meshTriangle struct {
+	a, b, c V3 // vertices
+	ab, ac  V3 // edge vectors
+}
+
+// meshNode is a node in the bounding volume hierarchy.
+type meshNode struct {
+	bb          Box3 // bounding box of the node
+	left, right int  // child node indices (internal nodes)
+	start, n    int  // triangle range (leaf nodes, n > 0)
+}
+
+// MeshSDF3 is an SDF3 made from a closed triangle mesh.
+type MeshSDF3 struct {
+	tri  []meshTriangle // mesh triangles
+	node []meshNode     // bvh nodes, node[0] is the root
+	bb   Box3           // bounding box
+}
+
+// Mesh3D returns an SDF3 made from a closed triangle mesh.
+// Each element of mesh is a triangle with three vertices.
+func Mesh3D(mesh [][3]V3) (SDF3, error) {
+	if len(mesh) == 0 {
+		return nil, ErrMsg("no triangles")
+	}
+	s := MeshSDF3{}
+	s.tri = make([]meshTriangle, len(mesh))
+	for i, t := range mesh {
+		s.tri[i] = meshTriangle{
+			a:  t[0],
+			b:  t[1],
+			c:  t[2],
+			ab: t[1].Sub(t[0]),
+			ac: t[2].Sub(t[0]),
+		}
+	}
+	s.build(0, len(s.tri))
+	s.bb = s.node[0].bb
+	return &s, nil
+}
+
+//-----------------------------------------------------------------------------
+// Bounding Volume Hierarchy
+
+// box returns the bounding box of a triangle.
+func (t *meshTriangle) box() Box3 {
+	return Box3{t.a.Min(t.b).Min(t.c), t.a.Max(t.b).Max(t.c)}
+}
+
+// centroid returns the centroid of a triangle.
+func (t *meshTriangle) centroid() V3 {
+	return t.a.Add(t.b).Add(t.c).DivScalar(3)
+}
+
+// build recursively builds the bvh for a range of triangles, returns the node index.
+func (s *MeshSDF3) build(start, end int) int {
+	idx := len(s.node)
+	s.node = append(s.node, meshNode{})
+	bb := s.tri[start].box()
+	cbb := Box3{s.tri[start].centroid(), s.tri[start].centroid()}
+	for i := start + 1; i < end; i++ {
+		bb = bb.Extend(s.tri[i].box())
+		c := s.tri[i].centroid()
+		cbb = Box3{cbb.Min.Min(c), cbb.Max.Max(c)}
+	}
+	if end-start <= meshLeafSize {
+		s.node[idx] = meshNode{bb: bb, start: start, n: end - start}
+		return idx
+	}
+	// split the triangles at the median of the longest centroid axis
+	size := cbb.Size()
+	axis := func(v V3) float64 { return v.X }
+	if size.Y >= size.X && size.Y >= size.Z {
+		axis = func(v V3) float64 { return v.Y }
+	} else if size.Z >= size.X && size.Z >= size.Y {
+		axis = func(v V3) float64 { return v.Z }
+	}
+	tri := s.tri[start:end]
+	sort.Slice(tri, func(i, j int) bool {
+		return axis(tri[i].centroid()) < axis(tri[j].centroid())
+	})
+	mid := (start + end) / 2
+	left := s.build(start, mid)
+	right := s.build(mid, end)
+	s.node[idx] = meshNode{bb: bb, left: left, right: right}
+	return idx
+}
+
+// boxDist2 returns the minimum distance squared from a point to a box.
+func boxDist2(b *Box3, p V3) float64 {
+	d := p.Sub(p.Clamp(b.Min, b.Max))
+	return d.Length2()
+}
+
+// rayHitsBox returns true if the ray p + t*v (t >= 0) intersects the box.
+func rayHitsBox(b *Box3, p, inv V3) bool {
+	t0 := (b.Min.X - p.X) * inv.X
+	t1 := (b.Max.X - p.X) * inv.X
+	tmin, tmax := math.Min(t0, t1), math.Max(t0, t1)
+	t0 = (b.Min.Y - p.Y) * inv.Y
+	t1 = (b.Max.Y - p.Y) * inv.Y
+	tmin, tmax = math.Max(tmin, math.Min(t0, t1)), math.Min(tmax, math.Max(t0, t1))
+	t0 = (b.Min.Z - p.Z) * inv.Z
+	t1 = (b.Max.Z - p.Z) * inv.Z
+	tmin, tmax = math.Max(tmin, math.Min(t0, t1)), math.Min(tmax, math.Max(t0, t1))
+	return tmax >= math.Max(tmin, 0)
+}
+
+//-----------------------------------------------------------------------------
+// Triangle Queries
+
+// dist2 returns the distance squared from p to the closest point on the triangle.
+// See: Real-Time Collision Detection, Christer Ericson, 5.1.5
+func (t *meshTriangle) dist2(p V3) float64 {
+	ap := p.Sub(t.a)
+	d1 := t.ab.Dot(ap)
+	d2 := t.ac.Dot(ap)
+	if d1 <= 0 && d2 <= 0 {
+		// vertex a
+		return ap.Length2()
+	}
+	bp := p.Sub(t.b)
+	d3 := t.ab.Dot(bp)
+	d4 := t.ac.Dot(bp)
+	if d3 >= 0 && d4 <= d3 {
+		// vertex b
+		return bp.Length2()
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		// edge ab
+		v := d1 / (d1 - d3)
+		return ap.Sub(t.ab.MulScalar(v)).Length2()
+	}
+	cp := p.Sub(t.c)
+	d5 := t.ab.Dot(cp)
+	d6 := t.ac.Dot(cp)
+	if d6 >= 0 && d5 <= d6 {
+		// vertex c
+		return cp.Length2()
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		// edge ac
+		w := d2 / (d2 - d6)
+		return ap.Sub(t.ac.MulScalar(w)).Length2()
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && (d4-d3) >= 0 && (d5-d6) >= 0 {
+		// edge bc
+		w := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		return bp.Sub(t.c.Sub(t.b).MulScalar(w)).Length2()
+	}
+	// face region
+	denom := 1 / (va + vb + vc)
+	v := vb * denom
+	w := vc * denom
+	return ap.Sub(t.ab.MulScalar(v)).Sub(t.ac.MulScalar(w)).Length2()
+}
+
+// intersects returns true if the ray p + t*v (t > 0) crosses the triangle.
+// See: Moller-Trumbore ray/triangle intersection.
+func (t *meshTriangle) intersects(p, v V3) bool {
+	h := v.Cross(t.ac)
+	a := t.ab.Dot(h)
+	if math.Abs(a) < epsilon {
+		// the ray is parallel to the triangle
+		return false
+	}
+	f := 1 / a
+	s := p.Sub(t.a)
+	u := f * s.Dot(h)
+	if u < 0 || u > 1 {
+		return false
+	}
+	q := s.Cross(t.ab)
+	w := f * v.Dot(q)
+	if w < 0 || u+w > 1 {
+		return false
+	}
+	return f*t.ac.Dot(q) > 0
+}
+
+//-----------------------------------------------------------------------------
+
+// distance returns the unsigned distance from p to the mesh.
+func (s *MeshSDF3) distance(p V3) float64 {
+	var stack [meshStackSize]int
+	sp := 0
+	stack[sp] = 0
+	sp++
+	dd := math.MaxFloat64
+	for sp > 0 {
+		sp--
+		n := &s.node[stack[sp]]
+		if boxDist2(&n.bb, p) >= dd {
+			continue
+		}
+		if n.n > 0 {
+			for i := n.start; i < n.start+n.n; i++ {
+				dd = math.Min(dd, s.tri[i].dist2(p))
+			}
+			continue
+		}
+		// visit the closer child first
+		l, r := n.left, n.right
+		if boxDist2(&s.node[l].bb, p) < boxDist2(&s.node[r].bb, p) {
+			l, r = r, l
+		}
+		stack[sp] = l
+		stack[sp+1] = r
+		sp += 2
+	}
+	return math.Sqrt(dd)
+}
+
+// crossings returns the number of times a ray from p crosses the mesh.
+func (s *MeshSDF3) crossings(p, v V3) int {
+	inv := V3{1 / v.X, 1 / v.Y, 1 / v.Z}
+	var stack [meshStackSize]int
+	sp := 0
+	stack[sp] = 0
+	sp++
+	count := 0
+	for sp > 0 {
+		sp--
+		n := &s.node[stack[sp]]
+		if !rayHitsBox(&n.bb, p, inv) {
+			continue
+		}
+		if n.n > 0 {
+			for i := n.start; i < n.start+n.n; i++ {
+				if s.tri[i].intersects(p, v) {
+					count++
+				}
+			}
+			continue
+		}
+		stack[sp] = n.left
+		stack[sp+1] = n.right
+		sp += 2
+	}
+	return count
+}
+
+// inside returns true if p is inside the mesh.
+// Rays are cast in several directions and the majority decides.
+func (s *MeshSDF3) inside(p V3) bool {
+	if boxDist2(&s.bb, p) > 0 {
+		return false
+	}
+	votes := 0
+	for _, v := range meshRays {
+		if s.crossings(p, v)&1 == 1 {
+			votes++
+		}
+	}
+	return votes >= 2
+}
+
+// Evaluate returns the minimum distance to a triangle mesh.
+func (s *MeshSDF3) Evaluate(p V3) float64 {
+	d := s.distance(p)
+	if s.inside(p) {
+		return -d
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a triangle mesh.
+func (s *MeshSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -3,6 +3,11 @@
 
 SDF for 2D polygons.
 
+PolySDF2 checks every edge for every evaluation. This is fine for small
+polygons, larger polygons use PolygonsSDF2 which stores the edges in a
+bounding volume hierarchy. It also handles multiple contours (E.g. polygons
+with holes) using a winding number fill rule.
+
 */
 //-----------------------------------------------------------------------------
 
@@ -10,6 +15,7 @@ package sdf
 
 import (
 	"math"
+	"sort"
 )
 
 //-----------------------------------------------------------------------------
@@ -23,13 +29,22 @@ type PolySDF2 struct {
 }
 
 // Polygon2D returns an SDF2 made from a closed set of line segments.
+// Polygons with many vertices use the (faster) Polygons2D implementation.
 func Polygon2D(vertex []V2) (SDF2, error) {
-	s := PolySDF2{}
-
 	n := len(vertex)
 	if n < 3 {
 		return nil, ErrMsg("number of vertices < 3")
 	}
+	if n >= polyBVHVertices {
+		return Polygons2D([][]V2{vertex}, NonZero)
+	}
+	return polygon2D(vertex), nil
+}
+
+// polygon2D returns a PolySDF2 for a closed set of line segments.
+func polygon2D(vertex []V2) *PolySDF2 {
+	s := PolySDF2{}
+	n := len(vertex)
 
 	// Close the loop (if necessary)
 	s.vertex = vertex
@@ -54,7 +69,7 @@ func Polygon2D(vertex []V2) (SDF2, error) {
 	}
 
 	s.bb = Box2{vmin, vmax}
-	return &s, nil
+	return &s
 }
 
 // Evaluate returns the minimum distance for a 2d polygon.
@@ -117,3 +132,220 @@ func (s *PolySDF2) BoundingBox() Box2 {
 }
 
 //-----------------------------------------------------------------------------
+// Polygons with a bounding volume hierarchy.
+
+const polyBVHVertices = 32 // Polygon2D uses Polygons2D for this many vertices
+const polyLeafSize = 4     // maximum number of edges in a bvh leaf node
+const polyStackSize = 64   // bvh traversal stack size
+
+// FillRule determines which regions of a set of contours are inside.
+type FillRule int
+
+// Fill rules.
+const (
+	NonZero FillRule = iota // inside if the winding number is not zero
+	EvenOdd                 // inside if the winding number is odd
+)
+
+// polyEdge is a polygon edge with pre-calculated line information.
+type polyEdge struct {
+	a, b   V2      // end points
+	v      V2      // unit line vector
+	length float64 // line length
+}
+
+// polyNode is a node in the bounding volume hierarchy.
+type polyNode struct {
+	bb          Box2 // bounding box of the node
+	left, right int  // child node indices (internal nodes)
+	start, n    int  // edge range (leaf nodes, n > 0)
+}
+
+// PolygonsSDF2 is an SDF2 made from a set of closed contours.
+type PolygonsSDF2 struct {
+	edge []polyEdge // contour edges
+	node []polyNode // bvh nodes, node[0] is the root
+	rule FillRule   // fill rule
+	bb   Box2       // bounding box
+}
+
+// Polygons2D returns an SDF2 made from a set of closed contours.
+// Holes are given by contours inside other contours. With the NonZero fill
+// rule holes should have the opposite direction to the enclosing contour.
+func Polygons2D(contours [][]V2, rule FillRule) (SDF2, error) {
+	s := PolygonsSDF2{rule: rule}
+	for _, c := range contours {
+		n := len(c)
+		if n < 3 {
+			return nil, ErrMsg("number of vertices < 3")
+		}
+		for i := range c {
+			a, b := c[i], c[(i+1)%n]
+			l := b.Sub(a).Length()
+			if l == 0 {
+				continue
+			}
+			s.edge = append(s.edge, polyEdge{a, b, b.Sub(a).DivScalar(l), l})
+		}
+	}
+	if len(s.edge) == 0 {
+		return nil, ErrMsg("no edges")
+	}
+	s.build(0, len(s.edge))
+	s.bb = s.node[0].bb
+	return &s, nil
+}
+
+// box returns the bounding box of an edge.
+func (e *polyEdge) box() Box2 {
+	return Box2{e.a.Min(e.b), e.a.Max(e.b)}
+}
+
+// centroid returns the center of an edge.
+func (e *polyEdge) centroid() V2 {
+	return e.a.Add(e.b).MulScalar(0.5)
+}
+
+// build recursively builds the bvh for a range of edges, returns the node index.
+func (s *PolygonsSDF2) build(start, end int) int {
+	idx := len(s.node)
+	s.node = append(s.node, polyNode{})
+	bb := s.edge[start].box()
+	cbb := Box2{s.edge[start].centroid(), s.edge[start].centroid()}
+	for i := start + 1; i < end; i++ {
+		bb = bb.Extend(s.edge[i].box())
+		c := s.edge[i].centroid()
+		cbb = Box2{cbb.Min.Min(c), cbb.Max.Max(c)}
+	}
+	if end-start <= polyLeafSize {
+		s.node[idx] = polyNode{bb: bb, start: start, n: end - start}
+		return idx
+	}
+	// split the edges at the median of the longest centroid axis
+	size := cbb.Size()
+	axis := func(v V2) float64 { return v.X }
+	if size.Y > size.X {
+		axis = func(v V2) float64 { return v.Y }
+	}
+	edge := s.edge[start:end]
+	sort.Slice(edge, func(i, j int) bool {
+		return axis(edge[i].centroid()) < axis(edge[j].centroid())
+	})
+	mid := (start + end) / 2
+	left := s.build(start, mid)
+	right := s.build(mid, end)
+	s.node[idx] = polyNode{bb: bb, left: left, right: right}
+	return idx
+}
+
+// box2Dist2 returns the minimum distance squared from a point to a box.
+func box2Dist2(b *Box2, p V2) float64 {
+	d := p.Sub(p.Clamp(b.Min, b.Max))
+	return d.Length2()
+}
+
+// dist2 returns the minimum distance squared from a point to an edge.
+func (e *polyEdge) dist2(p V2) float64 {
+	pa := p.Sub(e.a)
+	t := pa.Dot(e.v)
+	if t < 0 {
+		return pa.Length2()
+	}
+	if t > e.length {
+		return p.Sub(e.b).Length2()
+	}
+	dn := pa.Dot(V2{e.v.Y, -e.v.X})
+	return dn * dn
+}
+
+// winding returns the winding number contribution of an edge.
+// See: http://geomalgorithms.com/a03-_inclusion.html
+func (e *polyEdge) winding(p V2) int {
+	dn := p.Sub(e.a).Dot(V2{e.v.Y, -e.v.X})
+	if e.a.Y <= p.Y {
+		if e.b.Y > p.Y && dn < 0 {
+			// upward crossing, p is to the left of the edge
+			return 1
+		}
+	} else if e.b.Y <= p.Y && dn > 0 {
+		// downward crossing, p is to the right of the edge
+		return -1
+	}
+	return 0
+}
+
+// distance returns the unsigned distance from p to the contours.
+func (s *PolygonsSDF2) distance(p V2) float64 {
+	var stack [polyStackSize]int
+	sp := 0
+	stack[sp] = 0
+	sp++
+	dd := math.MaxFloat64
+	for sp > 0 {
+		sp--
+		n := &s.node[stack[sp]]
+		if box2Dist2(&n.bb, p) >= dd {
+			continue
+		}
+		if n.n > 0 {
+			for i := n.start; i < n.start+n.n; i++ {
+				dd = math.Min(dd, s.edge[i].dist2(p))
+			}
+			continue
+		}
+		// visit the closer child first
+		l, r := n.left, n.right
+		if box2Dist2(&s.node[l].bb, p) < box2Dist2(&s.node[r].bb, p) {
+			l, r = r, l
+		}
+		stack[sp] = l
+		stack[sp+1] = r
+		sp += 2
+	}
+	return math.Sqrt(dd)
+}
+
+// winding returns the winding number of the contours about p.
+// Only edges crossing the horizontal line through p to the right of p count.
+func (s *PolygonsSDF2) winding(p V2) int {
+	var stack [polyStackSize]int
+	sp := 0
+	stack[sp] = 0
+	sp++
+	wn := 0
+	for sp > 0 {
+		sp--
+		n := &s.node[stack[sp]]
+		if p.Y < n.bb.Min.Y || p.Y > n.bb.Max.Y || p.X > n.bb.Max.X {
+			continue
+		}
+		if n.n > 0 {
+			for i := n.start; i < n.start+n.n; i++ {
+				wn += s.edge[i].winding(p)
+			}
+			continue
+		}
+		stack[sp] = n.left
+		stack[sp+1] = n.right
+		sp += 2
+	}
+	return wn
+}
+
+// Evaluate returns the minimum distance to a set of contours.
+func (s *PolygonsSDF2) Evaluate(p V2) float64 {
+	d := s.distance(p)
+	wn := s.winding(p)
+	if (s.rule == NonZero && wn != 0) || (s.rule == EvenOdd && wn&1 != 0) {
+		// p is inside
+		return -d
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a set of contours.
+func (s *PolygonsSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -301,6 +301,7 @@ type ScrewSDF3 struct {
 	lead   float64 // distance per turn (starts * pitch)
 	length float64 // total length of screw
 	starts int     // number of thread starts
+	root   float64 // smallest radius of the thread surface
 	bb     Box3    // bounding box
 }
 
@@ -321,9 +322,47 @@ func Screw3D(
 	bb := s.thread.BoundingBox()
 	r := bb.Max.Y
 	s.bb = Box3{V3{-r, -r, -s.length}, V3{r, r, s.length}}
+	s.root = threadRoot(thread, pitch, r)
 	return &s, nil
 }
 
+// threadRoot returns the smallest radius at which a thread profile has a surface.
+// Below this radius the profile is the solid core of the screw.
+func threadRoot(thread SDF2, pitch, rMax float64) float64 {
+	const samples = 32
+	step := 1e-3 * pitch
+	root := rMax
+	for i := 0; i < samples; i++ {
+		// march up from the screw axis to the thread surface
+		x := pitch * ((float64(i)+0.5)/samples - 0.5)
+		for y := step; y < root; {
+			d := thread.Evaluate(V2{x, y})
+			if d >= 0 {
+				root = y
+				break
+			}
+			y += math.Max(-d, step)
+		}
+	}
+	return root
+}
+
+// lipschitz bounds the gradient of the thread profile distance at radius r.
+// The thread angle moves the profile along the axis by lead/Tau per radian,
+// which stretches space by sqrt(1 + (lead/(Tau*r))^2) at radius r.
+func (s *ScrewSDF3) lipschitz(r float64) float64 {
+	// below the root the profile is solid, and doesn't change with the thread angle
+	k := s.lead / (Tau * math.Max(r, s.root))
+	return math.Sqrt(1 + k*k)
+}
+
+// Lipschitz returns a bound on the factor by which the distance to the thread profile
+// overestimates the distance to the screw surface. Evaluate divides the profile distance
+// by the factor at the radius of the point.
+func (s *ScrewSDF3) Lipschitz() float64 {
+	return s.lipschitz(s.root)
+}
+
 // Evaluate returns the minimum distance to a 3d screw form.
 func (s *ScrewSDF3) Evaluate(p V3) float64 {
 	// map the 3d point back to the xy space of the profile
@@ -336,7 +375,7 @@ func (s *ScrewSDF3) Evaluate(p V3) float64 {
 	z := p.Z + s.lead*theta/Tau
 	p0.X = SawTooth(z, s.pitch)
 	// get the thread profile distance
-	d0 := s.thread.Evaluate(p0)
+	d0 := s.thread.Evaluate(p0) / s.lipschitz(p0.Y)
 	// create a region for the screw length
 	d1 := math.Abs(p.Z) - s.length
 	// return the intersection
@@ -21,6 +21,66 @@ type SDF2 interface {
 	BoundingBox() Box2
 }
 
+// BatchEvaluator2 is an optional interface for SDF2s that can evaluate many points
+// faster than one at a time. out[i] is set to the distance at ps[i].
+type BatchEvaluator2 interface {
+	EvaluateN(ps []V2, out []float64)
+}
+
+// Evaluate2N evaluates an SDF2 at a set of points.
+// The batch evaluator is used if the SDF2 has one.
+func Evaluate2N(s SDF2, ps []V2, out []float64) {
+	if b, ok := s.(BatchEvaluator2); ok {
+		b.EvaluateN(ps, out)
+		return
+	}
+	for i, p := range ps {
+		out[i] = s.Evaluate(p)
+	}
+}
+
+// GradientEvaluator2 is an optional interface for SDF2s with an analytic gradient.
+type GradientEvaluator2 interface {
+	Gradient(p V2) V2
+}
+
+// Gradient2 returns the gradient of an SDF2 at p.
+// The analytic gradient is used if the SDF2 has one, otherwise it is
+// estimated with central differences of step h.
+func Gradient2(s SDF2, p V2, h float64) V2 {
+	if g, ok := s.(GradientEvaluator2); ok {
+		return g.Gradient(p)
+	}
+	return FiniteGradient2(s.Evaluate, p, h)
+}
+
+// IntervalEvaluator2 is an optional interface for SDF2s that can bound their
+// distance over a box: lo <= Evaluate(p) <= hi for all p within the box.
+type IntervalEvaluator2 interface {
+	EvaluateInterval(b Box2) (lo, hi float64)
+}
+
+// EvaluateInterval2 returns bounds on the distance of an SDF2 over a box.
+// The interval evaluator is used if the SDF2 has one, otherwise the SDF2 is
+// assumed to be a distance bound and the distance at the center of the box
+// +/- the half diagonal is used.
+func EvaluateInterval2(s SDF2, b Box2) (float64, float64) {
+	if x, ok := s.(IntervalEvaluator2); ok {
+		return x.EvaluateInterval(b)
+	}
+	d := s.Evaluate(b.Center())
+	r := 0.5 * b.Size().Length()
+	return d - r, d + r
+}
+
+// FiniteGradient2 estimates the gradient of a distance function at p with central differences of step h.
+func FiniteGradient2(f func(V2) float64, p V2, h float64) V2 {
+	return V2{
+		X: f(p.Add(V2{X: h})) - f(p.Sub(V2{X: h})),
+		Y: f(p.Add(V2{Y: h})) - f(p.Sub(V2{Y: h})),
+	}.DivScalar(2 * h)
+}
+
 //-----------------------------------------------------------------------------
 // SDF2 Evaluation Caching (experimental)
 
@@ -63,6 +123,21 @@ func sdfBox2d(p, s V2) float64 {
 	return d.X
 }
 
+// sdfBox2dGradient returns the gradient of sdfBox2d.
+func sdfBox2dGradient(p, s V2) V2 {
+	q := p.Abs()
+	d := q.Sub(s)
+	var g V2
+	if d.X > 0 && d.Y > 0 {
+		g = d.Normalize()
+	} else if q.Y-q.X > s.Y-s.X {
+		g = V2{0, 1}
+	} else {
+		g = V2{1, 0}
+	}
+	return V2{math.Copysign(g.X, p.X), math.Copysign(g.Y, p.Y)}
+}
+
 //-----------------------------------------------------------------------------
 // 2D Circle
 
@@ -89,6 +164,27 @@ func (s *CircleSDF2) Evaluate(p V2) float64 {
 	return p.Length() - s.radius
 }
 
+// EvaluateN returns the minimum distances to a 2d circle.
+func (s *CircleSDF2) EvaluateN(ps []V2, out []float64) {
+	for i, p := range ps {
+		out[i] = p.Length() - s.radius
+	}
+}
+
+// Gradient returns the gradient of the distance to a 2d circle.
+func (s *CircleSDF2) Gradient(p V2) V2 {
+	if l := p.Length(); l != 0 {
+		return p.DivScalar(l)
+	}
+	return V2{}
+}
+
+// EvaluateInterval returns bounds on the distance to a 2d circle over a box.
+func (s *CircleSDF2) EvaluateInterval(b Box2) (float64, float64) {
+	d := b.MinMaxDist2(V2{})
+	return math.Sqrt(d.X) - s.radius, math.Sqrt(d.Y) - s.radius
+}
+
 // BoundingBox returns the bounding box of a 2d circle.
 func (s *CircleSDF2) BoundingBox() Box2 {
 	return s.bb
@@ -119,6 +215,18 @@ func (s *BoxSDF2) Evaluate(p V2) float64 {
 	return sdfBox2d(p, s.size) - s.round
 }
 
+// EvaluateN returns the minimum distances to a 2d box.
+func (s *BoxSDF2) EvaluateN(ps []V2, out []float64) {
+	for i, p := range ps {
+		out[i] = sdfBox2d(p, s.size) - s.round
+	}
+}
+
+// Gradient returns the gradient of the distance to a 2d box.
+func (s *BoxSDF2) Gradient(p V2) V2 {
+	return sdfBox2dGradient(p, s.size)
+}
+
 // BoundingBox returns the bounding box for a 2d box.
 func (s *BoxSDF2) BoundingBox() Box2 {
 	return s.bb
@@ -182,6 +290,12 @@ func (s *OffsetSDF2) Evaluate(p V2) float64 {
 	return s.sdf.Evaluate(p) - s.offset
 }
 
+// EvaluateInterval returns bounds on the distance to an offset SDF2 over a box.
+func (s *OffsetSDF2) EvaluateInterval(b Box2) (float64, float64) {
+	lo, hi := EvaluateInterval2(s.sdf, b)
+	return lo - s.offset, hi - s.offset
+}
+
 // BoundingBox returns the bounding box of an offset SDF2.
 func (s *OffsetSDF2) BoundingBox() Box2 {
 	return s.bb
@@ -216,6 +330,14 @@ func (s *IntersectionSDF2) Evaluate(p V2) float64 {
 	return s.max(s.s0.Evaluate(p), s.s1.Evaluate(p))
 }
 
+// EvaluateInterval returns bounds on the distance to the SDF2 intersection over a box.
+// The maximum function is assumed to be non-decreasing in each argument.
+func (s *IntersectionSDF2) EvaluateInterval(b Box2) (float64, float64) {
+	lo0, hi0 := EvaluateInterval2(s.s0, b)
+	lo1, hi1 := EvaluateInterval2(s.s1, b)
+	return s.max(lo0, lo1), s.max(hi0, hi1)
+}
+
 // SetMax sets the maximum function to control blending.
 func (s *IntersectionSDF2) SetMax(max MaxFunc) {
 	s.max = max
@@ -287,6 +409,30 @@ func (s *TransformSDF2) Evaluate(p V2) float64 {
 	return s.sdf.Evaluate(q)
 }
 
+// EvaluateN returns the minimum distances to a transformed SDF2.
+func (s *TransformSDF2) EvaluateN(ps []V2, out []float64) {
+	b := getV2s(len(ps))
+	q := *b
+	for i, p := range ps {
+		q[i] = s.mInv.MulPosition(p)
+	}
+	Evaluate2N(s.sdf, q, out)
+	v2Pool.Put(b)
+}
+
+// Gradient returns the gradient of the distance to a transformed SDF2.
+func (s *TransformSDF2) Gradient(p V2) V2 {
+	g := Gradient2(s.sdf, s.mInv.MulPosition(p), gradientStep)
+	// multiply by the transpose of the inverse
+	m := s.mInv
+	return V2{m.x00*g.X + m.x10*g.Y, m.x01*g.X + m.x11*g.Y}
+}
+
+// EvaluateInterval returns bounds on the distance to a transformed SDF2 over a box.
+func (s *TransformSDF2) EvaluateInterval(b Box2) (float64, float64) {
+	return EvaluateInterval2(s.sdf, s.mInv.MulBox(b))
+}
+
 // BoundingBox returns the bounding box of a transformed SDF2.
 func (s *TransformSDF2) BoundingBox() Box2 {
 	return s.bb
@@ -320,6 +466,13 @@ func (s *ScaleUniformSDF2) Evaluate(p V2) float64 {
 	return s.sdf.Evaluate(q) * s.k
 }
 
+// EvaluateInterval returns bounds on the distance to a uniformly scaled SDF2 over a box.
+func (s *ScaleUniformSDF2) EvaluateInterval(b Box2) (float64, float64) {
+	lo, hi := EvaluateInterval2(s.sdf, Scale2d(V2{s.invk, s.invk}).MulBox(b))
+	lo, hi = lo*s.k, hi*s.k
+	return math.Min(lo, hi), math.Max(lo, hi)
+}
+
 // BoundingBox returns the bounding box of an SDF2 with uniform scaling.
 func (s *ScaleUniformSDF2) BoundingBox() Box2 {
 	return s.bb
@@ -372,8 +525,8 @@ func Array2D(sdf SDF2, num V2i, step V2) SDF2 {
 }
 
 // SetMin sets the minimum function to control blending.
-func (s *ArraySDF2) SetMin(min MinFunc) {
-	s.min = min
+func (s *ArraySDF2) SetMin(min Minimizer) {
+	s.min = minFunc(min)
 }
 
 // Evaluate returns the minimum distance to a grid array of SDF2s.
@@ -441,8 +594,8 @@ func (s *RotateUnionSDF2) Evaluate(p V2) float64 {
 }
 
 // SetMin sets the minimum function to control blending.
-func (s *RotateUnionSDF2) SetMin(min MinFunc) {
-	s.min = min
+func (s *RotateUnionSDF2) SetMin(min Minimizer) {
+	s.min = minFunc(min)
 }
 
 // BoundingBox returns the bounding box of a union of rotated SDF2s.
@@ -560,9 +713,10 @@ func (s *SliceSDF2) BoundingBox() Box2 {
 
 // UnionSDF2 is a union of multiple SDF2 objects.
 type UnionSDF2 struct {
-	sdf []SDF2
-	min MinFunc
-	bb  Box2
+	sdf      []SDF2
+	min      MinFunc
+	partials func(a, b float64) (float64, float64)
+	bb       Box2
 }
 
 // Union2D returns the union of multiple SDF2 objects.
@@ -592,6 +746,7 @@ func Union2D(sdf ...SDF2) SDF2 {
 	}
 	s.bb = bb
 	s.min = math.Min
+	s.partials = hardMinPartials
 	return &s
 }
 
@@ -629,6 +784,65 @@ func (s *UnionSDF2) Evaluate(p V2) float64 {
 	return d
 }
 
+// EvaluateN returns the minimum distances to the SDF2 union.
+// As for Evaluate, an sdf is only evaluated at the points where its min/max
+// distances overlap those of the nearest bounding box.
+func (s *UnionSDF2) EvaluateN(ps []V2, out []float64) {
+	n, m := len(ps), len(s.sdf)
+	bv := getV2s(n*m + n)
+	bi := getInts(2 * n)
+	bd := getFloats(n)
+	// vs: the min/max distances for every bounding box and point
+	// q, k: the points (and their indices) for an sdf
+	vs, q := (*bv)[:n*m], (*bv)[n*m:]
+	index, k := (*bi)[:n], (*bi)[n:]
+	d := *bd
+	for i := range s.sdf {
+		bb := s.sdf[i].BoundingBox()
+		for j, p := range ps {
+			v := bb.MinMaxDist2(p)
+			vs[j*m+i] = v
+			// record the sdf with the minimum minimum d2 value
+			if i == 0 || v.X < vs[j*m+index[j]].X {
+				index[j] = i
+			}
+		}
+	}
+	for j := range out {
+		out[j] = math.NaN() // no distance yet
+	}
+	for i := range s.sdf {
+		// gather the points for this sdf
+		l := 0
+		for j, p := range ps {
+			if i == index[j] || vs[j*m+index[j]].Overlap(vs[j*m+i]) {
+				q[l] = p
+				k[l] = j
+				l++
+			}
+		}
+		switch {
+		case l == 0:
+			continue
+		case l == n:
+			// all of the points
+			Evaluate2N(s.sdf[i], ps, d)
+		default:
+			Evaluate2N(s.sdf[i], q[:l], d[:l])
+		}
+		for l, j := range k[:l] {
+			if math.IsNaN(out[j]) {
+				out[j] = d[l]
+			} else {
+				out[j] = s.min(out[j], d[l])
+			}
+		}
+	}
+	v2Pool.Put(bv)
+	intPool.Put(bi)
+	floatPool.Put(bd)
+}
+
 // EvaluateSlow returns the minimum distance to the SDF2 union.
 func (s *UnionSDF2) EvaluateSlow(p V2) float64 {
 	var d float64
@@ -643,9 +857,55 @@ func (s *UnionSDF2) EvaluateSlow(p V2) float64 {
 	return d
 }
 
+// Gradient returns the gradient of the distance to the SDF2 union.
+// Only the children with a non-zero weight in the blend have their gradient worked out.
+func (s *UnionSDF2) Gradient(p V2) V2 {
+	// the union is min(...min(min(d0, d1), d2)..., dn), so the weight of a child
+	// is its own partial times the partials of all the later minimums
+	wb, pb := getFloats(len(s.sdf)), getFloats(len(s.sdf))
+	w, da := *wb, *pb
+	var d float64
+	for i, x := range s.sdf {
+		dx := x.Evaluate(p)
+		if i == 0 {
+			d, w[i], da[i] = dx, 1, 1
+			continue
+		}
+		da[i], w[i] = s.partials(d, dx)
+		d = s.min(d, dx)
+	}
+	var g V2
+	k := 1.0
+	for i := len(s.sdf) - 1; i >= 0; i-- {
+		if wi := w[i] * k; wi != 0 {
+			g = g.Add(Gradient2(s.sdf[i], p, gradientStep).MulScalar(wi))
+		}
+		k *= da[i]
+	}
+	floatPool.Put(wb)
+	floatPool.Put(pb)
+	return g
+}
+
+// EvaluateInterval returns bounds on the distance to the SDF2 union over a box.
+// The minimum function is assumed to be non-decreasing in each argument.
+func (s *UnionSDF2) EvaluateInterval(b Box2) (float64, float64) {
+	var lo, hi float64
+	for i, x := range s.sdf {
+		xlo, xhi := EvaluateInterval2(x, b)
+		if i == 0 {
+			lo, hi = xlo, xhi
+		} else {
+			lo, hi = s.min(lo, xlo), s.min(hi, xhi)
+		}
+	}
+	return lo, hi
+}
+
 // SetMin sets the minimum function to control SDF2 blending.
-func (s *UnionSDF2) SetMin(min MinFunc) {
-	s.min = min
+func (s *UnionSDF2) SetMin(min Minimizer) {
+	s.min = minFunc(min)
+	s.partials = minPartials(min)
 }
 
 // BoundingBox returns the bounding box of an SDF2 union.
@@ -684,6 +944,14 @@ func (s *DifferenceSDF2) Evaluate(p V2) float64 {
 	return s.max(s.s0.Evaluate(p), -s.s1.Evaluate(p))
 }
 
+// EvaluateInterval returns bounds on the distance to the SDF2 difference over a box.
+// The maximum function is assumed to be non-decreasing in each argument.
+func (s *DifferenceSDF2) EvaluateInterval(b Box2) (float64, float64) {
+	lo0, hi0 := EvaluateInterval2(s.s0, b)
+	lo1, hi1 := EvaluateInterval2(s.s1, b)
+	return s.max(lo0, -hi1), s.max(hi0, -lo1)
+}
+
 // SetMax sets the maximum function to control blending.
 func (s *DifferenceSDF2) SetMax(max MaxFunc) {
 	s.max = max
@@ -725,6 +993,13 @@ func (s *ElongateSDF2) Evaluate(p V2) float64 {
 	return s.sdf.Evaluate(q)
 }
 
+// EvaluateInterval returns bounds on the distance to an elongated SDF2 over a box.
+func (s *ElongateSDF2) EvaluateInterval(b Box2) (float64, float64) {
+	// p - clamp(p) is non-decreasing on each axis
+	q := Box2{b.Min.Sub(b.Min.Clamp(s.hn, s.hp)), b.Max.Sub(b.Max.Clamp(s.hn, s.hp))}
+	return EvaluateInterval2(s.sdf, q)
+}
+
 // BoundingBox returns the bounding box of an elongated SDF2.
 func (s *ElongateSDF2) BoundingBox() Box2 {
 	return s.bb
@@ -21,6 +21,83 @@ type SDF3 interface {
 	BoundingBox() Box3
 }
 
+// BatchEvaluator is an optional interface for SDF3s that can evaluate many points
+// faster than one at a time. out[i] is set to the distance at ps[i].
+type BatchEvaluator interface {
+	EvaluateN(ps []V3, out []float64)
+}
+
+// Evaluate3N evaluates an SDF3 at a set of points.
+// The batch evaluator is used if the SDF3 has one.
+func Evaluate3N(s SDF3, ps []V3, out []float64) {
+	if b, ok := s.(BatchEvaluator); ok {
+		b.EvaluateN(ps, out)
+		return
+	}
+	for i, p := range ps {
+		out[i] = s.Evaluate(p)
+	}
+}
+
+// GradientEvaluator is an optional interface for SDF3s with an analytic gradient.
+type GradientEvaluator interface {
+	Gradient(p V3) V3
+}
+
+// Gradient3 returns the gradient of an SDF3 at p.
+// The analytic gradient is used if the SDF3 has one, otherwise it is
+// estimated with central differences of step h.
+func Gradient3(s SDF3, p V3, h float64) V3 {
+	if g, ok := s.(GradientEvaluator); ok {
+		return g.Gradient(p)
+	}
+	return FiniteGradient3(s.Evaluate, p, h)
+}
+
+// IntervalEvaluator is an optional interface for SDF3s that can bound their
+// distance over a box: lo <= Evaluate(p) <= hi for all p within the box.
+type IntervalEvaluator interface {
+	EvaluateInterval(b Box3) (lo, hi float64)
+}
+
+// EvaluateInterval3 returns bounds on the distance of an SDF3 over a box.
+// The interval evaluator is used if the SDF3 has one, otherwise the SDF3 is
+// assumed to be a distance bound and the distance at the center of the box
+// +/- the half diagonal is used.
+func EvaluateInterval3(s SDF3, b Box3) (float64, float64) {
+	if x, ok := s.(IntervalEvaluator); ok {
+		return x.EvaluateInterval(b)
+	}
+	return centerInterval3(s.Evaluate(b.Center()), b, 1)
+}
+
+// centerInterval3 returns the distance bounds over a box for a distance d at the
+// center of the box and a lipschitz constant k.
+func centerInterval3(d float64, b Box3, k float64) (float64, float64) {
+	r := 0.5 * k * b.Size().Length()
+	return d - r, d + r
+}
+
+// absInterval returns the range of |x| for x in [lo, hi].
+func absInterval(lo, hi float64) (float64, float64) {
+	switch {
+	case lo >= 0:
+		return lo, hi
+	case hi <= 0:
+		return -hi, -lo
+	}
+	return 0, math.Max(-lo, hi)
+}
+
+// FiniteGradient3 estimates the gradient of a distance function at p with central differences of step h.
+func FiniteGradient3(f func(V3) float64, p V3, h float64) V3 {
+	return V3{
+		X: f(p.Add(V3{X: h})) - f(p.Sub(V3{X: h})),
+		Y: f(p.Add(V3{Y: h})) - f(p.Sub(V3{Y: h})),
+		Z: f(p.Add(V3{Z: h})) - f(p.Sub(V3{Z: h})),
+	}.DivScalar(2 * h)
+}
+
 //-----------------------------------------------------------------------------
 // Basic SDF Functions
 
@@ -57,6 +134,23 @@ func sdfBox3d(p, s V3) float64 {
 	return d.MaxComponent()
 }
 
+// sdfBox3dGradient returns the gradient of sdfBox3d.
+func sdfBox3dGradient(p, s V3) V3 {
+	d := p.Abs().Sub(s)
+	var g V3
+	if d.X > 0 || d.Y > 0 || d.Z > 0 {
+		// outside: the direction to the nearest point
+		g = d.Max(V3{}).Normalize()
+	} else if d.X >= d.Y && d.X >= d.Z {
+		g = V3{1, 0, 0}
+	} else if d.Y >= d.Z {
+		g = V3{0, 1, 0}
+	} else {
+		g = V3{0, 0, 1}
+	}
+	return V3{math.Copysign(g.X, p.X), math.Copysign(g.Y, p.Y), math.Copysign(g.Z, p.Z)}
+}
+
 //-----------------------------------------------------------------------------
 
 // SorSDF3 solid of revolution, SDF2 to SDF3.
@@ -112,24 +206,40 @@ func Revolve3D(sdf SDF2) (SDF3, error) {
 	return RevolveTheta3D(sdf, 0)
 }
 
+// wedge returns the distance to the wedge of a partial revolution.
+func (s *SorSDF3) wedge(p V3) float64 {
+	// combine two vertical planes to give an intersection wedge
+	d := s.norm.Dot(V2{p.X, p.Y})
+	if s.theta < Pi {
+		return math.Max(-p.Y, d) // intersect
+	}
+	return math.Min(-p.Y, d) // union
+}
+
 // Evaluate returns the minimum distance to a solid of revolution.
 func (s *SorSDF3) Evaluate(p V3) float64 {
 	x := math.Sqrt(p.X*p.X + p.Y*p.Y)
 	a := s.sdf.Evaluate(V2{x, p.Z})
 	b := a
 	if s.theta != 0 {
-		// combine two vertical planes to give an intersection wedge
-		d := s.norm.Dot(V2{p.X, p.Y})
-		if s.theta < Pi {
-			b = math.Max(-p.Y, d) // intersect
-		} else {
-			b = math.Min(-p.Y, d) // union
-		}
+		b = s.wedge(p)
 	}
 	// return the intersection
 	return math.Max(a, b)
 }
 
+// EvaluateInterval returns bounds on the distance to a solid of revolution over a box.
+func (s *SorSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	r := Box2{V2{b.Min.X, b.Min.Y}, V2{b.Max.X, b.Max.Y}}.MinMaxDist2(V2{})
+	lo, hi := EvaluateInterval2(s.sdf, Box2{V2{math.Sqrt(r.X), b.Min.Z}, V2{math.Sqrt(r.Y), b.Max.Z}})
+	if s.theta != 0 {
+		// the wedge planes are distance fields
+		wlo, whi := centerInterval3(s.wedge(b.Center()), b, 1)
+		lo, hi = math.Max(lo, wlo), math.Max(hi, whi)
+	}
+	return lo, hi
+}
+
 // BoundingBox returns the bounding box for a solid of revolution.
 func (s *SorSDF3) BoundingBox() Box3 {
 	return s.bb
@@ -139,10 +249,12 @@ func (s *SorSDF3) BoundingBox() Box3 {
 
 // ExtrudeSDF3 extrudes an SDF2 to an SDF3.
 type ExtrudeSDF3 struct {
-	sdf     SDF2
-	height  float64
-	extrude ExtrudeFunc
-	bb      Box3
+	sdf        SDF2
+	height     float64
+	extrude    ExtrudeFunc
+	extrudeBox extrudeBoxFunc       // bounds the extruded points of a box, nil if unknown
+	lipschitz  extrudeLipschitzFunc // bounds the gradient over a box, nil for 1
+	bb         Box3
 }
 
 // Extrude3D does a linear extrude on an SDF3.
@@ -151,6 +263,7 @@ func Extrude3D(sdf SDF2, height float64) SDF3 {
 	s.sdf = sdf
 	s.height = height / 2
 	s.extrude = NormalExtrude
+	s.extrudeBox = normalExtrudeBox
 	// work out the bounding box
 	bb := sdf.BoundingBox()
 	s.bb = Box3{V3{bb.Min.X, bb.Min.Y, -s.height}, V3{bb.Max.X, bb.Max.Y, s.height}}
@@ -163,9 +276,11 @@ func TwistExtrude3D(sdf SDF2, height, twist float64) SDF3 {
 	s.sdf = sdf
 	s.height = height / 2
 	s.extrude = TwistExtrude(height, twist)
+	s.extrudeBox = twistExtrudeBox(height, twist)
+	s.lipschitz = twistExtrudeLipschitz(height, twist)
 	// work out the bounding box
 	bb := sdf.BoundingBox()
-	l := bb.Max.Length()
+	l := math.Sqrt(bb.MinMaxDist2(V2{}).Y)
 	s.bb = Box3{V3{-l, -l, -s.height}, V3{l, l, s.height}}
 	return &s
 }
@@ -176,6 +291,8 @@ func ScaleExtrude3D(sdf SDF2, height float64, scale V2) SDF3 {
 	s.sdf = sdf
 	s.height = height / 2
 	s.extrude = ScaleExtrude(height, scale)
+	s.extrudeBox = scaleExtrudeBox(height, scale)
+	s.lipschitz = scaleExtrudeLipschitz(height, scale)
 	// work out the bounding box
 	bb := sdf.BoundingBox()
 	bb = bb.Extend(Box2{bb.Min.Mul(scale), bb.Max.Mul(scale)})
@@ -189,27 +306,122 @@ func ScaleTwistExtrude3D(sdf SDF2, height, twist float64, scale V2) SDF3 {
 	s.sdf = sdf
 	s.height = height / 2
 	s.extrude = ScaleTwistExtrude(height, twist, scale)
+	s.extrudeBox = scaleTwistExtrudeBox(height, twist, scale)
+	s.lipschitz = scaleTwistExtrudeLipschitz(height, twist, scale)
 	// work out the bounding box
 	bb := sdf.BoundingBox()
 	bb = bb.Extend(Box2{bb.Min.Mul(scale), bb.Max.Mul(scale)})
-	l := bb.Max.Length()
+	l := math.Sqrt(bb.MinMaxDist2(V2{}).Y)
 	s.bb = Box3{V3{-l, -l, -s.height}, V3{l, l, s.height}}
 	return &s
 }
 
+// Lipschitz returns a bound on the factor by which the distance to the extruded SDF2
+// overestimates the distance to the extrusion within the bounding box.
+// Non-linear extrusions (twist, scale) stretch space, so Evaluate divides the
+// SDF2 distance by this factor (or a larger one outside the bounding box).
+func (s *ExtrudeSDF3) Lipschitz() float64 {
+	return s.lipschitzOver(s.bb)
+}
+
+// lipschitzOver bounds the gradient of the extruded SDF2 on paths between the
+// points of a box and the extrusion.
+func (s *ExtrudeSDF3) lipschitzOver(b Box3) float64 {
+	if s.lipschitz == nil {
+		return 1
+	}
+	return s.lipschitz(s.bb.Extend(b))
+}
+
+// lipschitzAt bounds the gradient of the extruded SDF2 on paths between p and the extrusion.
+func (s *ExtrudeSDF3) lipschitzAt(p V3) float64 {
+	return s.lipschitzOver(Box3{p, p})
+}
+
 // Evaluate returns the minimum distance to an extrusion.
 func (s *ExtrudeSDF3) Evaluate(p V3) float64 {
 	// sdf for the projected 2d surface
-	a := s.sdf.Evaluate(s.extrude(p))
+	a := s.sdf.Evaluate(s.extrude(p)) / s.lipschitzAt(p)
 	// sdf for the extrusion region: z = [-height, height]
 	b := math.Abs(p.Z) - s.height
 	// return the intersection
 	return math.Max(a, b)
 }
 
+// EvaluateN returns the minimum distances to an extrusion.
+func (s *ExtrudeSDF3) EvaluateN(ps []V3, out []float64) {
+	b := getV2s(len(ps))
+	q := *b
+	for i, p := range ps {
+		q[i] = s.extrude(p)
+	}
+	Evaluate2N(s.sdf, q, out)
+	v2Pool.Put(b)
+	for i, p := range ps {
+		out[i] = math.Max(out[i]/s.lipschitzAt(p), math.Abs(p.Z)-s.height)
+	}
+}
+
+// Gradient returns the gradient of the distance to an extrusion.
+// The extrusion function is differentiated numerically, it is cheap compared to the SDF2.
+func (s *ExtrudeSDF3) Gradient(p V3) V3 {
+	q := s.extrude(p)
+	a := s.sdf.Evaluate(q)
+	k := s.lipschitzAt(p)
+	if b := math.Abs(p.Z) - s.height; b > a/k {
+		return V3{0, 0, math.Copysign(1, p.Z)}
+	}
+	g := extrudeGradient(s.extrude, p, Gradient2(s.sdf, q, gradientStep)).DivScalar(k)
+	if s.lipschitz == nil {
+		return g
+	}
+	// d(a/k) = da/k - a*dk/k^2, k only varies outside the bounding box
+	dk := FiniteGradient3(s.lipschitzAt, p, gradientStep)
+	return g.Sub(dk.MulScalar(a / (k * k)))
+}
+
+// extrudeGradient returns the gradient of sdf(extrude(p)) given the SDF2 gradient g
+// at extrude(p). This is the transposed jacobian of the extrusion function times g.
+func extrudeGradient(extrude ExtrudeFunc, p V3, g V2) V3 {
+	const h = gradientStep
+	var dp [3]V2
+	for i, d := range []V3{{X: h}, {Y: h}, {Z: h}} {
+		dp[i] = extrude(p.Add(d)).Sub(extrude(p.Sub(d))).DivScalar(2 * h)
+	}
+	return V3{dp[0].Dot(g), dp[1].Dot(g), dp[2].Dot(g)}
+}
+
+// EvaluateInterval returns bounds on the distance to an extrusion over a box.
+func (s *ExtrudeSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	zlo, zhi := absInterval(b.Min.Z, b.Max.Z)
+	if s.extrudeBox == nil {
+		// the extruded region is unknown
+		return zlo - s.height, math.Inf(1)
+	}
+	lo, hi := EvaluateInterval2(s.sdf, s.extrudeBox(b))
+	// the sdf2 distance is divided by a factor in [k0, k1]
+	k0, k1 := s.Lipschitz(), s.lipschitzOver(b)
+	if lo < 0 {
+		lo /= k0
+	} else {
+		lo /= k1
+	}
+	if hi > 0 {
+		hi /= k0
+	} else {
+		hi /= k1
+	}
+	return math.Max(lo, zlo-s.height), math.Max(hi, zhi-s.height)
+}
+
 // SetExtrude sets the extrusion control function.
+// The distance bounds for the extrusion are unknown after this, so meshing can't prune
+// the space within the z range of the extrusion. The extrusion function is assumed
+// not to stretch space (a Lipschitz bound of 1).
 func (s *ExtrudeSDF3) SetExtrude(extrude ExtrudeFunc) {
 	s.extrude = extrude
+	s.extrudeBox = nil
+	s.lipschitz = nil
 }
 
 // BoundingBox returns the bounding box for an extrusion.
@@ -264,6 +476,19 @@ func (s *ExtrudeRoundedSDF3) Evaluate(p V3) float64 {
 	// sdf for the projected 2d surface
 	a := s.sdf.Evaluate(V2{p.X, p.Y})
 	b := math.Abs(p.Z) - s.height
+	return extrudeRounded(a, b) - s.round
+}
+
+// EvaluateInterval returns bounds on the distance to a rounded extrusion over a box.
+func (s *ExtrudeRoundedSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	alo, ahi := EvaluateInterval2(s.sdf, Box2{V2{b.Min.X, b.Min.Y}, V2{b.Max.X, b.Max.Y}})
+	zlo, zhi := absInterval(b.Min.Z, b.Max.Z)
+	// extrudeRounded is non-decreasing in a and b
+	return extrudeRounded(alo, zlo-s.height) - s.round, extrudeRounded(ahi, zhi-s.height) - s.round
+}
+
+// extrudeRounded combines the 2d distance a and the z distance b of a rounded extrusion.
+func extrudeRounded(a, b float64) float64 {
 	var d float64
 	if b > 0 {
 		// outside the object Z extent
@@ -284,7 +509,28 @@ func (s *ExtrudeRoundedSDF3) Evaluate(p V3) float64 {
 			d = a
 		}
 	}
-	return d - s.round
+	return d
+}
+
+// Gradient returns the gradient of the distance to a rounded extrusion.
+func (s *ExtrudeRoundedSDF3) Gradient(p V3) V3 {
+	q := V2{p.X, p.Y}
+	a := s.sdf.Evaluate(q)
+	b := math.Abs(p.Z) - s.height
+	ga := Gradient2(s.sdf, q, gradientStep)
+	gb := math.Copysign(1, p.Z)
+	switch {
+	case b > 0 && a >= 0:
+		// outside the edge: the direction to the nearest edge point
+		l := math.Sqrt((a * a) + (b * b))
+		if l == 0 {
+			return V3{}
+		}
+		return V3{ga.X * a / l, ga.Y * a / l, gb * b / l}
+	case b > 0 || (a < 0 && b > a):
+		return V3{0, 0, gb}
+	}
+	return V3{ga.X, ga.Y, 0}
 }
 
 // BoundingBox returns the bounding box for a rounded extrusion.
@@ -344,28 +590,7 @@ func (s *LoftSDF3) Evaluate(p V3) float64 {
 	a1 := s.sdf1.Evaluate(V2{p.X, p.Y})
 	a := Mix(a0, a1, k)
 
-	b := math.Abs(p.Z) - s.height
-	var d float64
-	if b > 0 {
-		// outside the object Z extent
-		if a < 0 {
-			// inside the boundary
-			d = b
-		} else {
-			// outside the boundary
-			d = math.Sqrt((a * a) + (b * b))
-		}
-	} else {
-		// within the object Z extent
-		if a < 0 {
-			// inside the boundary
-			d = math.Max(a, b)
-		} else {
-			// outside the boundary
-			d = a
-		}
-	}
-	return d - s.round
+	return extrudeRounded(a, math.Abs(p.Z)-s.height) - s.round
 }
 
 // BoundingBox returns the bounding box for a loft extrusion.
@@ -404,6 +629,18 @@ func (s *BoxSDF3) Evaluate(p V3) float64 {
 	return sdfBox3d(p, s.size) - s.round
 }
 
+// EvaluateN returns the minimum distances to a 3d box.
+func (s *BoxSDF3) EvaluateN(ps []V3, out []float64) {
+	for i, p := range ps {
+		out[i] = sdfBox3d(p, s.size) - s.round
+	}
+}
+
+// Gradient returns the gradient of the distance to a 3d box.
+func (s *BoxSDF3) Gradient(p V3) V3 {
+	return sdfBox3dGradient(p, s.size)
+}
+
 // BoundingBox returns the bounding box for a 3d box.
 func (s *BoxSDF3) BoundingBox() Box3 {
 	return s.bb
@@ -435,6 +672,27 @@ func (s *SphereSDF3) Evaluate(p V3) float64 {
 	return p.Length() - s.radius
 }
 
+// EvaluateN returns the minimum distances to a sphere.
+func (s *SphereSDF3) EvaluateN(ps []V3, out []float64) {
+	for i, p := range ps {
+		out[i] = p.Length() - s.radius
+	}
+}
+
+// Gradient returns the gradient of the distance to a sphere.
+func (s *SphereSDF3) Gradient(p V3) V3 {
+	if l := p.Length(); l != 0 {
+		return p.DivScalar(l)
+	}
+	return V3{}
+}
+
+// EvaluateInterval returns bounds on the distance to a sphere over a box.
+func (s *SphereSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	d := b.MinMaxDist2(V3{})
+	return math.Sqrt(d.X) - s.radius, math.Sqrt(d.Y) - s.radius
+}
+
 // BoundingBox returns the bounding box for a sphere.
 func (s *SphereSDF3) BoundingBox() Box3 {
 	return s.bb
@@ -485,6 +743,23 @@ func (s *CylinderSDF3) Evaluate(p V3) float64 {
 	return d - s.round
 }
 
+// EvaluateN returns the minimum distances to a cylinder.
+func (s *CylinderSDF3) EvaluateN(ps []V3, out []float64) {
+	for i, p := range ps {
+		out[i] = sdfBox2d(V2{V2{p.X, p.Y}.Length(), p.Z}, V2{s.radius, s.height}) - s.round
+	}
+}
+
+// Gradient returns the gradient of the distance to a cylinder.
+func (s *CylinderSDF3) Gradient(p V3) V3 {
+	r := V2{p.X, p.Y}.Length()
+	g := sdfBox2dGradient(V2{r, p.Z}, V2{s.radius, s.height})
+	if r == 0 {
+		return V3{0, 0, g.Y}
+	}
+	return V3{g.X * p.X / r, g.X * p.Y / r, g.Y}
+}
+
 // BoundingBox returns the bounding box for a cylinder.
 func (s *CylinderSDF3) BoundingBox() Box3 {
 	return s.bb
@@ -598,6 +873,34 @@ func (s *TransformSDF3) Evaluate(p V3) float64 {
 	return s.sdf.Evaluate(s.inverse.MulPosition(p))
 }
 
+// EvaluateN returns the minimum distances to a transformed SDF3.
+func (s *TransformSDF3) EvaluateN(ps []V3, out []float64) {
+	b := getV3s(len(ps))
+	q := *b
+	for i, p := range ps {
+		q[i] = s.inverse.MulPosition(p)
+	}
+	Evaluate3N(s.sdf, q, out)
+	v3Pool.Put(b)
+}
+
+// Gradient returns the gradient of the distance to a transformed SDF3.
+func (s *TransformSDF3) Gradient(p V3) V3 {
+	g := Gradient3(s.sdf, s.inverse.MulPosition(p), gradientStep)
+	// multiply by the transpose of the inverse
+	m := s.inverse
+	return V3{
+		m.x00*g.X + m.x10*g.Y + m.x20*g.Z,
+		m.x01*g.X + m.x11*g.Y + m.x21*g.Z,
+		m.x02*g.X + m.x12*g.Y + m.x22*g.Z,
+	}
+}
+
+// EvaluateInterval returns bounds on the distance to a transformed SDF3 over a box.
+func (s *TransformSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	return EvaluateInterval3(s.sdf, s.inverse.MulBox(b))
+}
+
 // BoundingBox returns the bounding box of a transformed SDF3.
 func (s *TransformSDF3) BoundingBox() Box3 {
 	return s.bb
@@ -631,6 +934,18 @@ func (s *ScaleUniformSDF3) Evaluate(p V3) float64 {
 	return s.sdf.Evaluate(q) * s.k
 }
 
+// Gradient returns the gradient of the distance to a uniformly scaled SDF3.
+func (s *ScaleUniformSDF3) Gradient(p V3) V3 {
+	return Gradient3(s.sdf, p.MulScalar(s.invK), gradientStep)
+}
+
+// EvaluateInterval returns bounds on the distance to a uniformly scaled SDF3 over a box.
+func (s *ScaleUniformSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	lo, hi := EvaluateInterval3(s.sdf, Scale3d(V3{s.invK, s.invK, s.invK}).MulBox(b))
+	lo, hi = lo*s.k, hi*s.k
+	return math.Min(lo, hi), math.Max(lo, hi)
+}
+
 // BoundingBox returns the bounding box of a uniformly scaled SDF3.
 func (s *ScaleUniformSDF3) BoundingBox() Box3 {
 	return s.bb
@@ -640,9 +955,10 @@ func (s *ScaleUniformSDF3) BoundingBox() Box3 {
 
 // UnionSDF3 is a union of SDF3s.
 type UnionSDF3 struct {
-	sdf []SDF3
-	min MinFunc
-	bb  Box3
+	sdf      []SDF3
+	min      MinFunc
+	partials func(a, b float64) (float64, float64)
+	bb       Box3
 }
 
 // Union3D returns the union of multiple SDF3 objects.
@@ -672,6 +988,7 @@ func Union3D(sdf ...SDF3) SDF3 {
 	}
 	s.bb = bb
 	s.min = math.Min
+	s.partials = hardMinPartials
 	return &s
 }
 
@@ -688,9 +1005,73 @@ func (s *UnionSDF3) Evaluate(p V3) float64 {
 	return d
 }
 
+// EvaluateN returns the minimum distances to an SDF3 union.
+func (s *UnionSDF3) EvaluateN(ps []V3, out []float64) {
+	b := getFloats(len(ps))
+	d := *b
+	for i, x := range s.sdf {
+		if i == 0 {
+			Evaluate3N(x, ps, out)
+			continue
+		}
+		Evaluate3N(x, ps, d)
+		for j := range out {
+			out[j] = s.min(out[j], d[j])
+		}
+	}
+	floatPool.Put(b)
+}
+
+// Gradient returns the gradient of the distance to an SDF3 union.
+// Each child SDF3 is evaluated to work out its weight in the blended distance,
+// the gradient is only worked out for the children with a non-zero weight.
+func (s *UnionSDF3) Gradient(p V3) V3 {
+	// the union is min(...min(min(d0, d1), d2)..., dn), so the weight of a child
+	// is its own partial times the partials of all the later minimums
+	wb, pb := getFloats(len(s.sdf)), getFloats(len(s.sdf))
+	w, da := *wb, *pb
+	var d float64
+	for i, x := range s.sdf {
+		dx := x.Evaluate(p)
+		if i == 0 {
+			d, w[i], da[i] = dx, 1, 1
+			continue
+		}
+		da[i], w[i] = s.partials(d, dx)
+		d = s.min(d, dx)
+	}
+	var g V3
+	k := 1.0
+	for i := len(s.sdf) - 1; i >= 0; i-- {
+		if wi := w[i] * k; wi != 0 {
+			g = g.Add(Gradient3(s.sdf[i], p, gradientStep).MulScalar(wi))
+		}
+		k *= da[i]
+	}
+	floatPool.Put(wb)
+	floatPool.Put(pb)
+	return g
+}
+
+// EvaluateInterval returns bounds on the distance to an SDF3 union over a box.
+// The minimum function is assumed to be non-decreasing in each argument.
+func (s *UnionSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	var lo, hi float64
+	for i, x := range s.sdf {
+		xlo, xhi := EvaluateInterval3(x, b)
+		if i == 0 {
+			lo, hi = xlo, xhi
+		} else {
+			lo, hi = s.min(lo, xlo), s.min(hi, xhi)
+		}
+	}
+	return lo, hi
+}
+
 // SetMin sets the minimum function to control blending.
-func (s *UnionSDF3) SetMin(min MinFunc) {
-	s.min = min
+func (s *UnionSDF3) SetMin(min Minimizer) {
+	s.min = minFunc(min)
+	s.partials = minPartials(min)
 }
 
 // BoundingBox returns the bounding box of an SDF3 union.
@@ -729,6 +1110,14 @@ func (s *DifferenceSDF3) Evaluate(p V3) float64 {
 	return s.max(s.s0.Evaluate(p), -s.s1.Evaluate(p))
 }
 
+// EvaluateInterval returns bounds on the distance to the SDF3 difference over a box.
+// The maximum function is assumed to be non-decreasing in each argument.
+func (s *DifferenceSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	lo0, hi0 := EvaluateInterval3(s.s0, b)
+	lo1, hi1 := EvaluateInterval3(s.s1, b)
+	return s.max(lo0, -hi1), s.max(hi0, -lo1)
+}
+
 // SetMax sets the maximum function to control blending.
 func (s *DifferenceSDF3) SetMax(max MaxFunc) {
 	s.max = max
@@ -770,6 +1159,13 @@ func (s *ElongateSDF3) Evaluate(p V3) float64 {
 	return s.sdf.Evaluate(q)
 }
 
+// EvaluateInterval returns bounds on the distance to an elongated SDF3 over a box.
+func (s *ElongateSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	// p - clamp(p) is non-decreasing on each axis
+	q := Box3{b.Min.Sub(b.Min.Clamp(s.hn, s.hp)), b.Max.Sub(b.Max.Clamp(s.hn, s.hp))}
+	return EvaluateInterval3(s.sdf, q)
+}
+
 // BoundingBox returns the bounding box of an elongated SDF3.
 func (s *ElongateSDF3) BoundingBox() Box3 {
 	return s.bb
@@ -804,6 +1200,14 @@ func (s *IntersectionSDF3) Evaluate(p V3) float64 {
 	return s.max(s.s0.Evaluate(p), s.s1.Evaluate(p))
 }
 
+// EvaluateInterval returns bounds on the distance to the SDF3 intersection over a box.
+// The maximum function is assumed to be non-decreasing in each argument.
+func (s *IntersectionSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	lo0, hi0 := EvaluateInterval3(s.s0, b)
+	lo1, hi1 := EvaluateInterval3(s.s1, b)
+	return s.max(lo0, lo1), s.max(hi0, hi1)
+}
+
 // SetMax sets the maximum function to control blending.
 func (s *IntersectionSDF3) SetMax(max MaxFunc) {
 	s.max = max
@@ -876,8 +1280,8 @@ func Array3D(sdf SDF3, num V3i, step V3) SDF3 {
 }
 
 // SetMin sets the minimum function to control blending.
-func (s *ArraySDF3) SetMin(min MinFunc) {
-	s.min = min
+func (s *ArraySDF3) SetMin(min Minimizer) {
+	s.min = minFunc(min)
 }
 
 // Evaluate returns the minimum distance to an XYZ SDF3 array.
@@ -947,8 +1351,8 @@ func (s *RotateUnionSDF3) Evaluate(p V3) float64 {
 }
 
 // SetMin sets the minimum function to control blending.
-func (s *RotateUnionSDF3) SetMin(min MinFunc) {
-	s.min = min
+func (s *RotateUnionSDF3) SetMin(min Minimizer) {
+	s.min = minFunc(min)
 }
 
 // BoundingBox returns the bounding box of a rotate/union object.
@@ -1078,6 +1482,12 @@ func (s *OffsetSDF3) Evaluate(p V3) float64 {
 	return s.sdf.Evaluate(p) - s.offset
 }
 
+// EvaluateInterval returns bounds on the distance to an offset SDF3 over a box.
+func (s *OffsetSDF3) EvaluateInterval(b Box3) (float64, float64) {
+	lo, hi := EvaluateInterval3(s.sdf, b)
+	return lo - s.offset, hi - s.offset
+}
+
 // BoundingBox returns the bounding box of an offset SDF3.
 func (s *OffsetSDF3) BoundingBox() Box3 {
 	return s.bb
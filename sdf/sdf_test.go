@@ -7,6 +7,8 @@ package sdf
 import (
 	"fmt"
 	"math"
+	"math/rand"
+	"strings"
 	"testing"
 )
 
@@ -734,6 +736,26 @@ func Test_Box2_Distances(t *testing.T) {
 	}
 }
 
+func Test_Box3_Distances(t *testing.T) {
+	b := NewBox3(V3{0, 0, 0}, V3{10, 10, 10})
+	tests := []struct {
+		p      V3
+		result V2
+	}{
+		{V3{0, 0, 0}, V2{0, 75}},
+		{V3{0, 0, 20}, V2{225, 675}},
+		{V3{10, 10, 0}, V2{50, 475}}, // closest to an edge
+		{V3{10, 10, 10}, V2{75, 675}},
+	}
+	for _, v := range tests {
+		x := b.MinMaxDist2(v.p)
+		if !x.Equals(v.result, tolerance) {
+			t.Logf("expected %v, actual %v\n", v.result, x)
+			t.Error("FAIL")
+		}
+	}
+}
+
 //-----------------------------------------------------------------------------
 
 func Test_Rotate_To_Vector(t *testing.T) {
@@ -774,3 +796,832 @@ func Test_Rotate_To_Vector(t *testing.T) {
 }
 
 //-----------------------------------------------------------------------------
+
+func Test_Mesh3D(t *testing.T) {
+	// a closed mesh for a 2x2x2 cube centered on the origin
+	v := []V3{
+		{-1, -1, -1}, {1, -1, -1}, {1, 1, -1}, {-1, 1, -1},
+		{-1, -1, 1}, {1, -1, 1}, {1, 1, 1}, {-1, 1, 1},
+	}
+	faces := [][3]int{
+		{0, 2, 1}, {0, 3, 2}, {4, 5, 6}, {4, 6, 7},
+		{0, 1, 5}, {0, 5, 4}, {2, 3, 7}, {2, 7, 6},
+		{1, 2, 6}, {1, 6, 5}, {0, 4, 7}, {0, 7, 3},
+	}
+	mesh := make([][3]V3, len(faces))
+	for i, f := range faces {
+		mesh[i] = [3]V3{v[f[0]], v[f[1]], v[f[2]]}
+	}
+	s0, err := Mesh3D(mesh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, _ := Box3D(V3{2, 2, 2}, 0)
+	if !s0.BoundingBox().Equals(s1.BoundingBox(), tolerance) {
+		t.Error("FAIL")
+	}
+	box := NewBox3(V3{}, V3{5, 5, 5})
+	for i := 0; i < 1000; i++ {
+		p := box.Random()
+		d0 := s0.Evaluate(p)
+		d1 := s1.Evaluate(p)
+		if math.Abs(d0-d1) > 1e-9 {
+			t.Logf("p %v expected %f, actual %f\n", p, d1, d0)
+			t.Error("FAIL")
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Sweep3D(t *testing.T) {
+	circle, _ := Circle2D(1)
+
+	// a straight path along z is an extrusion
+	path, err := PolylinePath3([]V3{{0, 0, -5}, {0, 0, 0}, {0, 0, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s0, err := Sweep3D(circle, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1 := Extrude3D(circle, 10)
+	box := NewBox3(V3{}, V3{4, 4, 14})
+	for i := 0; i < 1000; i++ {
+		p := box.Random()
+		if math.Abs(s0.Evaluate(p)-s1.Evaluate(p)) > tolerance {
+			t.Errorf("extrude %v: %f != %f", p, s0.Evaluate(p), s1.Evaluate(p))
+			break
+		}
+	}
+
+	// a circular path is a torus
+	var ring []V3
+	n := 200
+	for i := 0; i <= n; i++ {
+		a := Tau * float64(i) / float64(n)
+		ring = append(ring, V3{5 * math.Cos(a), 5 * math.Sin(a), 0})
+	}
+	path, _ = PolylinePath3(ring)
+	if !path.Closed() {
+		t.Error("path should be closed")
+	}
+	s0, _ = Sweep3D(circle, path)
+	box = NewBox3(V3{}, V3{12, 12, 4})
+	for i := 0; i < 1000; i++ {
+		p := box.Random()
+		d := math.Hypot(math.Hypot(p.X, p.Y)-5, p.Z) - 1
+		if math.Abs(s0.Evaluate(p)-d) > 0.01 {
+			t.Errorf("torus %v: %f != %f", p, s0.Evaluate(p), d)
+			break
+		}
+	}
+
+	// the frames line up at the start/end of a closed non-planar path
+	var knot []V3
+	for i := 0; i <= 6; i++ {
+		a := Tau * float64(i) / 6
+		knot = append(knot, V3{5 * math.Cos(a), 5 * math.Sin(a), 2 * math.Sin(2*a)})
+	}
+	path, err = CubicSplinePath3(knot, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := sweepFrames(path.p, path.Closed())
+	if !f[0].n.Equals(f[len(f)-1].n, tolerance) {
+		t.Errorf("frame mismatch %v != %v", f[0].n, f[len(f)-1].n)
+	}
+
+	// twist and scale
+	square := Box2D(V2{2, 2}, 0)
+	path, _ = BezierPath3([]V3{{0, 0, 0}, {0, 0, 1}, {0, 0, 2}, {0, 0, 3}}, 0)
+	s0, _ = ScaleTwistSweep3D(square, path, Pi/4, V2{2, 2})
+	if d := s0.Evaluate(V3{1, 0, 0}); math.Abs(d) > tolerance {
+		t.Errorf("start %f", d)
+	}
+	if d := s0.Evaluate(V3{2 * math.Sqrt2, 0, 3}); math.Abs(d) > tolerance {
+		t.Errorf("end %f", d)
+	}
+
+	// a closed path that reverses at the start point
+	path, _ = PolylinePath3([]V3{{0, 0, 0}, {0, 0, 5}, {0, 0, 0}})
+	s0, err = Sweep3D(circle, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := s0.Evaluate(V3{1, 0, 2}); math.Abs(d) > tolerance {
+		t.Errorf("reversed path %f", d)
+	}
+
+	if _, err := BezierPath3([]V3{{0, 0, 0}, {0, 0, 1}, {0, 0, 2}}, 0); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Surface(t *testing.T) {
+	// a bezier patch is a b-spline patch with no internal knots
+	cp := [][]V3{
+		{{0, 0, 0}, {0, 1, 1}, {0, 2, -1}, {0, 3, 0}},
+		{{1, 0, 1}, {1, 1, 2}, {1, 2, 0}, {1, 3, 1}},
+		{{2, 0, 0}, {2, 1, -1}, {2, 2, 1}, {2, 3, 0}},
+	}
+	b0, err := BezierPatch(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1, err := BSplinePatch(cp, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= 10; i++ {
+		for j := 0; j <= 10; j++ {
+			u, v := float64(i)/10, float64(j)/10
+			if !b0.Point(u, v).Equals(b1.Point(u, v), tolerance) {
+				t.Errorf("bezier != b-spline at %f %f", u, v)
+			}
+		}
+	}
+	if !b0.Point(1, 1).Equals(V3{2, 3, 0}, tolerance) {
+		t.Error("corner point")
+	}
+
+	// the derivatives agree with finite differences
+	const h = 1e-6
+	for _, uv := range []V2{{0.3, 0.6}, {0.7, 0.2}} {
+		e := b0.eval(uv.X, uv.Y)
+		du := b0.eval(uv.X+h, uv.Y)
+		dv := b0.eval(uv.X, uv.Y+h)
+		if !du.s.Sub(e.s).DivScalar(h).Equals(e.su, 1e-4) ||
+			!dv.s.Sub(e.s).DivScalar(h).Equals(e.sv, 1e-4) ||
+			!du.su.Sub(e.su).DivScalar(h).Equals(e.suu, 1e-3) ||
+			!dv.su.Sub(e.su).DivScalar(h).Equals(e.suv, 1e-3) ||
+			!dv.sv.Sub(e.sv).DivScalar(h).Equals(e.svv, 1e-3) {
+			t.Errorf("derivatives at %v", uv)
+		}
+	}
+	if n := testing.AllocsPerRun(100, func() { b1.eval(0.3, 0.6) }); n != 0 {
+		t.Errorf("eval allocates %f times", n)
+	}
+
+	// the projection agrees with dense sampling
+	shell, err := PatchShell3D([]*SurfacePatch{b0}, 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box := NewBox3(V3{1, 1.5, 0}, V3{3, 4, 3})
+	for k := 0; k < 50; k++ {
+		p := box.Random()
+		dmin := math.MaxFloat64
+		for i := 0; i <= 100; i++ {
+			for j := 0; j <= 100; j++ {
+				dmin = math.Min(dmin, b0.Point(float64(i)/100, float64(j)/100).Sub(p).Length())
+			}
+		}
+		d := shell.Evaluate(p) + 0.1
+		if d > dmin+tolerance || d < dmin-0.02 {
+			t.Errorf("%v: distance %f, sampled %f", p, d, dmin)
+			break
+		}
+	}
+
+	// a closed set of patches is a solid, E.g. a box made from 6 bilinear patches
+	v := []V3{
+		{-1, -1, -1}, {1, -1, -1}, {1, 1, -1}, {-1, 1, -1},
+		{-1, -1, 1}, {1, -1, 1}, {1, 1, 1}, {-1, 1, 1},
+	}
+	faces := [][4]int{
+		{0, 1, 3, 2}, {4, 7, 5, 6}, {0, 4, 1, 5},
+		{3, 2, 7, 6}, {1, 5, 2, 6}, {0, 3, 4, 7},
+	}
+	var patch []*SurfacePatch
+	for _, f := range faces {
+		x, err := BezierPatch([][]V3{{v[f[0]], v[f[1]]}, {v[f[2]], v[f[3]]}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		patch = append(patch, x)
+	}
+	s0, err := PatchSolid3D(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, _ := Box3D(V3{2, 2, 2}, 0)
+	box = NewBox3(V3{}, V3{4, 4, 4})
+	for i := 0; i < 1000; i++ {
+		p := box.Random()
+		if math.Abs(s0.Evaluate(p)-s1.Evaluate(p)) > tolerance {
+			t.Errorf("%v: %f != %f", p, s0.Evaluate(p), s1.Evaluate(p))
+			break
+		}
+	}
+
+	if _, err := BSplinePatch(cp, 3, 3); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Polygons2D(t *testing.T) {
+	// a star polygon, the bvh and simple polygon sdfs are the same
+	var star []V2
+	n := 200
+	for i := 0; i < n; i++ {
+		r := 5 + 3*float64(i&1)
+		star = append(star, PolarToXY(r, Tau*float64(i)/float64(n)))
+	}
+	s0 := polygon2D(star)
+	s1, err := Polygons2D([][]V2{star}, NonZero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, _ := Polygon2D(star)
+	if _, ok := s2.(*PolygonsSDF2); !ok {
+		t.Error("expected a bvh polygon")
+	}
+	box := NewBox2(V2{}, V2{20, 20})
+	for i := 0; i < 10000; i++ {
+		p := box.Random()
+		if math.Abs(s0.Evaluate(p)-s1.Evaluate(p)) > tolerance {
+			t.Errorf("%v: %f != %f", p, s0.Evaluate(p), s1.Evaluate(p))
+			break
+		}
+	}
+
+	// polygons with holes
+	outer := []V2{{-5, -5}, {5, -5}, {5, 5}, {-5, 5}}
+	inner := []V2{{-2, -2}, {2, -2}, {2, 2}, {-2, 2}}
+	hole := []V2{{-2, -2}, {-2, 2}, {2, 2}, {2, -2}}
+	tests := []struct {
+		contours [][]V2
+		rule     FillRule
+		d        float64 // distance at the origin
+	}{
+		{[][]V2{outer, inner}, EvenOdd, 2},
+		{[][]V2{outer, inner}, NonZero, -2},
+		{[][]V2{outer, hole}, NonZero, 2},
+		{[][]V2{outer, hole}, EvenOdd, 2},
+	}
+	for _, test := range tests {
+		s, err := Polygons2D(test.contours, test.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := s.Evaluate(V2{}); math.Abs(d-test.d) > tolerance {
+			t.Errorf("rule %d: %f != %f", test.rule, d, test.d)
+		}
+		if d := s.Evaluate(V2{3, 0}); math.Abs(d+1) > tolerance {
+			t.Errorf("rule %d: %f != -1", test.rule, d)
+		}
+		if d := s.Evaluate(V2{8, 9}); math.Abs(d-5) > tolerance {
+			t.Errorf("rule %d: %f != 5", test.rule, d)
+		}
+	}
+
+	// glyphs have holes
+	f, err := LoadFont("../examples/text/cmr10.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := TextSDF2(f, NewText("o"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := s.BoundingBox().Center()
+	if s.Evaluate(c) <= 0 {
+		t.Error("the center of an o should be outside")
+	}
+	if s.Evaluate(V2{s.BoundingBox().Min.X + 0.3, c.Y}) >= 0 {
+		t.Error("the side of an o should be inside")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_SVG(t *testing.T) {
+	// path data parsing
+	tests := []struct {
+		d string
+		v []V2
+	}{
+		{"M0,0 L10,0 10,10 Z", []V2{{0, 0}, {10, 0}, {10, 10}}},
+		{"m1-1h2v2H1z", []V2{{1, -1}, {3, -1}, {3, 1}, {1, 1}}},
+		{"M.5.5l1e1 0 0-10", []V2{{0.5, 0.5}, {10.5, 0.5}, {10.5, -9.5}}},
+	}
+	for _, test := range tests {
+		c, err := svgPath(test.d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok := len(c) == 1 && len(c[0]) == len(test.v)
+		for i := 0; ok && i < len(test.v); i++ {
+			ok = c[0][i].Equals(test.v[i], tolerance)
+		}
+		if !ok {
+			t.Errorf("%s: %v", test.d, c)
+		}
+	}
+
+	// arcs (with compact flags) and curves
+	c, err := svgPath("M-5 0a5 5 0 1110 0A5 5 0 01-5 0zM0 0c1 1 2 1 3 0s1-2 2 0q1 1 2 0t2 0z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c) != 2 {
+		t.Fatalf("expected 2 contours, got %d", len(c))
+	}
+	for _, v := range c[0] {
+		if math.Abs(v.Length()-5) > tolerance {
+			t.Errorf("%v is not on the circle", v)
+			break
+		}
+	}
+	if !c[1][len(c[1])-1].Equals(V2{9, 0}, tolerance) {
+		t.Errorf("curve end %v", c[1][len(c[1])-1])
+	}
+	for _, d := range []string{"L0 0", "M0 0 A1 1 0 2 1 1 1", "M0 0 X1 1", "M0 0 L10 0 L10 10 Z 5 5"} {
+		if _, err := svgPath(d); err == nil {
+			t.Errorf("%s: expected an error", d)
+		}
+	}
+
+	// a document
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="100mm" height="100mm" viewBox="0 0 100 100">
+  <defs><rect id="r" width="100" height="100"/></defs>
+  <g transform="translate(10 20) scale(2)">
+    <path fill-rule="evenodd" d="M0 0H10V10H0Z M5 2A3 3 0 0 1 5 8 A3 3 0 0 1 5 2Z"/>
+    <rect x="20" y="0" width="10" height="10" rx="2" style="fill:none;stroke:black"/>
+  </g>
+  <circle cx="50" cy="50" r="5" transform="rotate(90, 50, 50)"/>
+  <polygon points="60,60 70,60 70,70 60,70" style="display:none"/>
+</svg>`
+	s, err := ImportSVG(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// y is flipped
+	for _, test := range []struct {
+		p V2
+		d float64
+	}{
+		{V2{20, -30}, 6},     // the hole center
+		{V2{12, -22}, -2},    // the square
+		{V2{50, -50}, -5},    // the circle
+		{V2{65, -65}, 16.21}, // the hidden polygon
+		{V2{60, -30}, 0},     // the unfilled rect
+	} {
+		d := s.Evaluate(test.p)
+		if test.d == 0 {
+			if d <= 0 {
+				t.Errorf("%v should be outside", test.p)
+			}
+		} else if math.Abs(d-test.d) > 0.1 {
+			t.Errorf("%v: %f != %f", test.p, d, test.d)
+		}
+	}
+	if _, err := ImportSVG(strings.NewReader(`<svg><path d="M0 0" fill="none"/></svg>`)); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := ImportSVG(strings.NewReader(`<svg><path d="M0 0 L10 0 L10 10 Z 5 5"/></svg>`)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_DXF(t *testing.T) {
+	// entity group codes
+	entity := func(name, layer string, g ...interface{}) string {
+		s := fmt.Sprintf("0\n%s\n8\n%s\n", name, layer)
+		for i := 0; i < len(g); i += 2 {
+			s += fmt.Sprintf("%d\n%v\n", g[i], g[i+1])
+		}
+		return s
+	}
+	doc := "0\nSECTION\n2\nHEADER\n9\n$ACADVER\n1\nAC1015\n0\nENDSEC\n0\nSECTION\n2\nENTITIES\n" +
+		// a 40x20 plate with rounded right hand corners
+		entity("LWPOLYLINE", "plate", 90, 6, 70, 1,
+			10, 0, 20, 0, 10, 35, 20, 0, 42, 0.41421356237,
+			10, 40, 20, 5, 10, 40, 20, 15, 42, 0.41421356237,
+			10, 35, 20, 20, 10, 0, 20, 20) +
+		// a hole
+		entity("CIRCLE", "plate", 10, 10, 20, 10, 40, 3) +
+		// a slot made from lines and arcs (in any order/direction)
+		entity("LINE", "plate", 10, 20, 20, 8, 11, 25, 21, 8) +
+		entity("ARC", "plate", 10, 25, 20, 10, 40, 2, 50, 270, 51, 90) +
+		entity("LINE", "plate", 10, 20, 20, 12, 11, 25, 21, 12) +
+		entity("ARC", "plate", 10, 20, 20, 10, 40, 2, 50, 90, 51, 270) +
+		// other layers
+		entity("ELLIPSE", "other", 10, 100, 20, 0, 11, 10, 21, 0, 40, 0.5, 41, 0, 42, Tau) +
+		entity("SPLINE", "spline", 70, 0, 71, 3, 72, 8, 73, 4,
+			40, 0, 40, 0, 40, 0, 40, 0, 40, 2, 40, 2, 40, 2, 40, 2,
+			10, 0, 20, -50, 10, 10, 20, -50, 10, 10, 20, -40, 10, 0, 20, -50) +
+		entity("TEXT", "plate", 10, 0, 20, 0, 1, "ignored") +
+		// entities with a -z extrusion are mirrored
+		entity("ELLIPSE", "mirror", 10, -50, 20, 0, 11, 10, 21, 0, 40, 0.5, 41, 0, 42, Tau, 230, -1) +
+		entity("POLYLINE", "mirror", 66, 1, 70, 1, 230, -1) +
+		entity("VERTEX", "mirror", 10, -20, 20, 0) +
+		entity("VERTEX", "mirror", 10, -30, 20, 0) +
+		entity("VERTEX", "mirror", 10, -30, 20, 10) +
+		entity("VERTEX", "mirror", 10, -20, 20, 10) +
+		entity("SEQEND", "mirror") +
+		"0\nENDSEC\n0\nEOF\n"
+
+	s, err := ImportDXF(strings.NewReader(doc), "PLATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		p V2
+		d float64
+	}{
+		{V2{5, 10}, -2},     // plate
+		{V2{10, 10}, 3},     // hole center
+		{V2{22.5, 10}, 2},   // slot center
+		{V2{40, 20}, 2.07},  // rounded corner
+		{V2{100, 0}, 60.19}, // ellipse (not imported)
+	} {
+		if d := s.Evaluate(test.p); math.Abs(d-test.d) > 0.01 {
+			t.Errorf("%v: %f != %f", test.p, d, test.d)
+		}
+	}
+	s, err = ImportDXF(strings.NewReader(doc), "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := s.Evaluate(V2{100, 4}); math.Abs(d+1) > 0.01 {
+		t.Errorf("ellipse %f", d)
+	}
+	s, err = ImportDXF(strings.NewReader(doc), "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := s.Evaluate(V2{50, 4}); math.Abs(d+1) > 0.01 {
+		t.Errorf("mirrored ellipse %f", d)
+	}
+	if d := s.Evaluate(V2{25, 5}); math.Abs(d+5) > 0.01 {
+		t.Errorf("mirrored polyline %f", d)
+	}
+	s, err = ImportDXF(strings.NewReader(doc), "spline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := s.Evaluate(V2{5, -47}); math.Abs(d+0.833) > 0.01 {
+		t.Errorf("spline %f", d)
+	}
+
+	// open contours are an error
+	open := "0\nSECTION\n2\nENTITIES\n" + entity("LINE", "0", 10, 0, 20, 0, 11, 1, 21, 0) + "0\nENDSEC\n0\nEOF\n"
+	if _, err := ImportDXF(strings.NewReader(open)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Volume3D(t *testing.T) {
+	// samples of a linear field are interpolated exactly
+	size := V3i{6, 5, 4}
+	data := make([]float32, size[0]*size[1]*size[2])
+	for z := 0; z < size[2]; z++ {
+		for y := 0; y < size[1]; y++ {
+			for x := 0; x < size[0]; x++ {
+				data[(z*size[1]+y)*size[0]+x] = float32(x - 2*y + z)
+			}
+		}
+	}
+	for _, interp := range []Interpolation{Trilinear, Tricubic} {
+		s, err := Volume3D(V3{-1, -1, -1}, 0.5, size, data, interp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []V3{{0.1, -0.3, 0.2}, {-0.8, 0.9, 0.4}, {0.3, 0.2, -0.9}} {
+			g := p.Sub(V3{-1, -1, -1}).MulScalar(2)
+			if d := s.Evaluate(p); math.Abs(d-(g.X-2*g.Y+g.Z)) > 1e-4 {
+				t.Errorf("interpolation %d, %v: %f", interp, p, d)
+			}
+		}
+		// outside the grid
+		if d := s.Evaluate(V3{-3, -1, -1}); math.Abs(d-2) > 1e-4 {
+			t.Errorf("interpolation %d, outside: %f", interp, d)
+		}
+	}
+	if _, err := Volume3D(V3{}, 1, size, data[1:], Trilinear); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_BatchEvaluate(t *testing.T) {
+	sphere, _ := Sphere3D(3)
+	box, _ := Box3D(V3{4, 5, 6}, 0.5)
+	cylinder, _ := Cylinder3D(5, 2, 0.5)
+	cone, _ := Cone3D(4, 2, 1, 0) // no batch evaluator
+	circle, _ := Circle2D(2)
+	profile := Union2D(Transform2D(circle, Translate2d(V2{1, 0})), Box2D(V2{3, 2}, 0.2))
+	s := Union3D(
+		Transform3D(sphere, Translate3d(V3{1, 2, 3})),
+		Transform3D(box, RotateZ(0.3)),
+		cylinder,
+		cone,
+		Extrude3D(profile, 3),
+		TwistExtrude3D(Transform2D(Box2D(V2{2, 1}, 0), Translate2d(V2{0, 4})), 4, Pi),
+	)
+	s.(*UnionSDF3).SetMin(PolyMin(0.5))
+	if _, ok := s.(BatchEvaluator); !ok {
+		t.Fatal("expected a batch evaluator")
+	}
+	bb := NewBox3(V3{}, V3{12, 12, 12})
+	ps := bb.RandomSet(1000)
+	out := make([]float64, len(ps))
+	Evaluate3N(s, ps, out)
+	for i, p := range ps {
+		if d := s.Evaluate(p); math.Abs(d-out[i]) > tolerance {
+			t.Fatalf("%v: %f != %f", p, out[i], d)
+		}
+	}
+	// 2d unions cull by bounding box, the batch must cull the same way
+	profile2 := Union2D(
+		profile,
+		Transform2D(Box2D(V2{1, 1}, 0.1), Translate2d(V2{3, 3})),
+		Transform2D(circle, Translate2d(V2{-3, 2})),
+	)
+	profile2.(*UnionSDF2).SetMin(RoundMin(0.5))
+	bb2 := NewBox2(V2{}, V2{12, 12})
+	ps2 := bb2.RandomSet(1000)
+	out2 := make([]float64, len(ps2))
+	for _, s2 := range []SDF2{profile, profile2} {
+		if _, ok := s2.(BatchEvaluator2); !ok {
+			t.Fatal("expected a 2d batch evaluator")
+		}
+		Evaluate2N(s2, ps2, out2)
+		for i, p := range ps2 {
+			if d := s2.Evaluate(p); math.Abs(d-out2[i]) > tolerance {
+				t.Fatalf("%v: %f != %f", p, out2[i], d)
+			}
+		}
+	}
+}
+
+// benchmarkSDF3 returns an SDF3 tree for the evaluation benchmarks.
+func benchmarkSDF3() SDF3 {
+	sphere, _ := Sphere3D(3)
+	box, _ := Box3D(V3{4, 5, 6}, 0.5)
+	circle, _ := Circle2D(2)
+	profile := Union2D(Transform2D(circle, Translate2d(V2{1, 0})), Box2D(V2{3, 2}, 0.2))
+	return Union3D(
+		Transform3D(sphere, Translate3d(V3{1, 2, 3})),
+		Transform3D(box, RotateZ(0.3)),
+		Extrude3D(profile, 3),
+	)
+}
+
+// benchmarkPoints are batches of points as evaluated by the meshers:
+// the corners of an octree leaf and a batch of the evaluation engine.
+var benchmarkPoints = []int{8, 256}
+
+func Benchmark_Evaluate(b *testing.B) {
+	s := benchmarkSDF3()
+	for _, n := range benchmarkPoints {
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			bb := NewBox3(V3{}, V3{12, 12, 12})
+			ps := bb.RandomSet(n)
+			out := make([]float64, n)
+			b.ReportAllocs()
+			for k := 0; k < b.N; k++ {
+				for i, p := range ps {
+					out[i] = s.Evaluate(p)
+				}
+			}
+		})
+	}
+}
+
+func Benchmark_EvaluateN(b *testing.B) {
+	s := benchmarkSDF3()
+	for _, n := range benchmarkPoints {
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			bb := NewBox3(V3{}, V3{12, 12, 12})
+			ps := bb.RandomSet(n)
+			out := make([]float64, n)
+			b.ReportAllocs()
+			for k := 0; k < b.N; k++ {
+				Evaluate3N(s, ps, out)
+			}
+		})
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Gradient(t *testing.T) {
+	sphere, _ := Sphere3D(3)
+	box, _ := Box3D(V3{4, 5, 6}, 0.5)
+	cylinder, _ := Cylinder3D(5, 2, 0.5)
+	circle, _ := Circle2D(2)
+	rounded, _ := ExtrudeRounded3D(Box2D(V2{4, 3}, 0.5), 3, 0.5)
+	union := Union3D(
+		Transform3D(sphere, Translate3d(V3{1, 2, 3})),
+		Transform3D(box, RotateZ(0.3).Mul(Scale3d(V3{1, 2, 1}))),
+	)
+	union.(*UnionSDF3).SetMin(RoundMin(1))
+	for i, s := range []SDF3{
+		sphere,
+		box,
+		cylinder,
+		rounded,
+		union,
+		ScaleUniform3D(cylinder, 2),
+		Extrude3D(Transform2D(circle, Translate2d(V2{1, 0})), 3),
+		TwistExtrude3D(Box2D(V2{4, 2}, 0.2), 4, Pi),
+		ScaleTwistExtrude3D(circle, 4, 1, V2{0.5, 0.8}),
+	} {
+		if _, ok := s.(GradientEvaluator); !ok {
+			t.Fatalf("%d: expected a gradient evaluator", i)
+		}
+		rnd := rand.New(rand.NewSource(1))
+		for j := 0; j < 200; j++ {
+			p := V3{rnd.Float64(), rnd.Float64(), rnd.Float64()}.MulScalar(12).SubScalar(6)
+			g0 := Gradient3(s, p, 0)
+			g1 := FiniteGradient3(s.Evaluate, p, 1e-6)
+			if !g0.Equals(g1, 1e-4) {
+				t.Fatalf("%d, %v: %v != %v", i, p, g0, g1)
+			}
+		}
+	}
+
+	// fallback
+	cone, _ := Cone3D(4, 2, 1, 0)
+	p := V3{3, 1, 0.5}
+	if !Gradient3(cone, p, 1e-6).Equals(FiniteGradient3(cone.Evaluate, p, 1e-6), tolerance) {
+		t.Error("bad fallback gradient")
+	}
+
+	// 2d
+	s2 := Union2D(circle, Transform2D(Box2D(V2{3, 2}, 0), Translate2d(V2{2, 1})))
+	s2.(*UnionSDF2).SetMin(PolyMin(0.5))
+	rnd := rand.New(rand.NewSource(1))
+	for j := 0; j < 200; j++ {
+		p := V2{rnd.Float64(), rnd.Float64()}.MulScalar(10).SubScalar(5)
+		g0 := Gradient2(s2, p, 0)
+		g1 := FiniteGradient2(s2.(*UnionSDF2).EvaluateSlow, p, 1e-6)
+		if !g0.Equals(g1, 1e-4) {
+			t.Fatalf("%v: %v != %v", p, g0, g1)
+		}
+	}
+
+	// blend partials
+	for i, min := range []Minimizer{
+		RoundMin(1),
+		ChamferMin(1),
+		ExpMin(4),
+		PowMin(8),
+		PolyMin(0.5),
+		MinFunc(func(a, b float64) float64 { return math.Min(a, b) - 0.1 }),
+	} {
+		f := minFunc(min)
+		partials := minPartials(min)
+		for j := 0; j < 200; j++ {
+			a, b := rnd.Float64()*4, rnd.Float64()*4
+			h := 1e-6
+			da, db := partials(a, b)
+			fa := (f(a+h, b) - f(a-h, b)) / (2 * h)
+			fb := (f(a, b+h) - f(a, b-h)) / (2 * h)
+			if math.Abs(da-fa) > 1e-4 || math.Abs(db-fb) > 1e-4 {
+				t.Fatalf("%d, (%f, %f): (%f, %f) != (%f, %f)", i, a, b, da, db, fa, fb)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_EvaluateInterval(t *testing.T) {
+	sphere, _ := Sphere3D(3)
+	box, _ := Box3D(V3{4, 5, 6}, 0.5)
+	circle, _ := Circle2D(2)
+	profile := Union2D(Transform2D(circle, Translate2d(V2{4, 0})), Box2D(V2{3, 2}, 0.2))
+	rounded, _ := ExtrudeRounded3D(profile, 3, 0.5)
+	union := Union3D(
+		Transform3D(sphere, Translate3d(V3{1, 2, 3})),
+		Transform3D(box, RotateZ(0.3).Mul(Scale3d(V3{1, 2, 1}))),
+	)
+	union.(*UnionSDF3).SetMin(RoundMin(1))
+	revolve, _ := RevolveTheta3D(Transform2D(circle, Translate2d(V2{4, 0})), 0.75*Tau)
+	for i, s := range []SDF3{
+		sphere,
+		union,
+		rounded,
+		revolve,
+		ScaleUniform3D(union, 0.5),
+		Difference3D(box, Offset3D(sphere, 0.5)),
+		Intersect3D(box, Elongate3D(sphere, V3{2, 0, 1})),
+		Extrude3D(profile, 3),
+		TwistExtrude3D(profile, 4, Pi),
+		ScaleExtrude3D(profile, 4, V2{0.3, 2}),
+		ScaleTwistExtrude3D(profile, 4, 2, V2{0.5, 0.8}),
+	} {
+		if _, ok := s.(IntervalEvaluator); !ok {
+			t.Fatalf("%d: expected an interval evaluator", i)
+		}
+		rnd := rand.New(rand.NewSource(1))
+		for j := 0; j < 100; j++ {
+			c := V3{rnd.Float64(), rnd.Float64(), rnd.Float64()}.MulScalar(16).SubScalar(8)
+			b := NewBox3(c, V3{rnd.Float64(), rnd.Float64(), rnd.Float64()}.MulScalar(4))
+			lo, hi := EvaluateInterval3(s, b)
+			if lo > hi {
+				t.Fatalf("%d, %v: bad interval %f %f", i, b, lo, hi)
+			}
+			for k := 0; k < 50; k++ {
+				p := b.Min.Add(b.Size().Mul(V3{rnd.Float64(), rnd.Float64(), rnd.Float64()}))
+				if d := s.Evaluate(p); d < lo-tolerance || d > hi+tolerance {
+					t.Fatalf("%d, %v: %f not in [%f, %f]", i, p, d, lo, hi)
+				}
+			}
+		}
+	}
+
+	// a custom extrusion can't be bounded within its z range
+	e := Extrude3D(profile, 3)
+	e.(*ExtrudeSDF3).SetExtrude(NormalExtrude)
+	if lo, hi := EvaluateInterval3(e, NewBox3(V3{20, 0, 0}, V3{1, 1, 1})); lo > 0 || !math.IsInf(hi, 1) {
+		t.Errorf("custom extrusion: %f %f", lo, hi)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Lipschitz(t *testing.T) {
+	// the distance at a point must be a bound on the distance to the surface,
+	// ie: there are no points within the distance with a different sign
+	profile := Box2D(V2{3, 1}, 0.2)
+	profile = Transform2D(profile, Translate2d(V2{3, 0}))
+	iso, _ := ISOThread(2, 1, true)
+	screw, _ := Screw3D(iso, 6, 1, 6)
+	path, _ := PolylinePath3([]V3{{0, 0, -1}, {0, 0, 1}})
+	twist, _ := ScaleTwistSweep3D(profile, path, 3*Pi, V2{1, 1})
+	scale, _ := ScaleTwistSweep3D(profile, path, 2*Pi, V2{0.5, 2})
+	for i, s := range []SDF3{
+		TwistExtrude3D(profile, 2, 3*Pi),
+		ScaleExtrude3D(profile, 2, V2{0.2, 4}),
+		ScaleTwistExtrude3D(profile, 2, 2*Pi, V2{0.5, 2}),
+		screw,
+		twist,
+		scale,
+	} {
+		rnd := rand.New(rand.NewSource(1))
+		bb := s.BoundingBox()
+		bb = NewBox3(bb.Center(), bb.Size().MulScalar(1.5))
+		for j := 0; j < 2000; j++ {
+			p := bb.Min.Add(bb.Size().Mul(V3{rnd.Float64(), rnd.Float64(), rnd.Float64()}))
+			d := s.Evaluate(p)
+			for k := 0; k < 20; k++ {
+				v := V3{rnd.NormFloat64(), rnd.NormFloat64(), rnd.NormFloat64()}.Normalize()
+				q := p.Add(v.MulScalar(0.99 * math.Abs(d) * math.Cbrt(rnd.Float64())))
+				if dq := s.Evaluate(q); d*dq < 0 && math.Abs(dq) > tolerance {
+					t.Fatalf("%d: d(%v) = %f, d(%v) = %f", i, p, d, q, dq)
+				}
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Loft(t *testing.T) {
+	// a rounded loft between circles of the same radius is a rounded cylinder,
+	// the profile is grown by the rounding radius
+	c, _ := Circle2D(5)
+	s, err := Loft3D(c, c, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := 1 / math.Sqrt2
+	for _, test := range []struct {
+		p V3
+		d float64
+	}{
+		{V3{7, 0, 0}, 0},
+		{V3{0, 7, 6}, 0},
+		{V3{0, 0, 10}, 0},
+		{V3{0, 0, -10}, 0},
+		{V3{5 + 2*r, 0, 8 + 2*r}, 0},
+		{V3{8, 0, 0}, 1},
+		{V3{0, 0, 11}, 1},
+		{V3{0, 0, 0}, -7},
+	} {
+		if d := s.Evaluate(test.p); math.Abs(d-test.d) > tolerance {
+			t.Errorf("%v: distance %f (expected %f)", test.p, d, test.d)
+		}
+	}
+	if bb := s.BoundingBox(); bb != (Box3{V3{-7, -7, -10}, V3{7, 7, 10}}) {
+		t.Errorf("bounding box %v", bb)
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,440 @@
+//-----------------------------------------------------------------------------
+/*
+
+Bezier and B-Spline Surfaces
+
+A surface patch is a tensor product of bezier or b-spline curves defined by
+a grid of control points. The distance to a patch is found by sampling the
+patch to get a starting point and then using Newton-Raphson minimisation to
+project the point onto the surface.
+
+A set of patches can be used as:
+
+1) A shell: the surface thickened by a given amount. The patches don't need
+to be closed or oriented.
+
+2) A solid: the patches form a closed surface with the normals (Su x Sv)
+pointing outwards. The sign is given by the normal at the closest point.
+
+A bezier patch is a b-spline patch with no internal knots, so they share the
+same evaluation code.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+const patchMaxIters = 12       // maximum newton iterations
+const patchSamplesPerPoint = 4 // samples per control point for the initial estimate
+const patchStarts = 3          // number of starting samples for the newton iterations
+
+//-----------------------------------------------------------------------------
+// B-Spline Basis Functions
+
+// bsplineBasis is a set of b-spline basis functions.
+type bsplineBasis struct {
+	p    int       // degree
+	n    int       // number of basis functions (control points)
+	knot []float64 // knot vector, len = n + p + 1
+}
+
+// newBasis returns the basis for n control points of degree p
+// using a clamped uniform knot vector on [0,1].
+func newBasis(n, p int) *bsplineBasis {
+	b := bsplineBasis{p: p, n: n}
+	b.knot = make([]float64, n+p+1)
+	spans := n - p
+	for i := range b.knot {
+		b.knot[i] = Clamp(float64(i-p)/float64(spans), 0, 1)
+	}
+	return &b
+}
+
+// eval returns the basis function values and 1st/2nd derivatives at t.
+func (b *bsplineBasis) eval(t float64, f0, f1, f2 []float64) {
+	p, k := b.p, b.knot
+	m := len(k) - 1
+	// the knot span containing t
+	span := p
+	for span < m-p-1 && t >= k[span+1] {
+		span++
+	}
+	// scratch space for the degree d, d-1 and d-2 functions and the derivatives
+	sb := getFloats(4 * m)
+	scratch := *sb
+	// degree 0
+	n := scratch[0:m]
+	for i := range n {
+		n[i] = 0
+	}
+	n[span] = 1
+	// basis functions of degree d from degree d-1
+	// keep the degree p-1 and p-2 functions for the derivatives
+	var n1, n2 []float64
+	for d := 1; d <= p; d++ {
+		// reuse the degree d-3 buffer
+		j := (d % 3) * m
+		nd := scratch[j : j+m-d]
+		for i := range nd {
+			nd[i] = 0
+			if x := k[i+d] - k[i]; x != 0 {
+				nd[i] += (t - k[i]) / x * n[i]
+			}
+			if x := k[i+d+1] - k[i+1]; x != 0 {
+				nd[i] += (k[i+d+1] - t) / x * n[i+1]
+			}
+		}
+		n2, n1, n = n1, n, nd
+	}
+	// derivative of basis i (degree d) in terms of degree d-1
+	deriv := func(nd []float64, d, i int) float64 {
+		var x float64
+		if y := k[i+d] - k[i]; y != 0 {
+			x += float64(d) / y * nd[i]
+		}
+		if y := k[i+d+1] - k[i+1]; y != 0 {
+			x -= float64(d) / y * nd[i+1]
+		}
+		return x
+	}
+	var d1 []float64
+	if p >= 1 {
+		d1 = scratch[3*m : 3*m+len(n1)]
+		if p >= 2 {
+			// 1st derivatives of the degree p-1 functions
+			for i := range d1 {
+				d1[i] = deriv(n2, p-1, i)
+			}
+		}
+	}
+	for i := 0; i < b.n; i++ {
+		f0[i] = n[i]
+		f1[i], f2[i] = 0, 0
+		if p >= 1 {
+			f1[i] = deriv(n1, p, i)
+		}
+		if p >= 2 {
+			f2[i] = deriv(d1, p, i)
+		}
+	}
+	floatPool.Put(sb)
+}
+
+//-----------------------------------------------------------------------------
+// Surface Patches
+
+// SurfacePatch is a bezier or b-spline surface patch.
+type SurfacePatch struct {
+	cp     [][]V3        // control points, cp[i][j]: i along u, j along v
+	bu, bv *bsplineBasis // u/v basis functions
+	bb     Box3          // bounding box of the control points
+	uv     []V2          // sample parameters for the initial estimate
+	sample []V3          // sample points for the initial estimate
+}
+
+// patchEval holds the surface point and derivatives at a (u,v) parameter.
+type patchEval struct {
+	s, su, sv, suu, suv, svv V3
+}
+
+// newPatch returns a surface patch for a control point grid.
+func newPatch(cp [][]V3, p, q int) (*SurfacePatch, error) {
+	if len(cp) < 2 || len(cp[0]) < 2 {
+		return nil, errors.New("a patch needs at least 2x2 control points")
+	}
+	for i := range cp {
+		if len(cp[i]) != len(cp[0]) {
+			return nil, errors.New("the control point grid is not rectangular")
+		}
+	}
+	s := SurfacePatch{cp: cp}
+	s.bu = newBasis(len(cp), p)
+	s.bv = newBasis(len(cp[0]), q)
+	// the patch lies within the convex hull of the control points
+	s.bb = Box3{cp[0][0], cp[0][0]}
+	for i := range cp {
+		for _, v := range cp[i] {
+			s.bb = s.bb.Extend(Box3{v, v})
+		}
+	}
+	// samples for the initial estimate
+	nu := patchSamplesPerPoint * len(cp)
+	nv := patchSamplesPerPoint * len(cp[0])
+	for i := 0; i <= nu; i++ {
+		for j := 0; j <= nv; j++ {
+			uv := V2{float64(i) / float64(nu), float64(j) / float64(nv)}
+			s.uv = append(s.uv, uv)
+			s.sample = append(s.sample, s.eval(uv.X, uv.Y).s)
+		}
+	}
+	return &s, nil
+}
+
+// BezierPatch returns a bezier surface patch.
+// The degree in u/v is one less than the control point grid size.
+func BezierPatch(cp [][]V3) (*SurfacePatch, error) {
+	if len(cp) == 0 {
+		return nil, errors.New("no control points")
+	}
+	return newPatch(cp, len(cp)-1, len(cp[0])-1)
+}
+
+// BSplinePatch returns a b-spline surface patch with clamped uniform knots
+// (the patch passes through the corner control points).
+func BSplinePatch(cp [][]V3, degreeU, degreeV int) (*SurfacePatch, error) {
+	if len(cp) == 0 {
+		return nil, errors.New("no control points")
+	}
+	if degreeU < 1 || degreeV < 1 {
+		return nil, errors.New("degree must be >= 1")
+	}
+	if degreeU >= len(cp) || degreeV >= len(cp[0]) {
+		return nil, errors.New("degree must be less than the number of control points")
+	}
+	return newPatch(cp, degreeU, degreeV)
+}
+
+// eval returns the surface point and derivatives at (u,v).
+func (s *SurfacePatch) eval(u, v float64) patchEval {
+	nu, nv := s.bu.n, s.bv.n
+	sb := getFloats(3 * (nu + nv))
+	b := *sb
+	u0, u1, u2 := b[0:nu], b[nu:2*nu], b[2*nu:3*nu]
+	b = b[3*nu:]
+	v0, v1, v2 := b[0:nv], b[nv:2*nv], b[2*nv:3*nv]
+	s.bu.eval(u, u0, u1, u2)
+	s.bv.eval(v, v0, v1, v2)
+	var e patchEval
+	for i := 0; i < nu; i++ {
+		if u0[i] == 0 && u1[i] == 0 && u2[i] == 0 {
+			continue
+		}
+		for j := 0; j < nv; j++ {
+			p := s.cp[i][j]
+			e.s = e.s.Add(p.MulScalar(u0[i] * v0[j]))
+			e.su = e.su.Add(p.MulScalar(u1[i] * v0[j]))
+			e.sv = e.sv.Add(p.MulScalar(u0[i] * v1[j]))
+			e.suu = e.suu.Add(p.MulScalar(u2[i] * v0[j]))
+			e.suv = e.suv.Add(p.MulScalar(u1[i] * v1[j]))
+			e.svv = e.svv.Add(p.MulScalar(u0[i] * v2[j]))
+		}
+	}
+	floatPool.Put(sb)
+	return e
+}
+
+// Point returns the surface point at (u,v), u,v in [0,1].
+func (s *SurfacePatch) Point(u, v float64) V3 {
+	return s.eval(Clamp(u, 0, 1), Clamp(v, 0, 1)).s
+}
+
+// normal returns the (unnormalized) surface normal at (u,v).
+// Degenerate points (E.g. collapsed edges) use a nearby point.
+func (s *SurfacePatch) normal(u, v float64) V3 {
+	for i := 0; i < 4; i++ {
+		e := s.eval(u, v)
+		n := e.su.Cross(e.sv)
+		if n.Length2() > epsilon*epsilon {
+			return n
+		}
+		// move towards the center of the patch
+		u += 1e-3 * (0.5 - u) * math.Pow(10, float64(i))
+		v += 1e-3 * (0.5 - v) * math.Pow(10, float64(i))
+	}
+	return V3{}
+}
+
+// project returns the (u,v) parameters and position of the closest point on the patch.
+// The distance can have several local minima, so the newton iterations are
+// started from the few closest samples and the best result is used.
+func (s *SurfacePatch) project(p V3) (V2, V3) {
+	// initial estimates
+	var start [patchStarts]int
+	var dstart [patchStarts]float64
+	n := 0
+	for i, x := range s.sample {
+		d := x.Sub(p).Length2()
+		if n == patchStarts && d >= dstart[n-1] {
+			continue
+		}
+		if n < patchStarts {
+			n++
+		}
+		// insert in order of distance
+		j := n - 1
+		for ; j > 0 && dstart[j-1] > d; j-- {
+			start[j], dstart[j] = start[j-1], dstart[j-1]
+		}
+		start[j], dstart[j] = i, d
+	}
+	uv, x := s.uv[start[0]], s.sample[start[0]]
+	dmin := dstart[0]
+	for _, k := range start[:n] {
+		uv1, x1 := s.newton(p, s.uv[k])
+		if d := x1.Sub(p).Length2(); d < dmin {
+			uv, x, dmin = uv1, x1, d
+		}
+	}
+	return uv, x
+}
+
+// newton minimises |S(u,v) - p|^2 with newton iterations starting at uv.
+func (s *SurfacePatch) newton(p V3, uv V2) (V2, V3) {
+	u, v := uv.X, uv.Y
+	for i := 0; i < patchMaxIters; i++ {
+		e := s.eval(u, v)
+		r := e.s.Sub(p)
+		gu, gv := r.Dot(e.su), r.Dot(e.sv)
+		// the hessian, or the gauss-newton approximation if it isn't positive definite
+		a := e.su.Dot(e.su) + r.Dot(e.suu)
+		b := e.su.Dot(e.sv) + r.Dot(e.suv)
+		c := e.sv.Dot(e.sv) + r.Dot(e.svv)
+		if a <= epsilon {
+			a = e.su.Dot(e.su)
+		}
+		if c <= epsilon {
+			c = e.sv.Dot(e.sv)
+		}
+		det := a*c - b*b
+		if det <= epsilon*epsilon {
+			b = e.su.Dot(e.sv)
+			det = a*c - b*b
+		}
+		// parameters on the patch boundary with the gradient pointing
+		// out of the patch are fixed
+		fixU := (u == 0 && gu > 0) || (u == 1 && gu < 0)
+		fixV := (v == 0 && gv > 0) || (v == 1 && gv < 0)
+		var du, dv float64
+		switch {
+		case fixU && fixV:
+			// at a corner
+		case fixU:
+			// minimise along v
+			if c > epsilon {
+				dv = -gv / c
+			}
+		case fixV:
+			// minimise along u
+			if a > epsilon {
+				du = -gu / a
+			}
+		case det > epsilon*epsilon:
+			du = -(c*gu - b*gv) / det
+			dv = -(a*gv - b*gu) / det
+		default:
+			// degenerate, step along the gradient
+			if a > epsilon {
+				du = -gu / a
+			}
+			if c > epsilon {
+				dv = -gv / c
+			}
+		}
+		u1, v1 := Clamp(u+du, 0, 1), Clamp(v+dv, 0, 1)
+		done := math.Abs(u1-u) < 1e-10 && math.Abs(v1-v) < 1e-10
+		u, v = u1, v1
+		if done {
+			break
+		}
+	}
+	return V2{u, v}, s.eval(u, v).s
+}
+
+//-----------------------------------------------------------------------------
+
+// SurfaceSDF3 is an SDF3 made from a set of surface patches.
+type SurfaceSDF3 struct {
+	patch     []*SurfacePatch // surface patches
+	solid     bool            // the patches enclose a solid
+	thickness float64         // half the shell thickness
+	bb        Box3            // bounding box
+}
+
+// newSurface returns an SDF3 for a set of surface patches.
+func newSurface(patch []*SurfacePatch, solid bool, thickness float64) (SDF3, error) {
+	if len(patch) == 0 {
+		return nil, ErrMsg("no patches")
+	}
+	s := SurfaceSDF3{
+		patch:     patch,
+		solid:     solid,
+		thickness: thickness / 2,
+	}
+	s.bb = patch[0].bb
+	for _, x := range patch {
+		s.bb = s.bb.Extend(x.bb)
+	}
+	s.bb = Box3{s.bb.Min.SubScalar(s.thickness), s.bb.Max.AddScalar(s.thickness)}
+	return &s, nil
+}
+
+// PatchShell3D returns an SDF3 for a set of surface patches thickened to a shell.
+func PatchShell3D(patch []*SurfacePatch, thickness float64) (SDF3, error) {
+	if thickness <= 0 {
+		return nil, ErrMsg("thickness <= 0")
+	}
+	return newSurface(patch, false, thickness)
+}
+
+// PatchSolid3D returns an SDF3 for a solid enclosed by a closed set of surface patches.
+// The patch normals (Su x Sv) must point outwards.
+func PatchSolid3D(patch []*SurfacePatch) (SDF3, error) {
+	return newSurface(patch, true, 0)
+}
+
+// Evaluate returns the minimum distance to a set of surface patches.
+func (s *SurfaceSDF3) Evaluate(p V3) float64 {
+	dmin := math.MaxFloat64
+	sign := 1.0
+	cmax := 0.0
+	for _, x := range s.patch {
+		if boxDist2(&x.bb, p) > dmin {
+			continue
+		}
+		uv, q := x.project(p)
+		r := p.Sub(q)
+		d := r.Length2()
+		tol := epsilon * (1 + dmin)
+		if d > dmin+tol {
+			continue
+		}
+		if !s.solid {
+			dmin = math.Min(d, dmin)
+			continue
+		}
+		// The sign is given by the normal at the closest point.
+		// For ties (edges/corners) use the most decisive normal.
+		n := x.normal(uv.X, uv.Y)
+		c := r.Dot(n)
+		if l := r.Length() * n.Length(); l > 0 {
+			c /= l
+		}
+		if d < dmin-tol || math.Abs(c) > cmax {
+			cmax = math.Abs(c)
+			if c < 0 {
+				sign = -1
+			} else {
+				sign = 1
+			}
+		}
+		dmin = math.Min(d, dmin)
+	}
+	if !s.solid {
+		return math.Sqrt(dmin) - s.thickness
+	}
+	return sign * math.Sqrt(dmin)
+}
+
+// BoundingBox returns the bounding box for a set of surface patches.
+func (s *SurfaceSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,673 @@
+//-----------------------------------------------------------------------------
+/*
+
+SVG Import
+
+Convert the filled shapes of an SVG file into an SDF2.
+
+Supported elements: path, polygon, polyline, rect, circle, ellipse and g.
+Supported attributes: transform, fill (none or not), fill-rule and display
+(as attributes or within a style attribute).
+
+Curves are converted to line segments. Each element becomes a polygon SDF2
+with its own fill rule and the elements are unioned. Stroked outlines, text,
+gradients, clipping, CSS style sheets and <use> elements are not supported.
+
+The SVG y-axis points down. It is flipped so the shape has the same
+orientation as it does in an SVG viewer. Dimensions are in SVG user units
+(the viewBox and width/height attributes are ignored).
+
+See: https://www.w3.org/TR/SVG/paths.html
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+const svgCurveSegments = 16 // line segments per bezier curve
+const svgArcSegments = 64   // line segments per full circle/ellipse
+
+//-----------------------------------------------------------------------------
+// Number Scanning
+
+// svgScanner reads numbers and flags from SVG attribute values.
+type svgScanner struct {
+	s string
+	i int
+}
+
+// skip skips whitespace and commas.
+func (sc *svgScanner) skip() {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) >= 0 {
+		sc.i++
+	}
+}
+
+// more returns true if there is more to read.
+func (sc *svgScanner) more() bool {
+	sc.skip()
+	return sc.i < len(sc.s)
+}
+
+// peek returns the next character.
+func (sc *svgScanner) peek() byte {
+	sc.skip()
+	if sc.i < len(sc.s) {
+		return sc.s[sc.i]
+	}
+	return 0
+}
+
+// isNumber returns true if the next value is a number.
+func (sc *svgScanner) isNumber() bool {
+	c := sc.peek()
+	return (c >= '0' && c <= '9') || c == '.' || c == '-' || c == '+'
+}
+
+// digits skips the digits at the current position, returns the number skipped.
+func (sc *svgScanner) digits() int {
+	i := sc.i
+	for sc.i < len(sc.s) && sc.s[sc.i] >= '0' && sc.s[sc.i] <= '9' {
+		sc.i++
+	}
+	return sc.i - i
+}
+
+// number reads a number.
+func (sc *svgScanner) number() (float64, error) {
+	sc.skip()
+	start := sc.i
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '-' || sc.s[sc.i] == '+') {
+		sc.i++
+	}
+	n := sc.digits()
+	if sc.i < len(sc.s) && sc.s[sc.i] == '.' {
+		sc.i++
+		n += sc.digits()
+	}
+	if n == 0 {
+		sc.i = start
+		return 0, fmt.Errorf("expected a number at \"%s\"", sc.s[start:])
+	}
+	// exponent
+	if sc.i < len(sc.s) && (sc.s[sc.i] == 'e' || sc.s[sc.i] == 'E') {
+		i := sc.i
+		sc.i++
+		if sc.i < len(sc.s) && (sc.s[sc.i] == '-' || sc.s[sc.i] == '+') {
+			sc.i++
+		}
+		if sc.digits() == 0 {
+			// not an exponent (E.g. "em" units)
+			sc.i = i
+		}
+	}
+	return strconv.ParseFloat(sc.s[start:sc.i], 64)
+}
+
+// numbers reads n numbers.
+func (sc *svgScanner) numbers(n int) ([]float64, error) {
+	x := make([]float64, n)
+	for i := range x {
+		var err error
+		x[i], err = sc.number()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// flag reads an arc flag (a single 0 or 1, which may not be separated from the next value).
+func (sc *svgScanner) flag() (bool, error) {
+	switch sc.peek() {
+	case '0':
+		sc.i++
+		return false, nil
+	case '1':
+		sc.i++
+		return true, nil
+	}
+	return false, fmt.Errorf("expected a flag at \"%s\"", sc.s[sc.i:])
+}
+
+// svgLength returns the value of a length attribute (units are ignored).
+func svgLength(s string) float64 {
+	sc := svgScanner{s: s}
+	x, err := sc.number()
+	if err != nil {
+		return 0
+	}
+	return x
+}
+
+//-----------------------------------------------------------------------------
+// Transforms
+
+// svgTransform returns the matrix for an SVG transform attribute.
+func svgTransform(s string) (M33, error) {
+	m := Identity2d()
+	sc := svgScanner{s: s}
+	for sc.more() {
+		// transform name
+		i := sc.i
+		for sc.i < len(sc.s) && sc.s[sc.i] != '(' {
+			sc.i++
+		}
+		name := strings.TrimSpace(sc.s[i:sc.i])
+		if sc.i == len(sc.s) {
+			return m, fmt.Errorf("bad transform \"%s\"", s)
+		}
+		sc.i++
+		// arguments
+		var x []float64
+		for sc.peek() != ')' {
+			v, err := sc.number()
+			if err != nil {
+				return m, err
+			}
+			x = append(x, v)
+		}
+		sc.i++
+		arg := func(i int, v float64) float64 {
+			if i < len(x) {
+				return x[i]
+			}
+			return v
+		}
+		var t M33
+		switch name {
+		case "matrix":
+			if len(x) != 6 {
+				return m, fmt.Errorf("bad matrix \"%s\"", s)
+			}
+			t = M33{x[0], x[2], x[4], x[1], x[3], x[5], 0, 0, 1}
+		case "translate":
+			t = Translate2d(V2{arg(0, 0), arg(1, 0)})
+		case "scale":
+			t = Scale2d(V2{arg(0, 1), arg(1, arg(0, 1))})
+		case "rotate":
+			c := V2{arg(1, 0), arg(2, 0)}
+			t = Translate2d(c).Mul(Rotate2d(DtoR(arg(0, 0)))).Mul(Translate2d(c.Neg()))
+		case "skewX":
+			t = M33{1, math.Tan(DtoR(arg(0, 0))), 0, 0, 1, 0, 0, 0, 1}
+		case "skewY":
+			t = M33{1, 0, 0, math.Tan(DtoR(arg(0, 0))), 1, 0, 0, 0, 1}
+		default:
+			return m, fmt.Errorf("unknown transform \"%s\"", name)
+		}
+		m = m.Mul(t)
+	}
+	return m, nil
+}
+
+//-----------------------------------------------------------------------------
+// Curves
+
+// svgCubic returns the points on a cubic bezier curve (excluding p0).
+func svgCubic(p0, p1, p2, p3 V2) []V2 {
+	var v []V2
+	for i := 1; i <= svgCurveSegments; i++ {
+		t := float64(i) / svgCurveSegments
+		s := 1 - t
+		v = append(v, p0.MulScalar(s*s*s).Add(p1.MulScalar(3*s*s*t)).Add(p2.MulScalar(3*s*t*t)).Add(p3.MulScalar(t*t*t)))
+	}
+	return v
+}
+
+// svgQuadratic returns the points on a quadratic bezier curve (excluding p0).
+func svgQuadratic(p0, p1, p2 V2) []V2 {
+	var v []V2
+	for i := 1; i <= svgCurveSegments; i++ {
+		t := float64(i) / svgCurveSegments
+		s := 1 - t
+		v = append(v, p0.MulScalar(s*s).Add(p1.MulScalar(2*s*t)).Add(p2.MulScalar(t*t)))
+	}
+	return v
+}
+
+// svgArc returns the points on an elliptical arc (excluding p0).
+// See: https://www.w3.org/TR/SVG/implnote.html#ArcImplementationNotes
+func svgArc(p0 V2, rx, ry, phi float64, large, sweep bool, p1 V2) []V2 {
+	if p0.Equals(p1, epsilon) {
+		return nil
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return []V2{p1}
+	}
+	sin, cos := math.Sincos(DtoR(phi))
+	// the midpoint in the rotated coordinate system
+	d := p0.Sub(p1).MulScalar(0.5)
+	x1 := cos*d.X + sin*d.Y
+	y1 := -sin*d.X + cos*d.Y
+	// scale up the radii if they are too small
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		l = math.Sqrt(l)
+		rx *= l
+		ry *= l
+	}
+	// center
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	k := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		k = -k
+	}
+	cx1 := k * rx * y1 / ry
+	cy1 := -k * ry * x1 / rx
+	m := p0.Add(p1).MulScalar(0.5)
+	c := V2{cos*cx1 - sin*cy1 + m.X, sin*cx1 + cos*cy1 + m.Y}
+	// start and sweep angles
+	theta := math.Atan2((y1-cy1)/ry, (x1-cx1)/rx)
+	dtheta := math.Atan2((-y1-cy1)/ry, (-x1-cx1)/rx) - theta
+	if sweep && dtheta < 0 {
+		dtheta += Tau
+	} else if !sweep && dtheta > 0 {
+		dtheta -= Tau
+	}
+	n := int(math.Ceil(math.Abs(dtheta) / Tau * svgArcSegments))
+	if n < 1 {
+		n = 1
+	}
+	var v []V2
+	for i := 1; i < n; i++ {
+		s, c0 := math.Sincos(theta + dtheta*float64(i)/float64(n))
+		v = append(v, V2{cos*rx*c0 - sin*ry*s + c.X, sin*rx*c0 + cos*ry*s + c.Y})
+	}
+	return append(v, p1)
+}
+
+// svgEllipse returns the points of an ellipse.
+func svgEllipse(c V2, rx, ry float64) []V2 {
+	if rx <= 0 || ry <= 0 {
+		return nil
+	}
+	v := make([]V2, svgArcSegments)
+	for i := range v {
+		s, c0 := math.Sincos(Tau * float64(i) / svgArcSegments)
+		v[i] = V2{c.X + rx*c0, c.Y + ry*s}
+	}
+	return v
+}
+
+//-----------------------------------------------------------------------------
+// Paths
+
+// svgPath returns the contours for SVG path data.
+func svgPath(d string) ([][]V2, error) {
+	var contours [][]V2
+	var contour []V2
+	var cur, start, ctrl V2
+	var cmd, prev byte
+	sc := svgScanner{s: d}
+
+	// end the current contour
+	end := func() {
+		if len(contour) >= 3 {
+			contours = append(contours, contour)
+		}
+		contour = nil
+	}
+	// read a point
+	point := func(rel bool) (V2, error) {
+		x, err := sc.numbers(2)
+		if err != nil {
+			return V2{}, err
+		}
+		p := V2{x[0], x[1]}
+		if rel {
+			p = p.Add(cur)
+		}
+		return p, nil
+	}
+
+	for sc.more() {
+		if c := sc.peek(); !sc.isNumber() {
+			if cmd == 0 && c != 'M' && c != 'm' {
+				return nil, fmt.Errorf("path data must start with a moveto \"%s\"", d)
+			}
+			cmd = c
+			sc.i++
+		} else if cmd == 0 {
+			return nil, fmt.Errorf("path data must start with a moveto \"%s\"", d)
+		} else if cmd == 'M' {
+			// implicit lineto after a moveto
+			cmd = 'L'
+		} else if cmd == 'm' {
+			cmd = 'l'
+		} else if cmd == 'Z' || cmd == 'z' {
+			// closepath has no arguments
+			return nil, fmt.Errorf("number after a closepath \"%s\"", d)
+		}
+		rel := cmd >= 'a' && cmd <= 'z'
+		if contour == nil && cmd != 'M' && cmd != 'm' {
+			// drawing after a closepath starts a new contour at the current point
+			contour = []V2{cur}
+		}
+		var err error
+		switch cmd {
+		case 'M', 'm':
+			end()
+			cur, err = point(rel)
+			start = cur
+			contour = []V2{cur}
+		case 'L', 'l':
+			cur, err = point(rel)
+			contour = append(contour, cur)
+		case 'H', 'h':
+			var x float64
+			x, err = sc.number()
+			if rel {
+				x += cur.X
+			}
+			cur = V2{x, cur.Y}
+			contour = append(contour, cur)
+		case 'V', 'v':
+			var y float64
+			y, err = sc.number()
+			if rel {
+				y += cur.Y
+			}
+			cur = V2{cur.X, y}
+			contour = append(contour, cur)
+		case 'C', 'c', 'S', 's':
+			var p1, p2, p3 V2
+			if cmd == 'C' || cmd == 'c' {
+				p1, err = point(rel)
+			} else {
+				// reflect the previous control point
+				p1 = cur
+				if strings.IndexByte("CcSs", prev) >= 0 {
+					p1 = cur.MulScalar(2).Sub(ctrl)
+				}
+			}
+			if err == nil {
+				p2, err = point(rel)
+			}
+			if err == nil {
+				p3, err = point(rel)
+			}
+			if err == nil {
+				contour = append(contour, svgCubic(cur, p1, p2, p3)...)
+				ctrl, cur = p2, p3
+			}
+		case 'Q', 'q', 'T', 't':
+			var p1, p2 V2
+			if cmd == 'Q' || cmd == 'q' {
+				p1, err = point(rel)
+			} else {
+				// reflect the previous control point
+				p1 = cur
+				if strings.IndexByte("QqTt", prev) >= 0 {
+					p1 = cur.MulScalar(2).Sub(ctrl)
+				}
+			}
+			if err == nil {
+				p2, err = point(rel)
+			}
+			if err == nil {
+				contour = append(contour, svgQuadratic(cur, p1, p2)...)
+				ctrl, cur = p1, p2
+			}
+		case 'A', 'a':
+			var x []float64
+			var large, sweep bool
+			var p V2
+			x, err = sc.numbers(3)
+			if err == nil {
+				large, err = sc.flag()
+			}
+			if err == nil {
+				sweep, err = sc.flag()
+			}
+			if err == nil {
+				p, err = point(rel)
+			}
+			if err == nil {
+				contour = append(contour, svgArc(cur, x[0], x[1], x[2], large, sweep, p)...)
+				cur = p
+			}
+		case 'Z', 'z':
+			end()
+			cur = start
+		default:
+			return nil, fmt.Errorf("unknown path command '%c'", cmd)
+		}
+		if err != nil {
+			return nil, err
+		}
+		prev = cmd
+	}
+	end()
+	return contours, nil
+}
+
+// svgPoints returns the contour for a polygon/polyline points attribute.
+func svgPoints(s string) ([]V2, error) {
+	sc := svgScanner{s: s}
+	var v []V2
+	for sc.more() {
+		x, err := sc.numbers(2)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, V2{x[0], x[1]})
+	}
+	return v, nil
+}
+
+// svgRect returns the contours for a (possibly rounded) rectangle.
+func svgRect(x, y, w, h, rx, ry float64) ([][]V2, error) {
+	if w <= 0 || h <= 0 {
+		return nil, nil
+	}
+	if rx <= 0 && ry <= 0 {
+		return [][]V2{{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}}, nil
+	}
+	if rx <= 0 {
+		rx = ry
+	}
+	if ry <= 0 {
+		ry = rx
+	}
+	rx = math.Min(rx, w/2)
+	ry = math.Min(ry, h/2)
+	d := fmt.Sprintf("M%g,%g H%g A%g,%g 0 0 1 %g,%g V%g A%g,%g 0 0 1 %g,%g H%g A%g,%g 0 0 1 %g,%g V%g A%g,%g 0 0 1 %g,%g Z",
+		x+rx, y, x+w-rx, rx, ry, x+w, y+ry, y+h-ry, rx, ry, x+w-rx, y+h, x+rx, rx, ry, x, y+h-ry, y+ry, rx, ry, x+rx, y)
+	return svgPath(d)
+}
+
+//-----------------------------------------------------------------------------
+// Elements
+
+// svgState is the (inherited) state for an SVG element.
+type svgState struct {
+	m      M33      // transform to the root coordinate system
+	rule   FillRule // fill rule
+	fill   bool     // the element is filled
+	hidden bool     // the element is not rendered
+}
+
+// svgHidden are the elements whose contents are not rendered directly.
+var svgHidden = map[string]bool{
+	"defs":     true,
+	"clipPath": true,
+	"mask":     true,
+	"marker":   true,
+	"pattern":  true,
+	"symbol":   true,
+	"style":    true,
+}
+
+// property sets a style property.
+func (st *svgState) property(name, value string) error {
+	value = strings.TrimSpace(value)
+	switch strings.TrimSpace(name) {
+	case "fill":
+		st.fill = value != "none"
+	case "fill-rule":
+		switch value {
+		case "nonzero":
+			st.rule = NonZero
+		case "evenodd":
+			st.rule = EvenOdd
+		}
+	case "display":
+		if value == "none" {
+			st.hidden = true
+		}
+	case "transform":
+		m, err := svgTransform(value)
+		if err != nil {
+			return err
+		}
+		st.m = st.m.Mul(m)
+	}
+	return nil
+}
+
+// element returns the state for an element.
+func (st svgState) element(e *xml.StartElement) (svgState, error) {
+	if svgHidden[e.Name.Local] {
+		st.hidden = true
+	}
+	var style string
+	for _, a := range e.Attr {
+		if a.Name.Local == "style" {
+			style = a.Value
+			continue
+		}
+		if err := st.property(a.Name.Local, a.Value); err != nil {
+			return st, err
+		}
+	}
+	// style properties override attributes
+	for _, p := range strings.Split(style, ";") {
+		if kv := strings.SplitN(p, ":", 2); len(kv) == 2 && kv[0] != "transform" {
+			if err := st.property(kv[0], kv[1]); err != nil {
+				return st, err
+			}
+		}
+	}
+	return st, nil
+}
+
+// svgContours returns the contours for an element.
+func svgContours(e *xml.StartElement) ([][]V2, error) {
+	attr := make(map[string]string)
+	for _, a := range e.Attr {
+		attr[a.Name.Local] = a.Value
+	}
+	l := func(name string) float64 {
+		return svgLength(attr[name])
+	}
+	switch e.Name.Local {
+	case "path":
+		return svgPath(attr["d"])
+	case "polygon", "polyline":
+		v, err := svgPoints(attr["points"])
+		if err != nil || len(v) < 3 {
+			return nil, err
+		}
+		return [][]V2{v}, nil
+	case "rect":
+		return svgRect(l("x"), l("y"), l("width"), l("height"), l("rx"), l("ry"))
+	case "circle":
+		v := svgEllipse(V2{l("cx"), l("cy")}, l("r"), l("r"))
+		if v == nil {
+			return nil, nil
+		}
+		return [][]V2{v}, nil
+	case "ellipse":
+		v := svgEllipse(V2{l("cx"), l("cy")}, l("rx"), l("ry"))
+		if v == nil {
+			return nil, nil
+		}
+		return [][]V2{v}, nil
+	}
+	return nil, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// ImportSVG returns an SDF2 for the filled shapes of an SVG document.
+func ImportSVG(r io.Reader) (SDF2, error) {
+	dec := xml.NewDecoder(r)
+	dec.Entity = xml.HTMLEntity
+	// flip the y-axis
+	stack := []svgState{{m: Scale2d(V2{1, -1}), rule: NonZero, fill: true}}
+	var shapes []SDF2
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			st, err := stack[len(stack)-1].element(&t)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, st)
+			if st.hidden || !st.fill {
+				continue
+			}
+			contours, err := svgContours(&t)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", t.Name.Local, err)
+			}
+			if len(contours) == 0 {
+				continue
+			}
+			for _, c := range contours {
+				for i := range c {
+					c[i] = st.m.MulPosition(c[i])
+				}
+			}
+			s, err := Polygons2D(contours, st.rule)
+			if err != nil {
+				return nil, err
+			}
+			shapes = append(shapes, s)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if len(shapes) == 0 {
+		return nil, errors.New("no filled shapes")
+	}
+	if len(shapes) == 1 {
+		return shapes[0], nil
+	}
+	return Union2D(shapes...), nil
+}
+
+// LoadSVG returns an SDF2 for the filled shapes of an SVG file.
+func LoadSVG(fname string) (SDF2, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ImportSVG(f)
+}
+
+//-----------------------------------------------------------------------------
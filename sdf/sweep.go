@@ -0,0 +1,358 @@
+//-----------------------------------------------------------------------------
+/*
+
+Sweep a 2D profile along a 3D path.
+
+The path is converted to a polyline. Each polyline point has a rotation
+minimizing frame (the profile doesn't spin about the path as it moves along
+it). To evaluate a point we find the closest point on the path, work out the
+point position in the frame at the closest point and evaluate the profile.
+
+The profile x/y axes are mapped to the frame normal/binormal. For a path that
+starts along the z-axis the profile at the start of the path is the same as
+the profile of an extrusion.
+
+Closed paths (the first and last points are the same) have no end caps and the
+frames are adjusted so they line up at the start/end of the path.
+
+Notes:
+The path should not bend more sharply than the size of the profile, the
+closest point on the path is not unique for those regions.
+Polylines with sharp corners have rounded joins. Use a spline path for a
+smooth sweep.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+const sweepSamples = 32 // default number of path samples per spline
+const sweepChunk = 16   // number of path segments in a bounding box
+
+//-----------------------------------------------------------------------------
+// 3D Paths
+
+// Path3 is a 3D path that a profile can be swept along.
+type Path3 struct {
+	p []V3 // polyline points
+}
+
+// newPath3 returns a path for a set of points, removing repeated points.
+func newPath3(p []V3) (*Path3, error) {
+	path := Path3{}
+	for _, v := range p {
+		if len(path.p) == 0 || !v.Equals(path.p[len(path.p)-1], epsilon) {
+			path.p = append(path.p, v)
+		}
+	}
+	if len(path.p) < 2 {
+		return nil, errors.New("a path needs at least 2 distinct points")
+	}
+	return &path, nil
+}
+
+// PolylinePath3 returns a path made of straight lines between the points.
+func PolylinePath3(p []V3) (*Path3, error) {
+	return newPath3(p)
+}
+
+// CubicSplinePath3 returns a path passing through the knots using natural cubic splines.
+// Each spline is sampled n times (n = 0 gives a default value).
+func CubicSplinePath3(knot []V3, n int) (*Path3, error) {
+	if len(knot) < 2 {
+		return nil, errors.New("cubic splines need at least 2 knots")
+	}
+	if n <= 0 {
+		n = sweepSamples
+	}
+	// Build and solve the tridiagonal matrices.
+	// See CubicSpline2D.
+	k := len(knot)
+	m := make([]V3, k)
+	d := [3][]float64{make([]float64, k), make([]float64, k), make([]float64, k)}
+	set := func(i int, v V3) {
+		d[0][i] = 3 * v.X
+		d[1][i] = 3 * v.Y
+		d[2][i] = 3 * v.Z
+	}
+	for i := 1; i < k-1; i++ {
+		m[i] = V3{1, 4, 1}
+		set(i, knot[i+1].Sub(knot[i-1]))
+	}
+	m[0] = V3{0, 2, 1}
+	set(0, knot[1].Sub(knot[0]))
+	m[k-1] = V3{1, 2, 0}
+	set(k-1, knot[k-1].Sub(knot[k-2]))
+	var D [3][]float64
+	for j := range d {
+		x, err := triDiagonal(m, d[j])
+		if err != nil {
+			return nil, err
+		}
+		D[j] = x
+	}
+	// sample the splines
+	p := []V3{knot[0]}
+	for i := 0; i < k-1; i++ {
+		var px, py, pz CubicPolynomial
+		px.Set(knot[i].X, knot[i+1].X, D[0][i], D[0][i+1])
+		py.Set(knot[i].Y, knot[i+1].Y, D[1][i], D[1][i+1])
+		pz.Set(knot[i].Z, knot[i+1].Z, D[2][i], D[2][i+1])
+		for j := 1; j <= n; j++ {
+			t := float64(j) / float64(n)
+			p = append(p, V3{px.f0(t), py.f0(t), pz.f0(t)})
+		}
+	}
+	return newPath3(p)
+}
+
+// BezierPath3 returns a path made from cubic bezier curves.
+// The points are end, control, control, end, control, control, end, ...
+// Each curve is sampled n times (n = 0 gives a default value).
+func BezierPath3(p []V3, n int) (*Path3, error) {
+	if len(p) < 4 || (len(p)-1)%3 != 0 {
+		return nil, errors.New("bezier paths need 3n+1 points")
+	}
+	if n <= 0 {
+		n = sweepSamples
+	}
+	s := []V3{p[0]}
+	for i := 0; i < len(p)-1; i += 3 {
+		var px, py, pz BezierPolynomial
+		px.Set([]float64{p[i].X, p[i+1].X, p[i+2].X, p[i+3].X})
+		py.Set([]float64{p[i].Y, p[i+1].Y, p[i+2].Y, p[i+3].Y})
+		pz.Set([]float64{p[i].Z, p[i+1].Z, p[i+2].Z, p[i+3].Z})
+		for j := 1; j <= n; j++ {
+			t := float64(j) / float64(n)
+			s = append(s, V3{px.f0(t), py.f0(t), pz.f0(t)})
+		}
+	}
+	return newPath3(s)
+}
+
+// Closed returns true if the path is a closed loop.
+func (path *Path3) Closed() bool {
+	return len(path.p) > 2 && path.p[0].Equals(path.p[len(path.p)-1], epsilon)
+}
+
+// Points returns the polyline points of the path.
+func (path *Path3) Points() []V3 {
+	return path.p
+}
+
+//-----------------------------------------------------------------------------
+
+// sweepFrame is the coordinate frame at a point on a sweep path.
+type sweepFrame struct {
+	t, n V3      // tangent and normal
+	u    float64 // fractional distance along the path [0,1]
+}
+
+// rotateAbout rotates v about the unit vector k by angle a.
+func rotateAbout(v, k V3, a float64) V3 {
+	s, c := math.Sincos(a)
+	return v.MulScalar(c).Add(k.Cross(v).MulScalar(s)).Add(k.MulScalar(k.Dot(v) * (1 - c)))
+}
+
+// sweepFrames returns rotation minimizing frames for the points of a path.
+// See: Computation of Rotation Minimizing Frames, Wang, Juttler, Zheng & Liu, 2008
+func sweepFrames(p []V3, closed bool) []sweepFrame {
+	n := len(p)
+	f := make([]sweepFrame, n)
+	// tangents bisect the adjacent segments
+	for i := range p {
+		var t V3
+		if i > 0 {
+			t = t.Add(p[i].Sub(p[i-1]).Normalize())
+		} else if closed {
+			t = t.Add(p[n-1].Sub(p[n-2]).Normalize())
+		}
+		if i < n-1 {
+			t = t.Add(p[i+1].Sub(p[i]).Normalize())
+		} else if closed {
+			t = t.Add(p[1].Sub(p[0]).Normalize())
+		}
+		if t.Length() < epsilon {
+			// the path reverses direction
+			if i == 0 {
+				t = p[1].Sub(p[0])
+			} else {
+				t = p[i].Sub(p[i-1])
+			}
+		}
+		f[i].t = t.Normalize()
+	}
+	// fractional distance along the path
+	for i := 1; i < n; i++ {
+		f[i].u = f[i-1].u + p[i].Sub(p[i-1]).Length()
+	}
+	length := f[n-1].u
+	for i := range f {
+		f[i].u /= length
+	}
+	// initial normal: rotate the x-axis as the z-axis is rotated onto the tangent
+	f[0].n = V3{0, 0, 1}.RotateToVector(f[0].t).MulPosition(V3{1, 0, 0})
+	// double reflection
+	for i := 0; i < n-1; i++ {
+		v1 := p[i+1].Sub(p[i])
+		c1 := v1.Dot(v1)
+		nl := f[i].n.Sub(v1.MulScalar(2 * v1.Dot(f[i].n) / c1))
+		tl := f[i].t.Sub(v1.MulScalar(2 * v1.Dot(f[i].t) / c1))
+		v2 := f[i+1].t.Sub(tl)
+		c2 := v2.Dot(v2)
+		if c2 < epsilon {
+			f[i+1].n = nl
+		} else {
+			f[i+1].n = nl.Sub(v2.MulScalar(2 * v2.Dot(nl) / c2))
+		}
+	}
+	if closed {
+		// spread the frame mismatch at the start/end over the path
+		b := f[0].t.Cross(f[0].n)
+		a := math.Atan2(f[n-1].n.Dot(b), f[n-1].n.Dot(f[0].n))
+		for i := range f {
+			f[i].n = rotateAbout(f[i].n, f[i].t, -a*f[i].u)
+		}
+	}
+	return f
+}
+
+//-----------------------------------------------------------------------------
+
+// SweepSDF3 is a 2D profile swept along a 3D path.
+type SweepSDF3 struct {
+	sdf       SDF2                  // profile
+	p         []V3                  // path points
+	f         []sweepFrame          // path frames
+	closed    bool                  // the path is a closed loop
+	twist     float64               // twist (radians) over the length of the path
+	scale     V2                    // profile scale at the end of the path
+	length    float64               // path length
+	r         float64               // maximum profile radius
+	lipschitz func(float64) float64 // bounds the gradient at a distance from the path, nil for 1
+	chunk     []Box3                // bounding boxes for groups of path segments
+	bb        Box3                  // bounding box
+}
+
+// Sweep3D sweeps a 2D profile along a 3D path.
+func Sweep3D(sdf SDF2, path *Path3) (SDF3, error) {
+	return ScaleTwistSweep3D(sdf, path, 0, V2{1, 1})
+}
+
+// ScaleTwistSweep3D sweeps a 2D profile along a 3D path.
+// The profile is twisted (radians) and scaled over the length of the path.
+// Twisting and scaling stretch space, so the profile distance is divided by a
+// bound on the stretch, as for TwistExtrude3D and ScaleExtrude3D.
+func ScaleTwistSweep3D(sdf SDF2, path *Path3, twist float64, scale V2) (SDF3, error) {
+	if sdf == nil || path == nil {
+		return nil, ErrMsg("nil profile or path")
+	}
+	if scale.X <= 0 || scale.Y <= 0 {
+		return nil, ErrMsg("scale must be > 0")
+	}
+	s := SweepSDF3{
+		sdf:    sdf,
+		p:      path.p,
+		closed: path.Closed(),
+		twist:  twist,
+		scale:  scale,
+	}
+	s.f = sweepFrames(s.p, s.closed)
+	// the profile extent
+	bb := sdf.BoundingBox()
+	r := math.Max(bb.Min.Length(), bb.Max.Length())
+	r = math.Max(r, math.Max(V2{bb.Min.X, bb.Max.Y}.Length(), V2{bb.Max.X, bb.Min.Y}.Length()))
+	r *= math.Max(1, scale.MaxComponent())
+	s.r = r
+	for i := 1; i < len(s.p); i++ {
+		s.length += s.p[i].Sub(s.p[i-1]).Length()
+	}
+	if twist != 0 || scale != (V2{1, 1}) {
+		s.lipschitz = sweepLipschitz(s.length, twist, scale)
+	}
+	// bounding boxes for the path segments
+	for i := 0; i < len(s.p)-1; i += sweepChunk {
+		b := Box3{s.p[i], s.p[i]}
+		for j := i + 1; j <= i+sweepChunk && j < len(s.p); j++ {
+			b = b.Extend(Box3{s.p[j], s.p[j]})
+		}
+		s.chunk = append(s.chunk, b)
+	}
+	s.bb = s.chunk[0]
+	for _, b := range s.chunk {
+		s.bb = s.bb.Extend(b)
+	}
+	s.bb = Box3{s.bb.Min.SubScalar(r), s.bb.Max.AddScalar(r)}
+	return &s, nil
+}
+
+// closest returns the path segment and parameter of the closest point on the path.
+func (s *SweepSDF3) closest(p V3) (int, float64) {
+	dmin := math.MaxFloat64
+	imin, tmin := 0, 0.0
+	for k := range s.chunk {
+		if boxDist2(&s.chunk[k], p) >= dmin {
+			continue
+		}
+		i0 := k * sweepChunk
+		for i := i0; i < i0+sweepChunk && i < len(s.p)-1; i++ {
+			a := s.p[i]
+			v := s.p[i+1].Sub(a)
+			t := Clamp(p.Sub(a).Dot(v)/v.Dot(v), 0, 1)
+			d := p.Sub(a.Add(v.MulScalar(t))).Length2()
+			if d < dmin {
+				dmin, imin, tmin = d, i, t
+			}
+		}
+	}
+	return imin, tmin
+}
+
+// Evaluate returns the minimum distance to a swept profile.
+func (s *SweepSDF3) Evaluate(p V3) float64 {
+	i, t := s.closest(p)
+	f0, f1 := &s.f[i], &s.f[i+1]
+	// interpolate the frame at the closest point
+	c := s.p[i].Add(s.p[i+1].Sub(s.p[i]).MulScalar(t))
+	tangent := f0.t.MulScalar(1 - t).Add(f1.t.MulScalar(t)).Normalize()
+	n := f0.n.MulScalar(1 - t).Add(f1.n.MulScalar(t))
+	b := tangent.Cross(n).Normalize()
+	n = b.Cross(tangent)
+	u := f0.u*(1-t) + f1.u*t
+	// position in the profile plane
+	v := p.Sub(c)
+	q := V2{v.Dot(n), v.Dot(b)}
+	k := V2{1, 1}.Add(s.scale.SubScalar(1).MulScalar(u))
+	q = Rotate(-s.twist * u).MulPosition(q).Div(k)
+	d := s.sdf.Evaluate(q)
+	if s.lipschitz != nil {
+		// twisting and scaling stretch space, bound the distance
+		d /= s.lipschitz(math.Max(v.Length(), s.r))
+	}
+	if s.closed {
+		return d
+	}
+	// end caps (for points close to the ends of the path)
+	if u*s.length < s.r {
+		d = math.Max(d, -p.Sub(s.p[0]).Dot(s.f[0].t))
+	}
+	if (1-u)*s.length < s.r {
+		last := len(s.p) - 1
+		d = math.Max(d, p.Sub(s.p[last]).Dot(s.f[last].t))
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box for a swept profile.
+func (s *SweepSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -44,8 +44,8 @@ func pToV2(p truetype.Point) V2 {
 
 //-----------------------------------------------------------------------------
 
-// glyphCurve returns the SDF2 for the n-th curve of the glyph
-func glyphCurve(g *truetype.GlyphBuf, n int) (SDF2, bool, error) {
+// glyphCurve returns the polygon vertices for the n-th curve of the glyph
+func glyphCurve(g *truetype.GlyphBuf, n int) ([]V2, error) {
 	// get the start and end point
 	start := 0
 	if n != 0 {
@@ -54,9 +54,7 @@ func glyphCurve(g *truetype.GlyphBuf, n int) (SDF2, bool, error) {
 	end := g.Ends[n] - 1
 
 	// build a bezier curve from the points
-	// work out the cw/ccw direction
 	b := NewBezier()
-	sum := 0.0
 	offPrev := false
 	vPrev := pToV2(g.Points[end])
 
@@ -75,8 +73,6 @@ func glyphCurve(g *truetype.GlyphBuf, n int) (SDF2, bool, error) {
 		if off {
 			x.Mid()
 		}
-		// accumulate the cw/ccw direction
-		sum += (v.X - vPrev.X) * (v.Y + vPrev.Y)
 		// next point...
 		vPrev = v
 		offPrev = off
@@ -85,28 +81,27 @@ func glyphCurve(g *truetype.GlyphBuf, n int) (SDF2, bool, error) {
 
 	p, err := b.Polygon()
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
-
-	s, err := Polygon2D(p.Vertices())
-	return s, sum > 0, err
+	return p.Vertices(), nil
 }
 
 // glyphConvert returns the SDF2 for a glyph
 func glyphConvert(g *truetype.GlyphBuf) (SDF2, error) {
-	var s0 SDF2
+	// The outer curves are clockwise and the holes are counter-clockwise,
+	// so the glyph is the non-zero winding region of the curves.
+	var contours [][]V2
 	for n := 0; n < len(g.Ends); n++ {
-		s1, cw, err := glyphCurve(g, n)
+		c, err := glyphCurve(g, n)
 		if err != nil {
 			return nil, err
 		}
-		if cw {
-			s0 = Union2D(s0, s1)
-		} else {
-			s0 = Difference2D(s0, s1)
-		}
+		contours = append(contours, c)
+	}
+	if len(contours) == 0 {
+		return nil, nil
 	}
-	return s0, nil
+	return Polygons2D(contours, NonZero)
 }
 
 //-----------------------------------------------------------------------------
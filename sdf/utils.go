@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math"
 	"runtime"
+	"sync"
 )
 
 //-----------------------------------------------------------------------------
@@ -28,6 +29,7 @@ const Mil = MillimetresPerInch / 1000.0
 const sqrtHalf = 0.7071067811865476
 const tolerance = 1e-9
 const epsilon = 1e-12
+const gradientStep = 1e-6 // step for numerical derivatives
 
 //-----------------------------------------------------------------------------
 
@@ -86,37 +88,93 @@ func SawTooth(x, period float64) float64 {
 // MinFunc is a minimum functions for SDF blending.
 type MinFunc func(a, b float64) float64
 
+// Min returns the minimum of a and b, so a MinFunc can be used as a Minimizer.
+func (f MinFunc) Min(a, b float64) float64 {
+	return f(a, b)
+}
+
+// Minimizer is a minimum function for SDF blending.
+// The built-in blends have analytic partial derivatives, other minimum functions
+// are differentiated numerically.
+type Minimizer interface {
+	Min(a, b float64) float64
+}
+
+// blendMin is a built-in minimum function with analytic partial derivatives.
+type blendMin struct {
+	min      MinFunc
+	partials func(a, b float64) (float64, float64)
+}
+
+// Min returns the blended minimum of a and b.
+func (m *blendMin) Min(a, b float64) float64 {
+	return m.min(a, b)
+}
+
 // RoundMin returns a minimum function that uses a quarter-circle to join the two objects smoothly.
-func RoundMin(k float64) MinFunc {
-	return func(a, b float64) float64 {
-		u := V2{k - a, k - b}.Max(V2{0, 0})
-		return math.Max(k, math.Min(a, b)) - u.Length()
+func RoundMin(k float64) Minimizer {
+	return &blendMin{
+		min: func(a, b float64) float64 {
+			u := V2{k - a, k - b}.Max(V2{0, 0})
+			return math.Max(k, math.Min(a, b)) - u.Length()
+		},
+		partials: func(a, b float64) (float64, float64) {
+			u := V2{k - a, k - b}.Max(V2{0, 0})
+			l := u.Length()
+			if l == 0 {
+				return hardMinPartials(a, b)
+			}
+			return u.X / l, u.Y / l
+		},
 	}
 }
 
 // ChamferMin returns a minimum function that makes a 45-degree chamfered edge (the diagonal of a square of size <r>).
 // TODO: why the holes in the rendering?
-func ChamferMin(k float64) MinFunc {
-	return func(a, b float64) float64 {
-		return math.Min(math.Min(a, b), (a-k+b)*sqrtHalf)
+func ChamferMin(k float64) Minimizer {
+	return &blendMin{
+		min: func(a, b float64) float64 {
+			return math.Min(math.Min(a, b), (a-k+b)*sqrtHalf)
+		},
+		partials: func(a, b float64) (float64, float64) {
+			if (a-k+b)*sqrtHalf < math.Min(a, b) {
+				return sqrtHalf, sqrtHalf
+			}
+			return hardMinPartials(a, b)
+		},
 	}
 }
 
 // ExpMin returns a minimum function with exponential smoothing (k = 32).
-func ExpMin(k float64) MinFunc {
-	return func(a, b float64) float64 {
-		return -math.Log(math.Exp(-k*a)+math.Exp(-k*b)) / k
+func ExpMin(k float64) Minimizer {
+	return &blendMin{
+		min: func(a, b float64) float64 {
+			return -math.Log(math.Exp(-k*a)+math.Exp(-k*b)) / k
+		},
+		partials: func(a, b float64) (float64, float64) {
+			// the weights are a softmax of -k*a and -k*b
+			da := 1 / (1 + math.Exp(k*(a-b)))
+			return da, 1 - da
+		},
 	}
 }
 
 // PowMin returns  a minimum function (k = 8).
 // TODO - weird results, is this correct?
-func PowMin(k float64) MinFunc {
-	return func(a, b float64) float64 {
+func PowMin(k float64) Minimizer {
+	min := func(a, b float64) float64 {
 		a = math.Pow(a, k)
 		b = math.Pow(b, k)
 		return math.Pow((a*b)/(a+b), 1/k)
 	}
+	return &blendMin{
+		min: min,
+		partials: func(a, b float64) (float64, float64) {
+			// min^-k = a^-k + b^-k, so d(min)/da = (min/a)^(k+1)
+			d := min(a, b)
+			return math.Pow(d/a, k+1), math.Pow(d/b, k+1)
+		},
+	}
 }
 
 func poly(a, b, k float64) float64 {
@@ -125,9 +183,53 @@ func poly(a, b, k float64) float64 {
 }
 
 // PolyMin returns a minimum function (Try k = 0.1, a bigger k gives a bigger fillet).
-func PolyMin(k float64) MinFunc {
-	return func(a, b float64) float64 {
-		return poly(a, b, k)
+func PolyMin(k float64) Minimizer {
+	return &blendMin{
+		min: func(a, b float64) float64 {
+			return poly(a, b, k)
+		},
+		partials: func(a, b float64) (float64, float64) {
+			// the terms in dh/da and dh/db cancel out
+			h := Clamp(0.5+0.5*(b-a)/k, 0.0, 1.0)
+			return h, 1 - h
+		},
+	}
+}
+
+// minFunc returns the function to evaluate a minimum function.
+func minFunc(min Minimizer) MinFunc {
+	switch m := min.(type) {
+	case MinFunc:
+		return m
+	case *blendMin:
+		return m.min
+	}
+	return min.Min
+}
+
+// hardMinPartials returns the partial derivatives of math.Min at (a, b).
+func hardMinPartials(a, b float64) (float64, float64) {
+	if a < b {
+		return 1, 0
+	}
+	if b < a {
+		return 0, 1
+	}
+	return 0.5, 0.5
+}
+
+// minPartials returns a function for the partial derivatives of a minimum function.
+// They are analytic for the built-in blends and worked out numerically otherwise.
+func minPartials(min Minimizer) func(a, b float64) (float64, float64) {
+	if m, ok := min.(*blendMin); ok {
+		return m.partials
+	}
+	f := min.Min
+	return func(a, b float64) (float64, float64) {
+		h := gradientStep * math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+		da := (f(a+h, b) - f(a-h, b)) / (2 * h)
+		db := (f(a, b+h) - f(a, b-h)) / (2 * h)
+		return da, db
 	}
 }
 
@@ -162,11 +264,18 @@ func TwistExtrude(height, twist float64) ExtrudeFunc {
 	}
 }
 
+// scaleExtrudeCoeffs returns the slope and intercept of the z-dependent scaling
+// applied by ScaleExtrude and ScaleTwistExtrude.
+func scaleExtrudeCoeffs(height float64, scale V2) (m, b V2) {
+	inv := V2{1 / scale.X, 1 / scale.Y}
+	m = inv.Sub(V2{1, 1}).DivScalar(height)
+	b = inv.DivScalar(2).AddScalar(0.5)
+	return m, b
+}
+
 // ScaleExtrude returns an extrusion functions that scales with z.
 func ScaleExtrude(height float64, scale V2) ExtrudeFunc {
-	inv := V2{1 / scale.X, 1 / scale.Y}
-	m := inv.Sub(V2{1, 1}).DivScalar(height) // slope
-	b := inv.DivScalar(2).AddScalar(0.5)     // intercept
+	m, b := scaleExtrudeCoeffs(height, scale)
 	return func(p V3) V2 {
 		return V2{p.X, p.Y}.Mul(m.MulScalar(p.Z).Add(b))
 	}
@@ -175,9 +284,7 @@ func ScaleExtrude(height float64, scale V2) ExtrudeFunc {
 // ScaleTwistExtrude returns an extrusion function that scales and twists with z.
 func ScaleTwistExtrude(height, twist float64, scale V2) ExtrudeFunc {
 	k := twist / height
-	inv := V2{1 / scale.X, 1 / scale.Y}
-	m := inv.Sub(V2{1, 1}).DivScalar(height) // slope
-	b := inv.DivScalar(2).AddScalar(0.5)     // intercept
+	m, b := scaleExtrudeCoeffs(height, scale)
 	return func(p V3) V2 {
 		// Scale and then Twist
 		pnew := V2{p.X, p.Y}.Mul(m.MulScalar(p.Z).Add(b)) // Scale
@@ -189,6 +296,114 @@ func ScaleTwistExtrude(height, twist float64, scale V2) ExtrudeFunc {
 	}
 }
 
+// extrudeBoxFunc returns a bounding box for the extrusion function values of the points in a box.
+type extrudeBoxFunc func(b Box3) Box2
+
+// normalExtrudeBox bounds the values of NormalExtrude over a box.
+func normalExtrudeBox(b Box3) Box2 {
+	return Box2{V2{b.Min.X, b.Min.Y}, V2{b.Max.X, b.Max.Y}}
+}
+
+// rotateBox returns a bounding box for a box rotated by any angle in [a0, a1].
+func rotateBox(b Box2, a0, a1 float64) Box2 {
+	// rotate by the middle angle, the other angles move a point at radius r
+	// by at most r * the angle difference (and no more than 2r)
+	bb := Rotate2d(0.5 * (a0 + a1)).MulBox(b)
+	r := math.Sqrt(b.MinMaxDist2(V2{}).Y)
+	e := r * math.Min(0.5*math.Abs(a1-a0), 2)
+	return Box2{bb.Min.SubScalar(e), bb.Max.AddScalar(e)}
+}
+
+// mulInterval returns the range of x * y for x in [x0, x1] and y in [y0, y1].
+func mulInterval(x0, x1, y0, y1 float64) (float64, float64) {
+	a, b, c, d := x0*y0, x0*y1, x1*y0, x1*y1
+	return math.Min(math.Min(a, b), math.Min(c, d)), math.Max(math.Max(a, b), math.Max(c, d))
+}
+
+// scaleBox returns a bounding box for a box scaled by m*z + b for z in [z0, z1].
+func scaleBox(bb Box2, m, b V2, z0, z1 float64) Box2 {
+	k0, k1 := m.MulScalar(z0).Add(b), m.MulScalar(z1).Add(b)
+	x0, x1 := mulInterval(bb.Min.X, bb.Max.X, math.Min(k0.X, k1.X), math.Max(k0.X, k1.X))
+	y0, y1 := mulInterval(bb.Min.Y, bb.Max.Y, math.Min(k0.Y, k1.Y), math.Max(k0.Y, k1.Y))
+	return Box2{V2{x0, y0}, V2{x1, y1}}
+}
+
+// twistExtrudeBox bounds the values of TwistExtrude over a box.
+func twistExtrudeBox(height, twist float64) extrudeBoxFunc {
+	k := twist / height
+	return func(b Box3) Box2 {
+		return rotateBox(normalExtrudeBox(b), b.Min.Z*k, b.Max.Z*k)
+	}
+}
+
+// scaleExtrudeBox bounds the values of ScaleExtrude over a box.
+func scaleExtrudeBox(height float64, scale V2) extrudeBoxFunc {
+	m, b := scaleExtrudeCoeffs(height, scale)
+	return func(bb Box3) Box2 {
+		return scaleBox(normalExtrudeBox(bb), m, b, bb.Min.Z, bb.Max.Z)
+	}
+}
+
+// scaleTwistExtrudeBox bounds the values of ScaleTwistExtrude over a box.
+func scaleTwistExtrudeBox(height, twist float64, scale V2) extrudeBoxFunc {
+	k := twist / height
+	m, b := scaleExtrudeCoeffs(height, scale)
+	return func(bb Box3) Box2 {
+		sb := scaleBox(normalExtrudeBox(bb), m, b, bb.Min.Z, bb.Max.Z)
+		return rotateBox(sb, bb.Min.Z*k, bb.Max.Z*k)
+	}
+}
+
+// extrudeLipschitzFunc returns a bound (>= 1) on the gradient magnitude of sdf(extrude(p))
+// for the points p in a box, where sdf is a distance bound.
+type extrudeLipschitzFunc func(b Box3) float64
+
+// extrudeLipschitz bounds the norm of the jacobian of an extrusion function that scales
+// by m*z + b and then twists by k*z radians. The jacobian is [R*S | R*(k*R'*S + S')*p.xy],
+// so the norm is no more than sqrt(|S|^2 + (k*|S|*r + |S'*p.xy|)^2).
+func extrudeLipschitz(k float64, m, b V2) extrudeLipschitzFunc {
+	return func(bb Box3) float64 {
+		x := math.Max(math.Abs(bb.Min.X), math.Abs(bb.Max.X))
+		y := math.Max(math.Abs(bb.Min.Y), math.Abs(bb.Max.Y))
+		// the scaling is linear in z, so the maximum is at one end of the z range
+		s0, s1 := m.MulScalar(bb.Min.Z).Add(b).Abs(), m.MulScalar(bb.Max.Z).Add(b).Abs()
+		s := math.Max(s0.MaxComponent(), s1.MaxComponent())
+		dz := k*s*math.Sqrt(x*x+y*y) + math.Sqrt(m.X*m.X*x*x+m.Y*m.Y*y*y)
+		return math.Max(math.Sqrt(s*s+dz*dz), 1)
+	}
+}
+
+// twistExtrudeLipschitz bounds the gradient of a TwistExtrude extrusion over a box.
+func twistExtrudeLipschitz(height, twist float64) extrudeLipschitzFunc {
+	return extrudeLipschitz(twist/height, V2{}, V2{1, 1})
+}
+
+// scaleExtrudeLipschitz bounds the gradient of a ScaleExtrude extrusion over a box.
+func scaleExtrudeLipschitz(height float64, scale V2) extrudeLipschitzFunc {
+	m, b := scaleExtrudeCoeffs(height, scale)
+	return extrudeLipschitz(0, m, b)
+}
+
+// scaleTwistExtrudeLipschitz bounds the gradient of a ScaleTwistExtrude extrusion over a box.
+func scaleTwistExtrudeLipschitz(height, twist float64, scale V2) extrudeLipschitzFunc {
+	m, b := scaleExtrudeCoeffs(height, scale)
+	return extrudeLipschitz(twist/height, m, b)
+}
+
+// sweepLipschitz bounds the gradient of a ScaleTwistSweep3D profile mapping at distances up
+// to r from the path. As for extrusions the path is taken to be straight, the profile is
+// scaled by S = 1/(1 + (scale - 1)*z/length), so |S'| = |scale - 1|*S^2/length.
+func sweepLipschitz(length, twist float64, scale V2) func(r float64) float64 {
+	inv := V2{1 / scale.X, 1 / scale.Y}
+	s := math.Max(1, inv.MaxComponent())
+	m := scale.SubScalar(1).Abs().Mul(inv.Mul(inv).Max(V2{1, 1})).DivScalar(length).MaxComponent()
+	k := math.Abs(twist) / length
+	return func(r float64) float64 {
+		dz := (k*s + m) * r
+		return math.Max(math.Sqrt(s*s+dz*dz), 1)
+	}
+}
+
 //-----------------------------------------------------------------------------
 
 // FloatDecode returns a string that decodes the float64 bitfields.
@@ -254,3 +469,56 @@ func ErrMsg(msg string) error {
 }
 
 //-----------------------------------------------------------------------------
+// Batch Buffers
+// The EvaluateN methods take scratch buffers from pools rather than allocating
+// them for every batch. The pools hold pointers to slices so that returning a
+// buffer doesn't allocate.
+
+var (
+	v2Pool    = sync.Pool{New: func() interface{} { return new([]V2) }}
+	v3Pool    = sync.Pool{New: func() interface{} { return new([]V3) }}
+	floatPool = sync.Pool{New: func() interface{} { return new([]float64) }}
+	intPool   = sync.Pool{New: func() interface{} { return new([]int) }}
+)
+
+// getV2s returns a scratch buffer of n V2s.
+func getV2s(n int) *[]V2 {
+	b := v2Pool.Get().(*[]V2)
+	if cap(*b) < n {
+		*b = make([]V2, n)
+	}
+	*b = (*b)[:n]
+	return b
+}
+
+// getV3s returns a scratch buffer of n V3s.
+func getV3s(n int) *[]V3 {
+	b := v3Pool.Get().(*[]V3)
+	if cap(*b) < n {
+		*b = make([]V3, n)
+	}
+	*b = (*b)[:n]
+	return b
+}
+
+// getFloats returns a scratch buffer of n float64s.
+func getFloats(n int) *[]float64 {
+	b := floatPool.Get().(*[]float64)
+	if cap(*b) < n {
+		*b = make([]float64, n)
+	}
+	*b = (*b)[:n]
+	return b
+}
+
+// getInts returns a scratch buffer of n ints.
+func getInts(n int) *[]int {
+	b := intPool.Get().(*[]int)
+	if cap(*b) < n {
+		*b = make([]int, n)
+	}
+	*b = (*b)[:n]
+	return b
+}
+
+//-----------------------------------------------------------------------------
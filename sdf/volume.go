@@ -0,0 +1,158 @@
+//-----------------------------------------------------------------------------
+/*
+
+SDF for 3D distance volumes.
+
+The distance is interpolated from a regular grid of distance samples.
+Outside of the grid the distance to the grid bounds is added to the
+distance at the nearest point of the grid.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+//-----------------------------------------------------------------------------
+
+// Interpolation is the method used to interpolate volume samples.
+type Interpolation int
+
+// Interpolation methods.
+const (
+	Trilinear Interpolation = iota // linear interpolation of the 8 nearest samples
+	Tricubic                       // Catmull-Rom interpolation of the 64 nearest samples
+)
+
+// VolumeSDF3 is an SDF3 interpolated from a grid of distance samples.
+type VolumeSDF3 struct {
+	origin  V3        // position of sample (0,0,0)
+	spacing float64   // distance between samples
+	size    V3i       // number of samples on each axis
+	data    []float32 // samples, x varies fastest then y then z
+	interp  Interpolation
+	bb      Box3
+}
+
+// Volume3D returns an SDF3 for a grid of distance samples.
+func Volume3D(
+	origin V3, // position of sample (0,0,0)
+	spacing float64, // distance between samples
+	size V3i, // number of samples on each axis
+	data []float32, // samples, x varies fastest then y then z
+	interp Interpolation, // interpolation method
+) (SDF3, error) {
+	if spacing <= 0 {
+		return nil, ErrMsg("spacing <= 0")
+	}
+	if size[0] < 2 || size[1] < 2 || size[2] < 2 {
+		return nil, ErrMsg("size < 2")
+	}
+	if len(data) != size[0]*size[1]*size[2] {
+		return nil, ErrMsg("len(data) != number of samples")
+	}
+	if interp != Trilinear && interp != Tricubic {
+		return nil, ErrMsg("bad interpolation method")
+	}
+	s := VolumeSDF3{
+		origin:  origin,
+		spacing: spacing,
+		size:    size,
+		data:    data,
+		interp:  interp,
+	}
+	s.bb = Box3{origin, origin.Add(size.SubScalar(1).ToV3().MulScalar(spacing))}
+	return &s, nil
+}
+
+// sample returns the sample at (x, y, z).
+// Samples one step outside the grid are linearly extrapolated.
+func (s *VolumeSDF3) sample(x, y, z int) float64 {
+	n := s.size
+	switch {
+	case x < 0:
+		return 2*s.sample(0, y, z) - s.sample(1, y, z)
+	case x >= n[0]:
+		return 2*s.sample(n[0]-1, y, z) - s.sample(n[0]-2, y, z)
+	case y < 0:
+		return 2*s.sample(x, 0, z) - s.sample(x, 1, z)
+	case y >= n[1]:
+		return 2*s.sample(x, n[1]-1, z) - s.sample(x, n[1]-2, z)
+	case z < 0:
+		return 2*s.sample(x, y, 0) - s.sample(x, y, 1)
+	case z >= n[2]:
+		return 2*s.sample(x, y, n[2]-1) - s.sample(x, y, n[2]-2)
+	}
+	return float64(s.data[(z*n[1]+y)*n[0]+x])
+}
+
+// clampInt clamps x to the range [a, b].
+func clampInt(x, a, b int) int {
+	if x < a {
+		return a
+	}
+	if x > b {
+		return b
+	}
+	return x
+}
+
+// catmullRom returns the Catmull-Rom weights for samples -1, 0, 1, 2 at t.
+func catmullRom(t float64) [4]float64 {
+	t2 := t * t
+	t3 := t2 * t
+	return [4]float64{
+		0.5 * (-t3 + 2*t2 - t),
+		0.5 * (3*t3 - 5*t2 + 2),
+		0.5 * (-3*t3 + 4*t2 + t),
+		0.5 * (t3 - t2),
+	}
+}
+
+// Evaluate returns the minimum distance to a volume.
+func (s *VolumeSDF3) Evaluate(p V3) float64 {
+	q := p.Clamp(s.bb.Min, s.bb.Max)
+	g := q.Sub(s.origin).DivScalar(s.spacing)
+	// cell index and fractional position
+	var i [3]int
+	var f [3]float64
+	for k, v := range [3]float64{g.X, g.Y, g.Z} {
+		i[k] = clampInt(int(v), 0, s.size[k]-2)
+		f[k] = v - float64(i[k])
+	}
+	var d float64
+	if s.interp == Tricubic {
+		wx, wy, wz := catmullRom(f[0]), catmullRom(f[1]), catmullRom(f[2])
+		for z := 0; z < 4; z++ {
+			for y := 0; y < 4; y++ {
+				w := wz[z] * wy[y]
+				for x := 0; x < 4; x++ {
+					d += w * wx[x] * s.sample(i[0]+x-1, i[1]+y-1, i[2]+z-1)
+				}
+			}
+		}
+	} else {
+		for z := 0; z < 2; z++ {
+			wz := 1 - f[2]
+			if z == 1 {
+				wz = f[2]
+			}
+			for y := 0; y < 2; y++ {
+				wy := 1 - f[1]
+				if y == 1 {
+					wy = f[1]
+				}
+				x0 := s.sample(i[0], i[1]+y, i[2]+z)
+				x1 := s.sample(i[0]+1, i[1]+y, i[2]+z)
+				d += wz * wy * (x0 + f[0]*(x1-x0))
+			}
+		}
+	}
+	return d + p.Sub(q).Length()
+}
+
+// BoundingBox returns the bounding box of a volume.
+func (s *VolumeSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------